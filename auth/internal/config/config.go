@@ -19,6 +19,60 @@ type Config struct {
 	CACertPath     string `mapstructure:"CA_CERT_PATH"`
 	ServerCertPath string `mapstructure:"SERVER_CERT_PATH"`
 	ServerKeyPath  string `mapstructure:"SERVER_KEY_PATH"`
+
+	RefreshTokenExpiry time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRY"`
+	PruneInterval      time.Duration `mapstructure:"PRUNE_INTERVAL"`
+
+	SigningAlgorithm    string        `mapstructure:"JWT_SIGNING_ALGORITHM"`
+	KeyRotationInterval time.Duration `mapstructure:"KEY_ROTATION_INTERVAL"`
+	KeyGracePeriod      time.Duration `mapstructure:"KEY_GRACE_PERIOD"`
+
+	UserServiceAddr    string        `mapstructure:"USER_SERVICE_ADDR"`
+	OutboxPollInterval time.Duration `mapstructure:"OUTBOX_POLL_INTERVAL"`
+
+	PasswordResetExpiry time.Duration `mapstructure:"PASSWORD_RESET_EXPIRY"`
+
+	AppBaseURL      string `mapstructure:"APP_BASE_URL"`
+	NotifierBackend string `mapstructure:"NOTIFIER_BACKEND"`
+	NotifierDir     string `mapstructure:"NOTIFIER_DIR"`
+	DefaultLocale   string `mapstructure:"DEFAULT_LOCALE"`
+	SMTPHost        string `mapstructure:"SMTP_HOST"`
+	SMTPPort        string `mapstructure:"SMTP_PORT"`
+	SMTPUsername    string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword    string `mapstructure:"SMTP_PASSWORD"`
+	SMTPFrom        string `mapstructure:"SMTP_FROM"`
+
+	RequireEmailVerification   bool          `mapstructure:"REQUIRE_EMAIL_VERIFICATION"`
+	EmailVerificationExpiry    time.Duration `mapstructure:"EMAIL_VERIFICATION_EXPIRY"`
+	VerificationResendCooldown time.Duration `mapstructure:"VERIFICATION_RESEND_COOLDOWN"`
+
+	LoginLockoutThreshold   int           `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginIPLockoutThreshold int           `mapstructure:"LOGIN_IP_LOCKOUT_THRESHOLD"`
+	LoginLockout            time.Duration `mapstructure:"LOGIN_LOCKOUT"`
+	LoginMaxLockout         time.Duration `mapstructure:"LOGIN_MAX_LOCKOUT"`
+	LoginFailureWindow      time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+
+	MFAIssuer string `mapstructure:"MFA_ISSUER"`
+
+	BootstrapAdminEmail string `mapstructure:"BOOTSTRAP_ADMIN_EMAIL"`
+
+	OIDCIssuer string `mapstructure:"OIDC_ISSUER"`
+
+	AuditLogPath string `mapstructure:"AUDIT_LOG_PATH"`
+
+	PasswordHashAlgorithm string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	Argon2Memory          uint32 `mapstructure:"ARGON2_MEMORY"`
+	Argon2Iterations      uint32 `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism     uint8  `mapstructure:"ARGON2_PARALLELISM"`
+	BcryptCost            int    `mapstructure:"BCRYPT_COST"`
+
+	PasswordMinLength          int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength          int    `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordRequireUpper       bool   `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower       bool   `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit       bool   `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol      bool   `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordBreachedHashesFile string `mapstructure:"PASSWORD_BREACHED_HASHES_FILE"`
 }
 
 func LoadConfig() (*Config, error) {
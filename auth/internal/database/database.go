@@ -8,6 +8,7 @@ import (
 	"github.com/PakornBank/go-grpc-example/auth/internal/model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // NewDataBase initializes a new database connection using the provided configuration.
@@ -17,9 +18,58 @@ func NewDataBase(config *config.Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&model.Credential{}); err != nil {
+	if err := db.AutoMigrate(
+		&model.Credential{},
+		&model.RefreshToken{},
+		&model.RevokedToken{},
+		&model.UserRevocation{},
+		&model.SigningKey{},
+		&model.OutboxEvent{},
+		&model.PasswordResetToken{},
+		&model.LoginThrottle{},
+		&model.MFAFactor{},
+		&model.MFARecoveryCode{},
+		&model.MFAChallenge{},
+		&model.Role{},
+		&model.Permission{},
+		&model.RolePermission{},
+		&model.UserRole{},
+		&model.OAuthClient{},
+		&model.OAuthAuthorizationCode{},
+		&model.OAuthConsent{},
+		&model.APIKey{},
+		&model.Session{},
+		&model.LoginEvent{},
+	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := seedRoles(db); err != nil {
+		return nil, fmt.Errorf("failed to seed roles: %w", err)
+	}
+
 	return db, nil
 }
+
+// seedRoles creates the default roles and their permissions if they do not exist yet.
+func seedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for role, permissions := range model.DefaultRoles {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Role{Name: role}).Error; err != nil {
+				return err
+			}
+			for _, permission := range permissions {
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Permission{Name: permission}).Error; err != nil {
+					return err
+				}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RolePermission{
+					RoleName:       role,
+					PermissionName: permission,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -1,37 +1,177 @@
 package di
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/PakornBank/go-grpc-example/auth/internal/config"
 	"github.com/PakornBank/go-grpc-example/auth/internal/database"
+	"github.com/PakornBank/go-grpc-example/auth/internal/keys"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/outbox"
+	"github.com/PakornBank/go-grpc-example/auth/internal/password"
 	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/PakornBank/go-grpc-example/auth/internal/security"
 	"github.com/PakornBank/go-grpc-example/auth/internal/server"
 	"github.com/PakornBank/go-grpc-example/auth/internal/service"
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
+	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"google.golang.org/grpc"
 	"gorm.io/gorm"
 )
 
+const (
+	// defaultPruneInterval is used when PRUNE_INTERVAL is not configured.
+	defaultPruneInterval = time.Hour
+	// keyRotationCheckInterval is how often the signing keys are checked for rotation.
+	keyRotationCheckInterval = 10 * time.Minute
+	// defaultOutboxPollInterval is used when OUTBOX_POLL_INTERVAL is not configured.
+	defaultOutboxPollInterval = 5 * time.Second
+	// defaultAuditLogPath is used when AUDIT_LOG_PATH is not configured.
+	defaultAuditLogPath = "audit.jsonl"
+)
+
 type Container struct {
-	Server *server.Server
-	DB     *gorm.DB
+	Server   *server.Server
+	DB       *gorm.DB
+	UserConn *grpc.ClientConn
+	AuditLog *audit.Log
+	// AuditInterceptor records the state-changing RPCs of Server in AuditLog.
+	AuditInterceptor grpc.UnaryServerInterceptor
+	cancel           context.CancelFunc
 }
 
 func NewContainer(cfg *config.Config) *Container {
+	if cfg.JWTSecret == "" {
+		log.Fatal("JWT_SECRET is required to sign email verification tokens")
+	}
+
 	db, err := database.NewDataBase(cfg)
 	if err != nil {
 		log.Fatal("failed to initialize database: ", err)
 	}
 
 	r := repository.NewRepository(db)
-	s := service.NewService(r, cfg)
+
+	km, err := keys.NewManager(r, cfg.SigningAlgorithm, cfg.KeyRotationInterval, cfg.KeyGracePeriod, cfg.TokenExpiry)
+	if err != nil {
+		log.Fatal("failed to initialize signing keys: ", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := km.Rotate(ctx); err != nil {
+		log.Fatal("failed to load signing keys: ", err)
+	}
+	go runPeriodically(ctx, "rotate signing keys", keyRotationCheckInterval, km.Rotate)
+
+	// The connection is established lazily so the auth service can start while
+	// the user service is down; the outbox retries until it is reachable.
+	userConn, err := grpc.NewClient(cfg.UserServiceAddr, grpc.WithTransportCredentials(security.NewClientCredentials(cfg)))
+	if err != nil {
+		log.Fatal("failed to create user service client: ", err)
+	}
+	userClient := userPB.NewUserServiceClient(userConn)
+
+	dispatcher := outbox.NewDispatcher(r)
+	dispatcher.Handle(outbox.EventCreateUserProfile, outbox.CreateUserProfileHandler(userClient, r))
+	dispatcher.Handle(outbox.EventDeleteUserProfile, outbox.DeleteUserProfileHandler(userClient))
+
+	outboxInterval := cfg.OutboxPollInterval
+	if outboxInterval <= 0 {
+		outboxInterval = defaultOutboxPollInterval
+	}
+	go dispatcher.Run(ctx, outboxInterval)
+
+	renderer, err := notifier.NewRenderer(cfg.DefaultLocale)
+	if err != nil {
+		log.Fatal("failed to load notification templates: ", err)
+	}
+	sender, err := notifier.NewSender(cfg.NotifierBackend, notifier.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}, cfg.NotifierDir)
+	if err != nil {
+		log.Fatal("failed to initialize notifier: ", err)
+	}
+	n := notifier.NewAsyncNotifier(renderer, sender)
+	go n.Run(ctx)
+
+	hasher, err := password.NewHasher(password.Config{
+		Algorithm: cfg.PasswordHashAlgorithm,
+		Argon2: password.Argon2Params{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+		},
+		BcryptCost: cfg.BcryptCost,
+	})
+	if err != nil {
+		log.Fatal("failed to initialize password hasher: ", err)
+	}
+
+	policy, err := password.NewPolicy(password.PolicyConfig{
+		MinLength:          cfg.PasswordMinLength,
+		MaxLength:          cfg.PasswordMaxLength,
+		RequireUpper:       cfg.PasswordRequireUpper,
+		RequireLower:       cfg.PasswordRequireLower,
+		RequireDigit:       cfg.PasswordRequireDigit,
+		RequireSymbol:      cfg.PasswordRequireSymbol,
+		BreachedHashesFile: cfg.PasswordBreachedHashesFile,
+	}, cfg.PasswordHashAlgorithm)
+	if err != nil {
+		log.Fatal("failed to initialize password policy: ", err)
+	}
+
+	s := service.NewService(r, km, dispatcher, n, hasher, policy, userClient, cfg)
+
+	if cfg.BootstrapAdminEmail != "" {
+		if err := s.BootstrapAdmin(ctx, cfg.BootstrapAdminEmail); err != nil {
+			log.Printf("failed to assign admin role to %s: %v", cfg.BootstrapAdminEmail, err)
+		}
+	}
+
+	pruneInterval := cfg.PruneInterval
+	if pruneInterval <= 0 {
+		pruneInterval = defaultPruneInterval
+	}
+	go runPeriodically(ctx, "prune expired tokens", pruneInterval, s.PruneExpired)
+	go runPeriodically(ctx, "prune outbox", pruneInterval, dispatcher.Prune)
+
+	auditLogPath := cfg.AuditLogPath
+	if auditLogPath == "" {
+		auditLogPath = defaultAuditLogPath
+	}
+	auditLog, err := audit.Open(auditLogPath, "auth")
+	if err != nil {
+		log.Fatal("failed to open audit log: ", err)
+	}
 
 	return &Container{
-		Server: server.NewServer(s),
-		DB:     db,
+		Server:           server.NewServer(s),
+		DB:               db,
+		UserConn:         userConn,
+		AuditLog:         auditLog,
+		AuditInterceptor: audit.UnaryServerInterceptor(auditLog, server.AuditedMethods),
+		cancel:           cancel,
 	}
 }
 
 func (c *Container) Close() error {
+	c.cancel()
+
+	if err := c.UserConn.Close(); err != nil {
+		log.Printf("failed to close user service connection: %v", err)
+	}
+
+	if err := c.AuditLog.Close(); err != nil {
+		log.Printf("failed to close audit log: %v", err)
+	}
+
 	sqlDB, err := c.DB.DB()
 	if err != nil {
 		return err
@@ -39,3 +179,20 @@ func (c *Container) Close() error {
 
 	return sqlDB.Close()
 }
+
+// runPeriodically calls fn every interval until ctx is cancelled.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil {
+				log.Printf("%s error: %v", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Package keys manages the asymmetric keys used to sign and verify access tokens.
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	defaultRotationInterval = 30 * 24 * time.Hour
+	rsaKeyBits              = 2048
+	reloadCooldown          = time.Minute
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrNoSigningKey         = errors.New("no signing key available")
+)
+
+// JWK is the public part of a signing key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// key is a parsed signing key.
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+	rotatedAt *time.Time
+}
+
+// Manager signs tokens with the current key and verifies them with any key
+// that is still within its grace period.
+type Manager struct {
+	repository       repository.Repository
+	algorithm        string
+	rotationInterval time.Duration
+	gracePeriod      time.Duration
+
+	mu         sync.RWMutex
+	keys       map[string]*key
+	current    *key
+	lastReload time.Time
+}
+
+// NewManager creates a new Manager. The grace period is never shorter than
+// tokenExpiry, so a rotated key outlives every token it signed.
+func NewManager(repository repository.Repository, algorithm string, rotationInterval, gracePeriod, tokenExpiry time.Duration) (*Manager, error) {
+	if algorithm == "" {
+		algorithm = AlgorithmEdDSA
+	}
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if rotationInterval <= 0 {
+		rotationInterval = defaultRotationInterval
+	}
+	if gracePeriod < tokenExpiry {
+		gracePeriod = tokenExpiry
+	}
+
+	return &Manager{
+		repository:       repository,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		gracePeriod:      gracePeriod,
+		keys:             map[string]*key{},
+	}, nil
+}
+
+// Rotate loads the stored keys, creates a new signing key when the current one
+// is older than the rotation interval, and removes keys past their grace period.
+func (m *Manager) Rotate(ctx context.Context) error {
+	now := time.Now()
+
+	if err := m.repository.DeleteExpiredSigningKeys(ctx, now); err != nil {
+		return fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+
+	if err := m.reload(ctx); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	current := m.current
+	m.mu.RUnlock()
+
+	if current != nil && current.method.Alg() == m.algorithm && now.Sub(current.createdAt) < m.rotationInterval {
+		return nil
+	}
+
+	stored, err := generateKey(m.algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	if err := m.repository.CreateSigningKey(ctx, stored, now.Add(m.gracePeriod)); err != nil {
+		return fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	return m.reload(ctx)
+}
+
+// Sign signs the claims with the current signing key.
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	current := m.current
+	m.mu.RUnlock()
+
+	if current == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(current.method, claims)
+	token.Header["kid"] = current.id
+
+	return token.SignedString(current.private)
+}
+
+// Keyfunc returns the public key matching the kid header of a token.
+func (m *Manager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	k := m.lookup(kid)
+	if k == nil {
+		// Another instance may have rotated the key since we last loaded them.
+		if err := m.reloadIfStale(); err != nil {
+			return nil, err
+		}
+		if k = m.lookup(kid); k == nil {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, token.Method.Alg())
+	}
+
+	return k.public, nil
+}
+
+// PublicKeys returns every key that can still verify tokens in JWK format.
+func (m *Manager) PublicKeys() []JWK {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jwks := make([]JWK, 0, len(m.keys))
+	for _, k := range m.keys {
+		jwk := JWK{
+			Kid: k.id,
+			Use: "sig",
+			Alg: k.method.Alg(),
+		}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks
+}
+
+// SigningAlgorithm returns the algorithm new tokens are signed with.
+func (m *Manager) SigningAlgorithm() string {
+	return m.algorithm
+}
+
+// Algorithms returns the signing algorithms accepted for verification.
+func (m *Manager) Algorithms() []string {
+	return []string{AlgorithmRS256, AlgorithmEdDSA}
+}
+
+func (m *Manager) lookup(kid string) *key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.keys[kid]
+}
+
+func (m *Manager) reloadIfStale() error {
+	m.mu.RLock()
+	stale := time.Since(m.lastReload) > reloadCooldown
+	m.mu.RUnlock()
+
+	if !stale {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.reload(ctx)
+}
+
+// reload replaces the cached keys with the ones stored in the database.
+func (m *Manager) reload(ctx context.Context) error {
+	stored, err := m.repository.ListSigningKeys(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	keys := make(map[string]*key, len(stored))
+	var current *key
+	for i := range stored {
+		k, err := parseKey(&stored[i])
+		if err != nil {
+			return fmt.Errorf("failed to parse signing key %s: %w", stored[i].ID, err)
+		}
+		keys[k.id] = k
+		if current == nil && k.rotatedAt == nil {
+			current = k
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.current = current
+	m.lastReload = time.Now()
+	m.mu.Unlock()
+
+	return nil
+}
+
+// generateKey creates a new key pair for the given algorithm.
+func generateKey(algorithm string) (*model.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	kid := make([]byte, 16)
+	if _, err := rand.Read(kid); err != nil {
+		return nil, err
+	}
+
+	return &model.SigningKey{
+		ID:         base64.RawURLEncoding.EncodeToString(kid),
+		Algorithm:  algorithm,
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		PublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+	}, nil
+}
+
+// parseKey decodes a stored signing key.
+func parseKey(stored *model.SigningKey) (*key, error) {
+	var method jwt.SigningMethod
+	switch stored.Algorithm {
+	case AlgorithmRS256:
+		method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, stored.Algorithm)
+	}
+
+	block, _ := pem.Decode(stored.PrivateKey)
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+
+	return &key{
+		id:        stored.ID,
+		method:    method,
+		private:   private,
+		public:    private.Public(),
+		createdAt: stored.CreatedAt,
+		rotatedAt: stored.RotatedAt,
+	}, nil
+}
@@ -0,0 +1,212 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// parse verifies a token with the keys of m.
+func parse(m *Manager, token string) (*jwt.Token, error) {
+	return jwt.NewParser(jwt.WithValidMethods(m.Algorithms())).Parse(token, m.Keyfunc)
+}
+
+func sign(t *testing.T, m *Manager) string {
+	t.Helper()
+
+	token, err := m.Sign(jwt.MapClaims{"sub": "test"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
+
+func newManager(t *testing.T, algorithm string, rotationInterval, gracePeriod time.Duration) *Manager {
+	t.Helper()
+
+	m, err := NewManager(repositorytest.New(), algorithm, rotationInterval, gracePeriod, 0)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func TestNewManager(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		want      string
+		wantErr   error
+	}{
+		{algorithm: "", want: AlgorithmEdDSA},
+		{algorithm: AlgorithmEdDSA, want: AlgorithmEdDSA},
+		{algorithm: AlgorithmRS256, want: AlgorithmRS256},
+		{algorithm: "HS256", wantErr: ErrUnsupportedAlgorithm},
+	}
+
+	for _, tt := range tests {
+		m, err := NewManager(repositorytest.New(), tt.algorithm, 0, 0, time.Minute)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("NewManager(%q) error = %v, want %v", tt.algorithm, err, tt.wantErr)
+			continue
+		}
+		if err == nil && m.SigningAlgorithm() != tt.want {
+			t.Errorf("NewManager(%q) signs with %s, want %s", tt.algorithm, m.SigningAlgorithm(), tt.want)
+		}
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		kty       string
+	}{
+		{algorithm: AlgorithmEdDSA, kty: "OKP"},
+		{algorithm: AlgorithmRS256, kty: "RSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			m := newManager(t, tt.algorithm, 0, 0)
+			if _, err := m.Sign(jwt.MapClaims{}); !errors.Is(err, ErrNoSigningKey) {
+				t.Errorf("Sign before Rotate error = %v, want %v", err, ErrNoSigningKey)
+			}
+			if err := m.Rotate(context.Background()); err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+
+			token, err := parse(m, sign(t, m))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if token.Method.Alg() != tt.algorithm {
+				t.Errorf("token signed with %s, want %s", token.Method.Alg(), tt.algorithm)
+			}
+
+			jwks := m.PublicKeys()
+			if len(jwks) != 1 {
+				t.Fatalf("got %d public keys, want 1", len(jwks))
+			}
+			if jwk := jwks[0]; jwk.Kid != token.Header["kid"] || jwk.Kty != tt.kty || jwk.Alg != tt.algorithm || jwk.Use != "sig" {
+				t.Errorf("public key = %+v, want kid %v, kty %s, alg %s", jwk, token.Header["kid"], tt.kty, tt.algorithm)
+			}
+		})
+	}
+}
+
+func TestKeyfuncRejects(t *testing.T) {
+	m := newManager(t, AlgorithmEdDSA, 0, 0)
+	if err := m.Rotate(context.Background()); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	kid := m.PublicKeys()[0].Kid
+
+	// hmacToken is signed with the public key ID but another algorithm, as in
+	// an algorithm confusion attack.
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{})
+	hmacToken.Header["kid"] = kid
+
+	tests := []struct {
+		name    string
+		token   *jwt.Token
+		wantErr error
+	}{
+		{name: "missing kid", token: jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{}), wantErr: ErrUnknownKey},
+		{name: "unknown kid", token: &jwt.Token{Header: map[string]interface{}{"kid": "unknown"}, Method: jwt.SigningMethodEdDSA}, wantErr: ErrUnknownKey},
+		{name: "other algorithm", token: hmacToken, wantErr: ErrUnsupportedAlgorithm},
+	}
+
+	for _, tt := range tests {
+		if _, err := m.Keyfunc(tt.token); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Keyfunc error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name             string
+		rotationInterval time.Duration
+		gracePeriod      time.Duration
+		// algorithm is the algorithm of the manager that rotates the first key.
+		algorithm string
+		// wait is how long to wait before the second rotation.
+		wait        time.Duration
+		wantRotated bool
+		// wantOldValid is whether tokens of the first key still verify after the second rotation.
+		wantOldValid bool
+	}{
+		{
+			name:             "current key is kept within the rotation interval",
+			rotationInterval: time.Hour, gracePeriod: time.Hour, algorithm: AlgorithmEdDSA,
+			wantOldValid: true,
+		},
+		{
+			name:             "rotated key verifies during its grace period",
+			rotationInterval: time.Millisecond, gracePeriod: time.Hour, algorithm: AlgorithmEdDSA,
+			wait: 5 * time.Millisecond, wantRotated: true, wantOldValid: true,
+		},
+		{
+			name:             "rotated key is removed after its grace period",
+			rotationInterval: time.Millisecond, gracePeriod: 10 * time.Millisecond, algorithm: AlgorithmEdDSA,
+			wait: 5 * time.Millisecond, wantRotated: true, wantOldValid: false,
+		},
+		{
+			name:             "algorithm change rotates right away",
+			rotationInterval: time.Hour, gracePeriod: time.Hour, algorithm: AlgorithmRS256,
+			wantRotated: true, wantOldValid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := repositorytest.New()
+
+			first, err := NewManager(store, tt.algorithm, tt.rotationInterval, tt.gracePeriod, 0)
+			if err != nil {
+				t.Fatalf("NewManager: %v", err)
+			}
+			if err := first.Rotate(ctx); err != nil {
+				t.Fatalf("first Rotate: %v", err)
+			}
+			oldToken := sign(t, first)
+
+			time.Sleep(tt.wait)
+			m, err := NewManager(store, AlgorithmEdDSA, tt.rotationInterval, tt.gracePeriod, 0)
+			if err != nil {
+				t.Fatalf("NewManager: %v", err)
+			}
+			if err := m.Rotate(ctx); err != nil {
+				t.Fatalf("second Rotate: %v", err)
+			}
+			if !tt.wantOldValid {
+				// Let the grace period of the first key run out.
+				time.Sleep(tt.gracePeriod)
+				if err := m.Rotate(ctx); err != nil {
+					t.Fatalf("third Rotate: %v", err)
+				}
+			}
+
+			oldKid := first.PublicKeys()[0].Kid
+			newToken, err := parse(m, sign(t, m))
+			if err != nil {
+				t.Fatalf("parse of a new token: %v", err)
+			}
+			if rotated := newToken.Header["kid"] != oldKid; rotated != tt.wantRotated {
+				t.Errorf("new token signed with a new key = %v, want %v", rotated, tt.wantRotated)
+			}
+
+			_, err = parse(m, oldToken)
+			if valid := err == nil; valid != tt.wantOldValid {
+				t.Errorf("token of the first key valid = %v (%v), want %v", valid, err, tt.wantOldValid)
+			}
+			if !tt.wantOldValid && !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("parse of the token of a removed key error = %v, want %v", err, ErrUnknownKey)
+			}
+		})
+	}
+}
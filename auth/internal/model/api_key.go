@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey represent a long-lived key that a user creates for scripts and other
+// machine clients. Only the prefix, which identifies the key, and the hash of
+// the whole key are stored.
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	Name       string     `gorm:"type:varchar(255);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);uniqueIndex;not null" json:"prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);not null" json:"-"`
+	Scope      string     `gorm:"type:text;not null;default:''" json:"scope"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
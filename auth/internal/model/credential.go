@@ -1,12 +1,25 @@
 package model
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
+// Credential statuses. A credential stays pending until the profile of the
+// user has been created in the user service.
+const (
+	CredentialStatusPending = "pending"
+	CredentialStatusActive  = "active"
+)
+
 // Credential represent a credential record of a user.
 type Credential struct {
 	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" validate:"required"`
 	Email        string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"email" validate:"required,email"`
 	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-" validate:"required"`
+	Status       string    `gorm:"type:varchar(16);not null;default:active" json:"status"`
+
+	EmailVerified      bool       `gorm:"not null;default:false" json:"email_verified"`
+	VerificationSentAt *time.Time `json:"-"`
 }
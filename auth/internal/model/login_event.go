@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons recorded for failed logins.
+const (
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonLockedOut          = "locked_out"
+	LoginReasonAccountPending     = "account_pending"
+	LoginReasonEmailNotVerified   = "email_not_verified"
+	LoginReasonInvalidMFACode     = "invalid_mfa_code"
+)
+
+// LoginEvent is an entry of the append-only login history. UserID is nil for
+// failed logins to an email without an account. DeviceFingerprint and Network
+// identify where a login came from, so that logins from a new device or
+// network can be detected.
+type LoginEvent struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID            *uuid.UUID `gorm:"type:uuid;index:idx_login_events_user_created,priority:1" json:"user_id,omitempty"`
+	Email             string     `gorm:"type:varchar(320);not null" json:"email"`
+	Success           bool       `gorm:"not null" json:"success"`
+	Reason            string     `gorm:"type:varchar(32);not null;default:''" json:"reason,omitempty"`
+	IPAddress         string     `gorm:"type:varchar(64);not null;default:''" json:"ip_address"`
+	UserAgent         string     `gorm:"type:varchar(512);not null;default:''" json:"user_agent"`
+	DeviceFingerprint string     `gorm:"type:varchar(64);not null;default:''" json:"-"`
+	Network           string     `gorm:"type:varchar(64);not null;default:''" json:"-"`
+	NewDevice         bool       `gorm:"not null;default:false" json:"new_device"`
+	CreatedAt         time.Time  `gorm:"index:idx_login_events_user_created,priority:2;not null" json:"created_at"`
+}
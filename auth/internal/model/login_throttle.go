@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// LoginThrottle counts recent failed logins for a key, which is either an
+// email or a client IP, and the lockout they caused.
+type LoginThrottle struct {
+	Key           string     `gorm:"type:varchar(320);primaryKey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `gorm:"index" json:"locked_until,omitempty"`
+}
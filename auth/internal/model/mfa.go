@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFAFactor represent the TOTP secret of a user. MFA is enabled once the
+// factor is confirmed with a first code.
+type MFAFactor struct {
+	UserID       uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Secret       string     `gorm:"type:varchar(64);not null" json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	CreatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// MFARecoveryCode represent a hashed, single-use recovery code that replaces
+// a TOTP code when the authenticator is lost.
+type MFARecoveryCode struct {
+	ID       uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID   uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	CodeHash string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+}
+
+// MFAChallenge represent the second step of a login that passed the password
+// check. The client proves it with the hashed token and a TOTP or recovery code.
+type MFAChallenge struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Attempts  int       `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuth2 grant types a client may be allowed to use.
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// OAuthClient represent a client application registered with the
+// authorization server. Public clients, such as SPAs, have no secret and must
+// use PKCE; confidential clients authenticate with their hashed secret.
+type OAuthClient struct {
+	ID           string    `gorm:"type:varchar(64);primaryKey" json:"id"`
+	Name         string    `gorm:"type:varchar(255);not null" json:"name"`
+	SecretHash   string    `gorm:"type:varchar(64)" json:"-"`
+	RedirectURIs []string  `gorm:"type:jsonb;serializer:json;not null" json:"redirect_uris"`
+	GrantTypes   []string  `gorm:"type:jsonb;serializer:json;not null" json:"grant_types"`
+	Scope        string    `gorm:"type:text;not null" json:"scope"`
+	CreatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Confidential reports whether the client authenticates with a secret.
+func (c *OAuthClient) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// OAuthAuthorizationCode represent a hashed, single-use authorization code.
+// Tokens issued for the code share FamilyID so that they can be revoked if
+// the code is presented again.
+type OAuthAuthorizationCode struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CodeHash      string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ClientID      string     `gorm:"type:varchar(64);not null" json:"client_id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	FamilyID      uuid.UUID  `gorm:"type:uuid;not null" json:"family_id"`
+	RedirectURI   string     `gorm:"type:text;not null" json:"redirect_uri"`
+	Scope         string     `gorm:"type:text;not null" json:"scope"`
+	CodeChallenge string     `gorm:"type:varchar(128);not null" json:"-"`
+	Nonce         string     `gorm:"type:text" json:"-"`
+	ExpiresAt     time.Time  `gorm:"index;not null" json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// OAuthConsent records the scopes a user allowed a client to access, so that
+// the user is asked again only for scopes that were not granted before.
+type OAuthConsent struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	ClientID  string    `gorm:"type:varchar(64);primaryKey" json:"client_id"`
+	Scope     string    `gorm:"type:text;not null" json:"scope"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
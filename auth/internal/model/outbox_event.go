@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent represent a side effect on another service that was recorded in
+// the same transaction as the local change and is delivered asynchronously.
+type OutboxEvent struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Type          string     `gorm:"type:varchar(64);not null" json:"type"`
+	Payload       []byte     `gorm:"type:jsonb;not null" json:"payload"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index;not null" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	ProcessedAt   *time.Time `gorm:"index" json:"processed_at,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+}
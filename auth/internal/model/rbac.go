@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Built-in permissions checked by the gateway.
+const (
+	PermissionUsersList      = "users:list"
+	PermissionRolesManage    = "roles:manage"
+	PermissionAccountsUnlock = "accounts:unlock"
+	PermissionOAuthClients   = "oauth_clients:manage"
+)
+
+// RoleAdmin is the built-in role that holds every built-in permission.
+const RoleAdmin = "admin"
+
+// DefaultRoles are the roles and permissions created when the database is migrated.
+var DefaultRoles = map[string][]string{
+	RoleAdmin: {PermissionUsersList, PermissionRolesManage, PermissionAccountsUnlock, PermissionOAuthClients},
+}
+
+// Role represent a named set of permissions that can be assigned to users.
+type Role struct {
+	Name        string    `gorm:"type:varchar(64);primaryKey" json:"name"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	CreatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Permission represent an action that a role may grant, such as "users:list".
+type Permission struct {
+	Name        string `gorm:"type:varchar(64);primaryKey" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+}
+
+// RolePermission grants a permission to a role.
+type RolePermission struct {
+	RoleName       string `gorm:"type:varchar(64);primaryKey" json:"role_name"`
+	PermissionName string `gorm:"type:varchar(64);primaryKey" json:"permission_name"`
+}
+
+// UserRole assigns a role to a user.
+type UserRole struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	RoleName  string    `gorm:"type:varchar(64);primaryKey;index" json:"role_name"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
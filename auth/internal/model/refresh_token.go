@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken represent a hashed refresh token issued to a user.
+// Tokens issued from the same login share a FamilyID so that the whole chain
+// can be revoked when a rotated token is presented again.
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	FamilyID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"family_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+
+	// ClientID and Scope are set on tokens issued to OAuth clients and are
+	// carried over when the token is rotated.
+	ClientID string `gorm:"type:varchar(64)" json:"client_id,omitempty"`
+	Scope    string `gorm:"type:text" json:"scope,omitempty"`
+}
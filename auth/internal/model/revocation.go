@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokedToken represent an access token that was revoked before it expired.
+// The record only needs to be kept until ExpiresAt, after which the token is
+// rejected by its exp claim anyway. UserID is not set for client credentials
+// tokens, which have no user.
+type RevokedToken struct {
+	JTI       string     `gorm:"type:varchar(36);primaryKey" json:"jti"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	ExpiresAt time.Time  `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// UserRevocation represent a cutoff before which every access token issued to a user is revoked.
+type UserRevocation struct {
+	UserID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	RevokedBefore time.Time `gorm:"index;not null" json:"revoked_before"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session represent a first-party login of a user on a device. Its ID is the
+// family ID of the refresh tokens issued for the login, and the sid claim of
+// its access tokens. ExpiresAt follows the latest refresh token.
+type Session struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	UserAgent  string     `gorm:"type:varchar(512);not null;default:''" json:"user_agent"`
+	IPAddress  string     `gorm:"type:varchar(64);not null;default:''" json:"ip_address"`
+	LastUsedAt time.Time  `gorm:"not null" json:"last_used_at"`
+	ExpiresAt  time.Time  `gorm:"index;not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
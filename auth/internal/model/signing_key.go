@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// SigningKey represent an asymmetric key pair used to sign access tokens.
+// The newest key that has not been rotated signs new tokens; rotated keys are
+// kept until ExpiresAt so that tokens they signed can still be verified.
+type SigningKey struct {
+	ID         string     `gorm:"type:varchar(64);primaryKey" json:"kid"`
+	Algorithm  string     `gorm:"type:varchar(16);not null" json:"alg"`
+	PrivateKey []byte     `gorm:"not null" json:"-"`
+	PublicKey  []byte     `gorm:"not null" json:"-"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`
+}
@@ -0,0 +1,157 @@
+// Package notifier renders messages for users from templates and delivers
+// them in the background through a pluggable Sender.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	defaultQueueSize   = 256
+	defaultWorkers     = 2
+	defaultMaxAttempts = 5
+	defaultBaseBackoff = time.Second
+	defaultMaxBackoff  = time.Minute
+)
+
+var (
+	ErrQueueFull       = errors.New("notification queue is full")
+	ErrUnknownTemplate = errors.New("unknown notification template")
+)
+
+// Message is a notification for a single recipient. Template names the
+// message template; Data is passed to it when it is rendered.
+type Message struct {
+	To       string
+	Locale   string
+	Template string
+	Data     interface{}
+}
+
+// Email is a rendered message ready to be delivered.
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Sender delivers a rendered email. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, email *Email) error
+}
+
+// AsyncNotifier renders messages right away and delivers them from a queue,
+// retrying failed deliveries with backoff. Notify never waits for the Sender.
+type AsyncNotifier struct {
+	renderer    *Renderer
+	sender      Sender
+	queue       chan *Email
+	workers     int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewAsyncNotifier creates a new AsyncNotifier.
+func NewAsyncNotifier(renderer *Renderer, sender Sender) *AsyncNotifier {
+	return &AsyncNotifier{
+		renderer:    renderer,
+		sender:      sender,
+		queue:       make(chan *Email, defaultQueueSize),
+		workers:     defaultWorkers,
+		maxAttempts: defaultMaxAttempts,
+		baseBackoff: defaultBaseBackoff,
+		maxBackoff:  defaultMaxBackoff,
+	}
+}
+
+// Notify renders msg and queues it for delivery.
+func (n *AsyncNotifier) Notify(_ context.Context, msg Message) error {
+	email, err := n.renderer.Render(msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case n.queue <- email:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers queued messages until ctx is cancelled.
+func (n *AsyncNotifier) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for i := 0; i < n.workers; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case email := <-n.queue:
+					n.deliver(ctx, email)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n.workers; i++ {
+		<-done
+	}
+
+	if pending := len(n.queue); pending > 0 {
+		log.Printf("notifier stopped with %d undelivered messages", pending)
+	}
+}
+
+// deliver sends an email, retrying until it succeeds, the attempts run out or
+// ctx is cancelled.
+func (n *AsyncNotifier) deliver(ctx context.Context, email *Email) {
+	delay := n.baseBackoff
+	for attempt := 1; ; attempt++ {
+		err := n.sender.Send(ctx, email)
+		if err == nil {
+			return
+		}
+		if attempt == n.maxAttempts {
+			log.Printf("giving up on %q after %d attempts: %v", email.Subject, attempt, err)
+			return
+		}
+		log.Printf("failed to deliver %q, attempt %d: %v", email.Subject, attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > n.maxBackoff {
+			delay = n.maxBackoff
+		}
+	}
+}
+
+// NewSender creates the Sender for the given backend: "smtp", "file" or "memory".
+func NewSender(backend string, smtp SMTPConfig, dir string) (Sender, error) {
+	switch backend {
+	case "smtp":
+		return NewSMTPSender(smtp), nil
+	case "file":
+		return NewFileSender(dir)
+	case "", "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier backend %q", backend)
+	}
+}
@@ -0,0 +1,182 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakySender fails the first failures deliveries and records every attempt.
+type flakySender struct {
+	mu       sync.Mutex
+	failures int
+	attempts []time.Time
+	sent     []Email
+	done     chan struct{}
+}
+
+func newFlakySender(failures int) *flakySender {
+	return &flakySender{failures: failures, done: make(chan struct{}, 16)}
+}
+
+func (s *flakySender) Send(_ context.Context, email *Email) error {
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		s.done <- struct{}{}
+	}()
+
+	s.attempts = append(s.attempts, time.Now())
+	if len(s.attempts) <= s.failures {
+		return errors.New("connection refused")
+	}
+	s.sent = append(s.sent, *email)
+	return nil
+}
+
+func (s *flakySender) result() ([]time.Time, []Email) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.attempts...), append([]Email(nil), s.sent...)
+}
+
+func newTestNotifier(t *testing.T, sender Sender) *AsyncNotifier {
+	t.Helper()
+
+	renderer, err := NewRenderer(DefaultLocale)
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	n := NewAsyncNotifier(renderer, sender)
+	n.baseBackoff = 5 * time.Millisecond
+	n.maxBackoff = 10 * time.Millisecond
+	return n
+}
+
+var resetMessage = Message{
+	To:       "alice@example.com",
+	Template: TemplatePasswordReset,
+	Data:     PasswordResetData{URL: "https://example.com/reset?token=abc", ExpiresAt: time.Now().Add(time.Hour)},
+}
+
+func TestAsyncNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		wantAttempts int
+		wantSent     bool
+		// wantDelays are the least waits before each retry.
+		wantDelays []time.Duration
+	}{
+		{name: "delivered", wantAttempts: 1, wantSent: true},
+		{name: "delivered after retries", failures: 2, wantAttempts: 3, wantSent: true, wantDelays: []time.Duration{5 * time.Millisecond, 10 * time.Millisecond}},
+		{
+			name:     "attempts run out",
+			failures: defaultMaxAttempts, wantAttempts: defaultMaxAttempts,
+			wantDelays: []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := newFlakySender(tt.failures)
+			n := newTestNotifier(t, sender)
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+			go func() {
+				n.Run(ctx)
+				close(stopped)
+			}()
+
+			if err := n.Notify(ctx, resetMessage); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			for i := 0; i < tt.wantAttempts; i++ {
+				select {
+				case <-sender.done:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("got %d delivery attempts, want %d", i, tt.wantAttempts)
+				}
+			}
+			// No attempt follows the last one.
+			select {
+			case <-sender.done:
+				t.Fatalf("got more than %d delivery attempts", tt.wantAttempts)
+			case <-time.After(30 * time.Millisecond):
+			}
+			cancel()
+			<-stopped
+
+			attempts, sent := sender.result()
+			if (len(sent) == 1) != tt.wantSent || len(sent) > 1 {
+				t.Errorf("sent %d emails, want one %v", len(sent), tt.wantSent)
+			}
+			if tt.wantSent && (sent[0].To != resetMessage.To || sent[0].Subject == "") {
+				t.Errorf("sent %+v, want the rendered message to %s", sent[0], resetMessage.To)
+			}
+			for i, want := range tt.wantDelays {
+				if got := attempts[i+1].Sub(attempts[i]); got < want {
+					t.Errorf("retry %d after %v, want at least %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAsyncNotifierStopsRetryingOnCancel(t *testing.T) {
+	sender := newFlakySender(defaultMaxAttempts)
+	n := newTestNotifier(t, sender)
+	n.baseBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(stopped)
+	}()
+	if err := n.Notify(ctx, resetMessage); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	<-sender.done
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return while waiting to retry")
+	}
+	if attempts, _ := sender.result(); len(attempts) != 1 {
+		t.Errorf("got %d delivery attempts, want 1", len(attempts))
+	}
+}
+
+func TestAsyncNotifierNotify(t *testing.T) {
+	tests := []struct {
+		name    string
+		queued  int
+		msg     Message
+		wantErr error
+	}{
+		{name: "queued", msg: resetMessage},
+		{name: "queue full", queued: defaultQueueSize, msg: resetMessage, wantErr: ErrQueueFull},
+		{name: "unknown template", msg: Message{To: "alice@example.com", Template: "welcome"}, wantErr: ErrUnknownTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The notifier is not running, so queued messages stay queued.
+			n := newTestNotifier(t, NewMemorySender())
+			for i := 0; i < tt.queued; i++ {
+				if err := n.Notify(context.Background(), resetMessage); err != nil {
+					t.Fatalf("Notify %d: %v", i, err)
+				}
+			}
+
+			if err := n.Notify(context.Background(), tt.msg); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Notify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
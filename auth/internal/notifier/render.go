@@ -0,0 +1,146 @@
+package notifier
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Template names.
+const (
+	TemplatePasswordReset = "password_reset"
+	TemplateVerifyEmail   = "verify_email"
+	TemplateNewLogin      = "new_login"
+)
+
+// PasswordResetData is the data of TemplatePasswordReset.
+type PasswordResetData struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// VerifyEmailData is the data of TemplateVerifyEmail.
+type VerifyEmailData struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// NewLoginData is the data of TemplateNewLogin.
+type NewLoginData struct {
+	Time      time.Time
+	IPAddress string
+	UserAgent string
+}
+
+// DefaultLocale is used when a message has no locale or its locale has no template.
+const DefaultLocale = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+// template is a message template in one locale. The text template defines
+// "subject" and "body"; the HTML template is the HTML body.
+type template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Renderer renders messages from the embedded templates. Templates live in
+// templates/<locale>/<name>.txt and templates/<locale>/<name>.html.
+type Renderer struct {
+	defaultLocale string
+	templates     map[string]map[string]*template
+}
+
+// NewRenderer parses the embedded templates. Messages in a locale without a
+// template fall back to defaultLocale.
+func NewRenderer(defaultLocale string) (*Renderer, error) {
+	if defaultLocale == "" {
+		defaultLocale = DefaultLocale
+	}
+
+	r := &Renderer{
+		defaultLocale: defaultLocale,
+		templates:     map[string]map[string]*template{},
+	}
+
+	locales, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, locale := range locales {
+		dir := path.Join("templates", locale.Name())
+		files, err := fs.Glob(templateFS, path.Join(dir, "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+
+		r.templates[locale.Name()] = map[string]*template{}
+		for _, file := range files {
+			name := strings.TrimSuffix(path.Base(file), ".txt")
+
+			text, err := texttemplate.ParseFS(templateFS, file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			html, err := htmltemplate.ParseFS(templateFS, path.Join(dir, name+".html"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s HTML template: %w", file, err)
+			}
+
+			r.templates[locale.Name()][name] = &template{text: text, html: html}
+		}
+	}
+
+	if _, ok := r.templates[defaultLocale]; !ok {
+		return nil, fmt.Errorf("no templates for default locale %q", defaultLocale)
+	}
+
+	return r, nil
+}
+
+// Render renders msg into an email.
+func (r *Renderer) Render(msg Message) (*Email, error) {
+	t := r.lookup(msg.Locale, msg.Template)
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, msg.Template)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", msg.Data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", msg.Template, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "body", msg.Data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text body: %w", msg.Template, err)
+	}
+	if err := t.html.Execute(&html, msg.Data); err != nil {
+		return nil, fmt.Errorf("failed to render %s HTML body: %w", msg.Template, err)
+	}
+
+	return &Email{
+		To:      msg.To,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// lookup finds a template in the locale of a message, its base language, or
+// the default locale, in that order.
+func (r *Renderer) lookup(locale, name string) *template {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	base, _, _ := strings.Cut(locale, "-")
+
+	for _, l := range []string{locale, base, r.defaultLocale} {
+		if t, ok := r.templates[l][name]; ok {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// templateData holds data for every template, with a value that must be escaped in HTML.
+var templateData = map[string]interface{}{
+	TemplatePasswordReset: PasswordResetData{URL: "https://example.com/reset?token=a&b", ExpiresAt: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)},
+	TemplateVerifyEmail:   VerifyEmailData{URL: "https://example.com/verify?token=a&b", ExpiresAt: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)},
+	TemplateNewLogin:      NewLoginData{Time: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC), IPAddress: "203.0.113.7", UserAgent: "a&b <script>"},
+}
+
+func TestRender(t *testing.T) {
+	r, err := NewRenderer(DefaultLocale)
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		locale   string
+		template string
+		// wantLocale is the locale of the template that should be used.
+		wantLocale string
+	}{
+		{name: "default locale", template: TemplatePasswordReset, wantLocale: "en"},
+		{name: "English", locale: "en", template: TemplatePasswordReset, wantLocale: "en"},
+		{name: "Thai", locale: "th", template: TemplatePasswordReset, wantLocale: "th"},
+		{name: "regional Thai", locale: "th-TH", template: TemplatePasswordReset, wantLocale: "th"},
+		{name: "regional Thai with underscore and case", locale: "TH_th", template: TemplatePasswordReset, wantLocale: "th"},
+		{name: "locale without templates", locale: "fr-FR", template: TemplatePasswordReset, wantLocale: "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, err := r.Render(Message{To: "alice@example.com", Locale: tt.locale, Template: tt.template, Data: templateData[tt.template]})
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			want, err := r.Render(Message{To: "alice@example.com", Locale: tt.wantLocale, Template: tt.template, Data: templateData[tt.template]})
+			if err != nil {
+				t.Fatalf("Render %s: %v", tt.wantLocale, err)
+			}
+			if *email != *want {
+				t.Errorf("Render in %q = %+v, want the %s template", tt.locale, email, tt.wantLocale)
+			}
+		})
+	}
+}
+
+func TestRenderTemplates(t *testing.T) {
+	r, err := NewRenderer(DefaultLocale)
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+
+	locales, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, locale := range locales {
+		for name, data := range templateData {
+			t.Run(path.Join(locale.Name(), name), func(t *testing.T) {
+				if _, ok := r.templates[locale.Name()][name]; !ok {
+					t.Fatalf("no %s template in %s", name, locale.Name())
+				}
+
+				email, err := r.Render(Message{To: "alice@example.com", Locale: locale.Name(), Template: name, Data: data})
+				if err != nil {
+					t.Fatalf("Render: %v", err)
+				}
+				if email.To != "alice@example.com" || email.Subject == "" || strings.Contains(email.Subject, "\n") {
+					t.Errorf("email = %+v, want a one-line subject to alice@example.com", email)
+				}
+				if strings.Contains(email.Text, "&amp;") || !strings.HasSuffix(email.Text, "\n") {
+					t.Errorf("text body %q, want unescaped text ending in a newline", email.Text)
+				}
+				if strings.Contains(email.HTML, "a&b") {
+					t.Errorf("HTML body %q, want the data escaped", email.HTML)
+				}
+			})
+		}
+	}
+
+	for name := range r.templates[DefaultLocale] {
+		if _, ok := templateData[name]; !ok {
+			t.Errorf("no test data for template %s", name)
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	r, err := NewRenderer(DefaultLocale)
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr error
+	}{
+		{name: "unknown template", msg: Message{Template: "welcome"}, wantErr: ErrUnknownTemplate},
+		{name: "data of another template", msg: Message{Template: TemplatePasswordReset, Data: struct{ Name string }{"Alice"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.Render(tt.msg)
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Render error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewRenderer(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultLocale string
+		wantErr       bool
+	}{
+		{name: "built-in default", defaultLocale: ""},
+		{name: "Thai", defaultLocale: "th"},
+		{name: "locale without templates", defaultLocale: "fr", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRenderer(tt.defaultLocale); (err != nil) != tt.wantErr {
+				t.Errorf("NewRenderer error = %v, want one %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package notifier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileSenderFrom is the sender address written by FileSender.
+const fileSenderFrom = "no-reply@localhost"
+
+// FileSender writes every email to a .eml file in a directory. It is meant
+// for development, where the files can be opened with any mail client.
+type FileSender struct {
+	dir string
+}
+
+// NewFileSender creates a new FileSender, creating dir if needed.
+func NewFileSender(dir string) (*FileSender, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("notifier file directory is not configured")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create notifier directory: %w", err)
+	}
+	return &FileSender{dir: dir}, nil
+}
+
+// Send writes an email to a new file.
+func (s *FileSender) Send(_ context.Context, email *Email) error {
+	now := time.Now()
+
+	msg, err := buildMIME(fileSenderFrom, email, now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	return os.WriteFile(filepath.Join(s.dir, name), msg, 0o640)
+}
+
+// MemorySender keeps every email in memory. It is meant for tests and local runs.
+type MemorySender struct {
+	mu     sync.Mutex
+	emails []Email
+}
+
+// NewMemorySender creates a new MemorySender.
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// Send records an email.
+func (s *MemorySender) Send(_ context.Context, email *Email) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.emails = append(s.emails, *email)
+	return nil
+}
+
+// Emails returns the emails sent so far.
+func (s *MemorySender) Emails() []Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Email(nil), s.emails...)
+}
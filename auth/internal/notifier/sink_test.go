@@ -0,0 +1,239 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testEmail = &Email{
+	To:      "alice@example.com",
+	Subject: "รีเซ็ตรหัสผ่าน",
+	Text:    "Open https://example.com/reset?token=abc\n",
+	HTML:    `<p><a href="https://example.com/reset?token=abc">Reset</a></p>`,
+}
+
+// checkMIME parses a message written by buildMIME and checks that it carries testEmail.
+func checkMIME(t *testing.T, raw []byte, from string) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("DecodeHeader: %v", err)
+	}
+	if msg.Header.Get("From") != from || msg.Header.Get("To") != testEmail.To || subject != testEmail.Subject {
+		t.Errorf("headers = %v, want from %s to %s about %q", msg.Header, from, testEmail.To, testEmail.Subject)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date header: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v, want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		// multipart.Reader decodes quoted-printable parts, which use CRLF line breaks.
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		bodies = append(bodies, strings.ReplaceAll(string(body), "\r\n", "\n"))
+	}
+	if len(bodies) != 2 || bodies[0] != testEmail.Text || bodies[1] != testEmail.HTML {
+		t.Errorf("parts = %q, want the text and HTML bodies", bodies)
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	s, err := NewFileSender(dir)
+	if err != nil {
+		t.Fatalf("NewFileSender: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Send(context.Background(), testEmail); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("wrote %d files, want one per email", len(files))
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	checkMIME(t, raw, fileSenderFrom)
+}
+
+func TestNewSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		dir     string
+		want    Sender
+		wantErr bool
+	}{
+		{name: "default", want: &MemorySender{}},
+		{name: "memory", backend: "memory", want: &MemorySender{}},
+		{name: "file", backend: "file", dir: "mail", want: &FileSender{}},
+		{name: "file without a directory", backend: "file", wantErr: true},
+		{name: "smtp", backend: "smtp", want: &SMTPSender{}},
+		{name: "unknown", backend: "carrier-pigeon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tt.dir
+			if dir != "" {
+				dir = filepath.Join(t.TempDir(), dir)
+			}
+
+			got, err := NewSender(tt.backend, SMTPConfig{}, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSender error = %v, want one %v", err, tt.wantErr)
+			}
+			if err == nil && fmt.Sprintf("%T", got) != fmt.Sprintf("%T", tt.want) {
+				t.Errorf("NewSender = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemorySender(t *testing.T) {
+	s := NewMemorySender()
+	if err := s.Send(context.Background(), testEmail); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	emails := s.Emails()
+	emails[0].To = "mallory@example.com"
+	if got := s.Emails(); len(got) != 1 || got[0] != *testEmail {
+		t.Errorf("Emails = %+v, want a copy of the sent email", got)
+	}
+}
+
+// serveSMTP accepts one SMTP session on l, replying with code to the
+// envelope commands, and sends the data of the message on the returned channel.
+func serveSMTP(t *testing.T, l net.Listener, code string) <-chan string {
+	t.Helper()
+
+	data := make(chan string, 1)
+	go func() {
+		defer close(data)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"):
+				reply(code + " envelope")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					b.WriteString(line)
+				}
+				data <- b.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return data
+}
+
+func TestSMTPSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		cancel  bool
+		wantErr bool
+	}{
+		{name: "delivered", code: "250"},
+		{name: "rejected", code: "550", wantErr: true},
+		{name: "cancelled", code: "250", cancel: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen: %v", err)
+			}
+			defer l.Close()
+			data := serveSMTP(t, l, tt.code)
+
+			host, port, _ := net.SplitHostPort(l.Addr().String())
+			s := NewSMTPSender(SMTPConfig{Host: host, Port: port, From: "no-reply@example.com"})
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			err = s.Send(ctx, testEmail)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send error = %v, want one %v", err, tt.wantErr)
+			}
+			l.Close()
+			raw, received := <-data
+			if received == tt.wantErr {
+				t.Fatalf("server received a message = %v, want %v", received, !tt.wantErr)
+			}
+			if received {
+				checkMIME(t, []byte(raw), "no-reply@example.com")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig holds the settings of an SMTP server.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender delivers emails through an SMTP server.
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender creates a new SMTPSender.
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	return &SMTPSender{config: config}
+}
+
+// Send delivers an email. smtp.SendMail cannot be cancelled, so ctx is only
+// checked before the delivery starts.
+func (s *SMTPSender) Send(ctx context.Context, email *Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg, err := buildMIME(s.config.From, email, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(s.config.Host, s.config.Port), auth, s.config.From, []string{email.To}, msg)
+}
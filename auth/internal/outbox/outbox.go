@@ -0,0 +1,196 @@
+// Package outbox delivers side effects on other services that were recorded
+// in the database together with the local change that caused them.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	batchSize   = 20
+	lease       = time.Minute
+	baseBackoff = 2 * time.Second
+	maxBackoff  = 10 * time.Minute
+	// retention is how long delivered events are kept before they are deleted.
+	retention = 7 * 24 * time.Hour
+)
+
+// Handler delivers the payload of an event. Returning an error wrapped with
+// Permanent stops further attempts; any other error is retried with backoff.
+type Handler func(ctx context.Context, payload []byte) error
+
+// permanentError marks an error that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not retryable.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked as not retryable.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// NewEvent creates an outbox event of the given type with a JSON encoded payload.
+func NewEvent(eventType string, payload interface{}) (*model.OutboxEvent, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+
+	return &model.OutboxEvent{
+		Type:          eventType,
+		Payload:       b,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+// Dispatcher polls the outbox and delivers due events to their handlers.
+type Dispatcher struct {
+	repository repository.Repository
+	handlers   map[string]Handler
+	wake       chan struct{}
+}
+
+// NewDispatcher creates a new Dispatcher.
+func NewDispatcher(repository repository.Repository) *Dispatcher {
+	return &Dispatcher{
+		repository: repository,
+		handlers:   map[string]Handler{},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the handler of an event type. It must be called before Run.
+func (d *Dispatcher) Handle(eventType string, handler Handler) {
+	d.handlers[eventType] = handler
+}
+
+// Wake asks the dispatcher to poll right away instead of waiting for the next tick.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due events every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DispatchDue(ctx); err != nil {
+			log.Printf("outbox dispatch error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DispatchDue delivers every event that is due.
+func (d *Dispatcher) DispatchDue(ctx context.Context) error {
+	for {
+		events, err := d.repository.ClaimOutboxEvents(ctx, time.Now(), batchSize, lease)
+		if err != nil {
+			return fmt.Errorf("failed to claim outbox events: %w", err)
+		}
+
+		for i := range events {
+			if err := d.dispatch(ctx, &events[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < batchSize {
+			return nil
+		}
+	}
+}
+
+// DispatchEvent delivers a single event right away and returns the handler
+// error. An event that failed with a transient error stays scheduled for retry.
+func (d *Dispatcher) DispatchEvent(ctx context.Context, id uuid.UUID) error {
+	event, err := d.repository.ClaimOutboxEvent(ctx, id, time.Now(), lease)
+	if err != nil {
+		return fmt.Errorf("failed to claim outbox event: %w", err)
+	}
+	if event == nil {
+		return nil
+	}
+
+	handlerErr := d.handle(ctx, event)
+	if err := d.record(ctx, event, handlerErr); err != nil {
+		return fmt.Errorf("failed to record outbox event outcome: %w", err)
+	}
+
+	return handlerErr
+}
+
+// Prune deletes events that were delivered longer ago than the retention period.
+func (d *Dispatcher) Prune(ctx context.Context) error {
+	return d.repository.DeleteProcessedOutboxEvents(ctx, time.Now().Add(-retention))
+}
+
+// dispatch delivers a single event and records the outcome.
+func (d *Dispatcher) dispatch(ctx context.Context, event *model.OutboxEvent) error {
+	return d.record(ctx, event, d.handle(ctx, event))
+}
+
+// handle runs the handler registered for the type of an event.
+func (d *Dispatcher) handle(ctx context.Context, event *model.OutboxEvent) error {
+	handler, ok := d.handlers[event.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for event type %q", event.Type))
+	}
+
+	return handler(ctx, event.Payload)
+}
+
+// record stores the outcome of a delivery attempt.
+func (d *Dispatcher) record(ctx context.Context, event *model.OutboxEvent, err error) error {
+	if err == nil {
+		return d.repository.CompleteOutboxEvent(ctx, event.ID)
+	}
+
+	if IsPermanent(err) {
+		log.Printf("outbox event %s (%s) failed permanently: %v", event.ID, event.Type, err)
+		return d.repository.FailOutboxEvent(ctx, event.ID, err.Error())
+	}
+
+	log.Printf("outbox event %s (%s) failed, attempt %d: %v", event.ID, event.Type, event.Attempts+1, err)
+	return d.repository.RetryOutboxEvent(ctx, event.ID, err.Error(), time.Now().Add(backoff(event.Attempts)))
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 0; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,114 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Event types handled by the user service handlers.
+const (
+	EventCreateUserProfile = "user_profile.create"
+	EventDeleteUserProfile = "user_profile.delete"
+)
+
+// callTimeout bounds a single delivery attempt to the user service.
+const callTimeout = 5 * time.Second
+
+// CreateUserProfilePayload is the payload of EventCreateUserProfile.
+type CreateUserProfilePayload struct {
+	UserID   string `json:"user_id"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+}
+
+// DeleteUserProfilePayload is the payload of EventDeleteUserProfile.
+type DeleteUserProfilePayload struct {
+	UserID string `json:"user_id"`
+}
+
+// DeleteUserProfileHandler deletes the profile of a user from the user service.
+// A profile that is already gone counts as delivered.
+func DeleteUserProfileHandler(client userPB.UserServiceClient) Handler {
+	return func(ctx context.Context, payload []byte) error {
+		var p DeleteUserProfilePayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return Permanent(err)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, callTimeout)
+		defer cancel()
+
+		_, err := client.DeleteUser(ctx, &userPB.DeleteUserRequest{UserId: p.UserID})
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		return classify(err)
+	}
+}
+
+// CreateUserProfileHandler runs the remaining steps of the registration saga:
+// it creates the profile of a pending credential in the user service and then
+// activates the credential. If the user service rejects the profile for good,
+// the credential is deleted as compensation. Every step is idempotent, so the
+// event can be redelivered after a crash at any point.
+func CreateUserProfileHandler(client userPB.UserServiceClient, r repository.Repository) Handler {
+	return func(ctx context.Context, payload []byte) error {
+		var p CreateUserProfilePayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return Permanent(err)
+		}
+
+		userID, err := uuid.Parse(p.UserID)
+		if err != nil {
+			return Permanent(err)
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		_, err = client.CreateUser(callCtx, &userPB.CreateUserRequest{
+			UserId:   p.UserID,
+			Email:    p.Email,
+			FullName: p.FullName,
+		})
+		cancel()
+
+		if err = classify(err); err != nil {
+			if !IsPermanent(err) {
+				return err
+			}
+			// Compensate; a failure here is retried like any other step.
+			if compErr := r.DeleteByID(ctx, p.UserID); compErr != nil && !errors.Is(compErr, repository.ErrRecordNotFound) {
+				return fmt.Errorf("failed to delete credential after %v: %w", err, compErr)
+			}
+			return err
+		}
+
+		if err := r.UpdateStatus(ctx, userID, model.CredentialStatusActive); err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+			return fmt.Errorf("failed to activate credential: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// classify marks errors that retrying cannot fix as permanent.
+func classify(err error) error {
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.PermissionDenied,
+		codes.Unauthenticated, codes.Unimplemented, codes.AlreadyExists:
+		return Permanent(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,206 @@
+// Package password hashes passwords into self-describing strings, so that the
+// algorithm and its parameters can change without invalidating stored hashes.
+//
+// Argon2id hashes use the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// bcrypt hashes use their usual modular crypt format ($2a$10$...), which
+// already names the algorithm and cost.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	defaultArgon2Memory      = 64 * 1024
+	defaultArgon2Iterations  = 3
+	defaultArgon2Parallelism = 2
+	argon2SaltLength         = 16
+	argon2KeyLength          = 32
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported password hash algorithm")
+	ErrInvalidHash          = errors.New("invalid password hash")
+)
+
+// Argon2Params are the cost parameters of argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Config selects the algorithm used for new hashes and its parameters.
+type Config struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// Hasher hashes and verifies passwords.
+type Hasher interface {
+	// Hash returns the encoded hash of a password.
+	Hash(password string) (string, error)
+	// Verify reports whether a password matches an encoded hash of any supported algorithm.
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether an encoded hash uses another algorithm or
+	// weaker parameters than new hashes do.
+	NeedsRehash(encoded string) bool
+}
+
+// hasher is the Hasher for a Config.
+type hasher struct {
+	config Config
+}
+
+// NewHasher creates a Hasher. Zero parameters are replaced by defaults.
+func NewHasher(config Config) (Hasher, error) {
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmArgon2id
+	}
+	if config.Algorithm != AlgorithmArgon2id && config.Algorithm != AlgorithmBcrypt {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, config.Algorithm)
+	}
+	if config.Argon2.Memory == 0 {
+		config.Argon2.Memory = defaultArgon2Memory
+	}
+	if config.Argon2.Iterations == 0 {
+		config.Argon2.Iterations = defaultArgon2Iterations
+	}
+	if config.Argon2.Parallelism == 0 {
+		config.Argon2.Parallelism = defaultArgon2Parallelism
+	}
+	if config.BcryptCost == 0 {
+		config.BcryptCost = bcrypt.DefaultCost
+	}
+	if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost %d is out of range", config.BcryptCost)
+	}
+
+	return &hasher{config: config}, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	if h.config.Algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.config.Argon2
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *hasher) Verify(password, encoded string) (bool, error) {
+	if isBcrypt(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	hash, err := parseArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), hash.salt, hash.params.Iterations, hash.params.Memory, hash.params.Parallelism, uint32(len(hash.key)))
+	return subtle.ConstantTimeCompare(key, hash.key) == 1, nil
+}
+
+func (h *hasher) NeedsRehash(encoded string) bool {
+	if isBcrypt(encoded) {
+		if h.config.Algorithm != AlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost < h.config.BcryptCost
+	}
+
+	hash, err := parseArgon2(encoded)
+	if err != nil || h.config.Algorithm != AlgorithmArgon2id {
+		return true
+	}
+
+	p := h.config.Argon2
+	return hash.params.Memory < p.Memory ||
+		hash.params.Iterations < p.Iterations ||
+		hash.params.Parallelism < p.Parallelism ||
+		len(hash.key) < argon2KeyLength
+}
+
+// argon2Hash is a decoded argon2id PHC string.
+type argon2Hash struct {
+	params Argon2Params
+	salt   []byte
+	key    []byte
+}
+
+// parseArgon2 decodes an argon2id PHC string.
+func parseArgon2(encoded string) (*argon2Hash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, ErrInvalidHash
+	}
+	if parts[1] != AlgorithmArgon2id {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, parts[1])
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrInvalidHash
+	}
+
+	var hash argon2Hash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.params.Memory, &hash.params.Iterations, &hash.params.Parallelism); err != nil {
+		return nil, ErrInvalidHash
+	}
+
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(hash.key) == 0 {
+		return nil, ErrInvalidHash
+	}
+
+	return &hash, nil
+}
+
+// isBcrypt reports whether an encoded hash is a bcrypt hash.
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
@@ -0,0 +1,189 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2 are argon2id parameters cheap enough for tests.
+var testArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func newHasher(t *testing.T, config Config) Hasher {
+	t.Helper()
+
+	h, err := NewHasher(config)
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+	return h
+}
+
+func hash(t *testing.T, h Hasher, password string) string {
+	t.Helper()
+
+	encoded, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	return encoded
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "argon2id", config: Config{Algorithm: AlgorithmArgon2id, Argon2: testArgon2}},
+		{name: "bcrypt", config: Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}},
+		{name: "unsupported algorithm", config: Config{Algorithm: "md5"}, wantErr: true},
+		{name: "bcrypt cost too low", config: Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost - 1}, wantErr: true},
+		{name: "bcrypt cost too high", config: Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost + 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewHasher(tt.config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: NewHasher error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		wantPrefix string
+	}{
+		{name: "argon2id", config: Config{Argon2: testArgon2}, wantPrefix: "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{name: "bcrypt", config: Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, wantPrefix: "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHasher(t, tt.config)
+
+			encoded := hash(t, h, "correct-horse-battery")
+			if !strings.HasPrefix(encoded, tt.wantPrefix) {
+				t.Errorf("Hash = %q, want prefix %q", encoded, tt.wantPrefix)
+			}
+			if other := hash(t, h, "correct-horse-battery"); other == encoded {
+				t.Error("two hashes of the same password are equal, want distinct salts")
+			}
+
+			for password, want := range map[string]bool{
+				"correct-horse-battery":  true,
+				"correct-horse-battery ": false,
+				"":                       false,
+			} {
+				ok, err := h.Verify(password, encoded)
+				if err != nil || ok != want {
+					t.Errorf("Verify(%q) = %v, %v, want %v", password, ok, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAcrossAlgorithms(t *testing.T) {
+	argon2Hasher := newHasher(t, Config{Argon2: testArgon2})
+	bcryptHasher := newHasher(t, Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
+	// Stored hashes keep verifying after the configured algorithm changes.
+	if ok, err := argon2Hasher.Verify("secret", hash(t, bcryptHasher, "secret")); !ok || err != nil {
+		t.Errorf("argon2id hasher Verify of a bcrypt hash = %v, %v", ok, err)
+	}
+	if ok, err := bcryptHasher.Verify("secret", hash(t, argon2Hasher, "secret")); !ok || err != nil {
+		t.Errorf("bcrypt hasher Verify of an argon2id hash = %v, %v", ok, err)
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	h := newHasher(t, Config{Argon2: testArgon2})
+	valid := strings.Split(hash(t, h, "secret"), "$")
+
+	// withPart returns the valid hash with part i replaced.
+	withPart := func(i int, part string) string {
+		parts := append([]string(nil), valid...)
+		parts[i] = part
+		return strings.Join(parts, "$")
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr error
+	}{
+		{name: "empty", encoded: "", wantErr: ErrInvalidHash},
+		{name: "plain text", encoded: "secret", wantErr: ErrInvalidHash},
+		{name: "missing key", encoded: strings.Join(valid[:5], "$"), wantErr: ErrInvalidHash},
+		{name: "unknown algorithm", encoded: withPart(1, "scrypt"), wantErr: ErrUnsupportedAlgorithm},
+		{name: "argon2i", encoded: withPart(1, "argon2i"), wantErr: ErrUnsupportedAlgorithm},
+		{name: "other version", encoded: withPart(2, "v=16"), wantErr: ErrInvalidHash},
+		{name: "malformed parameters", encoded: withPart(3, "m=1024,t=1"), wantErr: ErrInvalidHash},
+		{name: "malformed salt", encoded: withPart(4, "not base64!"), wantErr: ErrInvalidHash},
+		{name: "malformed key", encoded: withPart(5, "not base64!"), wantErr: ErrInvalidHash},
+		{name: "empty key", encoded: withPart(5, ""), wantErr: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		ok, err := h.Verify("secret", tt.encoded)
+		if ok || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Verify = %v, %v, want false, %v", tt.name, ok, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2Hasher := newHasher(t, Config{Argon2: testArgon2})
+	bcryptHasher := newHasher(t, Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
+	argon2Hash := hash(t, argon2Hasher, "secret")
+	bcryptHash := hash(t, bcryptHasher, "secret")
+	weakArgon2Hash := hash(t, newHasher(t, Config{Argon2: Argon2Params{Memory: 512, Iterations: 1, Parallelism: 1}}), "secret")
+
+	tests := []struct {
+		name    string
+		config  Config
+		encoded string
+		want    bool
+	}{
+		{name: "current argon2id hash", config: Config{Argon2: testArgon2}, encoded: argon2Hash, want: false},
+		{name: "weaker argon2id hash", config: Config{Argon2: testArgon2}, encoded: weakArgon2Hash, want: true},
+		{
+			name:    "argon2id hash with more memory",
+			config:  Config{Argon2: Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1}},
+			encoded: argon2Hash, want: true,
+		},
+		{
+			name:    "argon2id hash with more iterations",
+			config:  Config{Argon2: Argon2Params{Memory: 1024, Iterations: 2, Parallelism: 1}},
+			encoded: argon2Hash, want: true,
+		},
+		{
+			name:    "argon2id hash with more parallelism",
+			config:  Config{Argon2: Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 2}},
+			encoded: argon2Hash, want: true,
+		},
+		{
+			name:    "stronger argon2id hash",
+			config:  Config{Argon2: Argon2Params{Memory: 512, Iterations: 1, Parallelism: 1}},
+			encoded: argon2Hash, want: false,
+		},
+		{name: "bcrypt hash under argon2id", config: Config{Argon2: testArgon2}, encoded: bcryptHash, want: true},
+		{name: "argon2id hash under bcrypt", config: Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, encoded: argon2Hash, want: true},
+		{name: "current bcrypt hash", config: Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, encoded: bcryptHash, want: false},
+		{name: "cheaper bcrypt hash", config: Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}, encoded: bcryptHash, want: true},
+		{name: "invalid hash", config: Config{Argon2: testArgon2}, encoded: "secret", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := newHasher(t, tt.config).NeedsRehash(tt.encoded); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMinLength = 8
+	defaultMaxLength = 128
+	// bcryptMaxLength is the longest password bcrypt can hash.
+	bcryptMaxLength = 72
+	// rangePrefixLength is the length of the SHA-1 prefix that breached hashes are grouped by.
+	rangePrefixLength = 5
+	// minEmailPartLength is the shortest local part of an email that a password may not contain.
+	minEmailPartLength = 3
+)
+
+// PolicyConfig configures a Policy.
+type PolicyConfig struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// BreachedHashesFile is a file of SHA-1 hashes of breached passwords, one
+	// upper or lower case hex hash per line, optionally followed by ":count"
+	// as in the Have I Been Pwned downloads. Empty disables the check.
+	BreachedHashesFile string
+}
+
+// Violation is a rule of the policy that a password breaks.
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// Policy checks new passwords against configurable rules.
+type Policy struct {
+	config   PolicyConfig
+	breached breachedHashes
+}
+
+// NewPolicy creates a Policy for passwords hashed with the given algorithm.
+// Zero lengths are replaced by defaults, and the maximum length never exceeds
+// what the algorithm can hash.
+func NewPolicy(config PolicyConfig, algorithm string) (*Policy, error) {
+	if config.MinLength <= 0 {
+		config.MinLength = defaultMinLength
+	}
+	if config.MaxLength <= 0 {
+		config.MaxLength = defaultMaxLength
+	}
+	if algorithm == AlgorithmBcrypt && config.MaxLength > bcryptMaxLength {
+		config.MaxLength = bcryptMaxLength
+	}
+	if config.MinLength > config.MaxLength {
+		return nil, fmt.Errorf("password min length %d exceeds max length %d", config.MinLength, config.MaxLength)
+	}
+
+	p := &Policy{config: config}
+	if config.BreachedHashesFile != "" {
+		breached, err := loadBreachedHashes(config.BreachedHashesFile)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = breached
+	}
+
+	return p, nil
+}
+
+// Validate returns every rule that a new password for the account with the
+// given email breaks.
+func (p *Policy) Validate(password, email string) []Violation {
+	var violations []Violation
+	add := func(description string, args ...interface{}) {
+		violations = append(violations, Violation{Field: "password", Description: fmt.Sprintf(description, args...)})
+	}
+
+	if n := utf8.RuneCountInString(password); n < p.config.MinLength {
+		add("must be at least %d characters long", p.config.MinLength)
+	}
+	if len(password) > p.config.MaxLength {
+		add("must be at most %d bytes long", p.config.MaxLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.config.RequireUpper && !upper {
+		add("must contain an uppercase letter")
+	}
+	if p.config.RequireLower && !lower {
+		add("must contain a lowercase letter")
+	}
+	if p.config.RequireDigit && !digit {
+		add("must contain a digit")
+	}
+	if p.config.RequireSymbol && !symbol {
+		add("must contain a symbol")
+	}
+
+	if containsEmail(password, email) {
+		add("must not contain the email address")
+	}
+
+	if p.breached.contains(password) {
+		add("appears in a list of breached passwords")
+	}
+
+	return violations
+}
+
+// containsEmail reports whether a password contains the email address or its local part.
+func containsEmail(password, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	return strings.Contains(password, email) ||
+		(len(local) >= minEmailPartLength && strings.Contains(password, local))
+}
+
+// breachedHashes holds the SHA-1 hashes of breached passwords grouped by
+// their first five hex digits, the same split the k-anonymity range API of
+// Have I Been Pwned uses, so that a lookup only touches one range.
+type breachedHashes map[string]map[string]struct{}
+
+// loadBreachedHashes reads a file of breached password hashes.
+func loadBreachedHashes(path string) (breachedHashes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password file: %w", err)
+	}
+	defer f.Close()
+
+	hashes := breachedHashes{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid hash on line %d of %s", line, path)
+		}
+		hash = strings.ToUpper(hash)
+
+		prefix, suffix := hash[:rangePrefixLength], hash[rangePrefixLength:]
+		if hashes[prefix] == nil {
+			hashes[prefix] = map[string]struct{}{}
+		}
+		hashes[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password file: %w", err)
+	}
+
+	return hashes, nil
+}
+
+// contains reports whether a password is in the list.
+func (b breachedHashes) contains(password string) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	_, ok := b[hash[:rangePrefixLength]][hash[rangePrefixLength:]]
+	return ok
+}
@@ -0,0 +1,114 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeBreachedHashes writes a breached password file with the given lines.
+func writeBreachedHashes(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        PolicyConfig
+		algorithm     string
+		wantMaxLength int
+		wantErr       bool
+	}{
+		{name: "defaults", algorithm: AlgorithmArgon2id, wantMaxLength: defaultMaxLength},
+		{name: "bcrypt caps the max length", algorithm: AlgorithmBcrypt, wantMaxLength: bcryptMaxLength},
+		{name: "explicit max length", config: PolicyConfig{MaxLength: 64}, algorithm: AlgorithmBcrypt, wantMaxLength: 64},
+		{name: "min length above max length", config: PolicyConfig{MinLength: 100}, algorithm: AlgorithmBcrypt, wantErr: true},
+		{name: "missing breached password file", config: PolicyConfig{BreachedHashesFile: filepath.Join(t.TempDir(), "missing")}, wantErr: true},
+		{name: "malformed breached password file", config: PolicyConfig{BreachedHashesFile: writeBreachedHashes(t, "not-a-hash")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		p, err := NewPolicy(tt.config, tt.algorithm)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: NewPolicy error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && p.config.MaxLength != tt.wantMaxLength {
+			t.Errorf("%s: max length = %d, want %d", tt.name, p.config.MaxLength, tt.wantMaxLength)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	breached := writeBreachedHashes(t,
+		// SHA-1 of "password", upper case with a count as in the Have I Been Pwned downloads.
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824",
+		"",
+		// SHA-1 of "Tr0ub4dor&3", lower case without a count.
+		"  874572e7a5ae6a49466a6ac578b98adba78c6aa6  ",
+	)
+
+	tests := []struct {
+		name     string
+		config   PolicyConfig
+		password string
+		email    string
+		want     []string
+	}{
+		{name: "valid", password: "correct-horse-battery", email: "alice@example.com"},
+		{name: "too short", password: "short", want: []string{"must be at least 8 characters long"}},
+		{name: "multibyte characters count once", password: "pässwörd", config: PolicyConfig{MinLength: 8}},
+		{name: "too long", config: PolicyConfig{MaxLength: 10}, password: "correct-horse-battery", want: []string{"must be at most 10 bytes long"}},
+		{
+			name:     "character classes",
+			config:   PolicyConfig{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			password: "correcthorsebattery",
+			want:     []string{"must contain an uppercase letter", "must contain a digit", "must contain a symbol"},
+		},
+		{
+			name:     "every character class",
+			config:   PolicyConfig{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			password: "Correct horse 1",
+		},
+		{name: "contains the email", password: "xALICE@example.comx", email: "alice@example.com", want: []string{"must not contain the email address"}},
+		{name: "contains the local part", password: "alice-horse-battery", email: "Alice@example.com", want: []string{"must not contain the email address"}},
+		{name: "short local part", password: "al-horse-battery", email: "al@example.com"},
+		{name: "breached", config: PolicyConfig{BreachedHashesFile: breached}, password: "password", want: []string{"appears in a list of breached passwords"}},
+		{name: "breached in lower case", config: PolicyConfig{BreachedHashesFile: breached}, password: "Tr0ub4dor&3", want: []string{"appears in a list of breached passwords"}},
+		{name: "not breached", config: PolicyConfig{BreachedHashesFile: breached}, password: "correct-horse-battery"},
+		{
+			name:     "several violations",
+			config:   PolicyConfig{RequireDigit: true, BreachedHashesFile: breached},
+			password: "passwd", email: "passwd@example.com",
+			want: []string{"must be at least 8 characters long", "must contain a digit", "must not contain the email address"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(tt.config, AlgorithmArgon2id)
+			if err != nil {
+				t.Fatalf("NewPolicy: %v", err)
+			}
+
+			var got []string
+			for _, v := range p.Validate(tt.password, tt.email) {
+				if v.Field != "password" {
+					t.Errorf("violation of field %q, want password", v.Field)
+				}
+				got = append(got, v.Description)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
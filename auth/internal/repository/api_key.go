@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateAPIKey inserts a new API key.
+func (r *repository) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// ListAPIKeys returns the API keys of a user that were not revoked, newest first.
+func (r *repository) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FindAPIKeyByPrefix retrieves an API key by its prefix.
+func (r *repository) FindAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	var key model.APIKey
+
+	if err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// RevokeAPIKey revokes an API key of a user. It returns ErrRecordNotFound if
+// the user has no such key, or it was already revoked.
+func (r *repository) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, now time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAPIKeysByUser revokes every API key of a user.
+func (r *repository) RevokeAPIKeysByUser(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// TouchAPIKey records that an API key was used. The timestamp is only written
+// when the previous one is older than resolution, so busy keys do not cause a
+// write on every request.
+func (r *repository) TouchAPIKey(ctx context.Context, id uuid.UUID, now time.Time, resolution time.Duration) error {
+	return r.db.WithContext(ctx).
+		Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-resolution)).
+		Update("last_used_at", now).Error
+}
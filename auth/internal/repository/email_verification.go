@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+// MarkEmailVerified marks the email of a user record as verified, provided it
+// still has the given address.
+func (r *repository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Credential{}).
+		Where("id = ? AND email = ?", id, email).
+		Update("email_verified", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkVerificationSent records that a verification email is being sent to a
+// user, unless one was sent less than cooldown ago. It reports whether the
+// email may be sent.
+func (r *repository) MarkVerificationSent(ctx context.Context, id uuid.UUID, now time.Time, cooldown time.Duration) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.Credential{}).
+		Where("id = ? AND (verification_sent_at IS NULL OR verification_sent_at <= ?)", id, now.Add(-cooldown)).
+		Update("verification_sent_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
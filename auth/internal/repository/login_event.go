@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+// LoginHistoryQuery selects a page of the login history of a user, newest first.
+type LoginHistoryQuery struct {
+	UserID uuid.UUID
+	// AfterCreatedAt and AfterID are the sort key of the last event of the
+	// previous page, if any.
+	AfterCreatedAt *time.Time
+	AfterID        uuid.UUID
+	Limit          int
+}
+
+// LoginSightings reports what earlier successful logins of a user have in
+// common with a new one.
+type LoginSightings struct {
+	Logins      int64
+	DeviceSeen  bool
+	NetworkSeen bool
+}
+
+// CreateLoginEvent appends an event to the login history.
+func (r *repository) CreateLoginEvent(ctx context.Context, event *model.LoginEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// ListLoginEvents returns a page of the login history of a user.
+func (r *repository) ListLoginEvents(ctx context.Context, query LoginHistoryQuery) ([]model.LoginEvent, error) {
+	db := r.db.WithContext(ctx).Where("user_id = ?", query.UserID)
+	if query.AfterCreatedAt != nil {
+		db = db.Where("(created_at, id) < (?, ?)", *query.AfterCreatedAt, query.AfterID)
+	}
+
+	var events []model.LoginEvent
+	if err := db.Order("created_at DESC, id DESC").Limit(query.Limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// FindLoginSightings checks the successful logins of a user for the given
+// device fingerprint and network.
+func (r *repository) FindLoginSightings(ctx context.Context, userID uuid.UUID, fingerprint, network string) (*LoginSightings, error) {
+	var sightings LoginSightings
+	if err := r.db.WithContext(ctx).
+		Model(&model.LoginEvent{}).
+		Select("COUNT(*) AS logins, "+
+			"COALESCE(BOOL_OR(device_fingerprint = ?), false) AS device_seen, "+
+			"COALESCE(BOOL_OR(network = ?), false) AS network_seen", fingerprint, network).
+		Where("user_id = ? AND success", userID).
+		Scan(&sightings).Error; err != nil {
+		return nil, err
+	}
+	return &sightings, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FindLoginThrottles retrieves the login throttles of the given keys. Keys
+// without failures have no throttle.
+func (r *repository) FindLoginThrottles(ctx context.Context, keys []string) ([]model.LoginThrottle, error) {
+	var throttles []model.LoginThrottle
+	if err := r.db.WithContext(ctx).Where("key IN ?", keys).Find(&throttles).Error; err != nil {
+		return nil, err
+	}
+	return throttles, nil
+}
+
+// RecordLoginFailure counts a failed login for a key and returns the updated
+// throttle. Failures older than window no longer count.
+func (r *repository) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*model.LoginThrottle, error) {
+	throttle := model.LoginThrottle{Key: key, Failures: 1, LastFailureAt: now}
+
+	if err := r.db.WithContext(ctx).
+		Clauses(
+			clause.OnConflict{
+				Columns: []clause.Column{{Name: "key"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", now.Add(-window)),
+					"last_failure_at": now,
+				}),
+			},
+			clause.Returning{},
+		).
+		Create(&throttle).Error; err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// LockLogin locks a key until the given time.
+func (r *repository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.LoginThrottle{}).
+		Where("key = ?", key).
+		Update("locked_until", until).Error
+}
+
+// ResetLoginThrottle clears the failures and lockout of a key.
+func (r *repository) ResetLoginThrottle(ctx context.Context, key string) error {
+	return r.db.WithContext(ctx).
+		Where("key = ?", key).
+		Delete(&model.LoginThrottle{}).Error
+}
+
+// DeleteStaleLoginThrottles deletes throttles whose last failure is older than
+// before and that are no longer locked.
+func (r *repository) DeleteStaleLoginThrottles(ctx context.Context, now, before time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, now).
+		Delete(&model.LoginThrottle{}).Error
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveMFAFactor stores a new unconfirmed MFA factor, replacing an earlier
+// unconfirmed one. It reports false if the user already has a confirmed factor.
+func (r *repository) SaveMFAFactor(ctx context.Context, factor *model.MFAFactor) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"secret", "last_used_step", "created_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "mfa_factors.confirmed_at IS NULL"}}},
+		}).
+		Create(factor)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindMFAFactor retrieves the MFA factor of a user, if there is one.
+func (r *repository) FindMFAFactor(ctx context.Context, userID uuid.UUID) (*model.MFAFactor, error) {
+	var factor model.MFAFactor
+
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&factor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &factor, nil
+}
+
+// ConfirmMFAFactor enables the unconfirmed MFA factor of a user and records
+// the step of the code that confirmed it. It reports false if there is no
+// unconfirmed factor.
+func (r *repository) ConfirmMFAFactor(ctx context.Context, userID uuid.UUID, now time.Time, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.MFAFactor{}).
+		Where("user_id = ? AND confirmed_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"confirmed_at":   now,
+			"last_used_step": step,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UseMFAStep records that the code of a time step was used. It reports false
+// if that step or a later one was used before, so that a code works only once.
+func (r *repository) UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.MFAFactor{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteMFAFactor removes the MFA factor and recovery codes of a user.
+func (r *repository) DeleteMFAFactor(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.MFAFactor{}).Error
+	})
+}
+
+// ReplaceRecoveryCodes replaces every recovery code of a user.
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []model.MFARecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used. It reports
+// false if the user has no such code.
+func (r *repository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateMFAChallenge inserts a new MFA challenge record into the database.
+func (r *repository) CreateMFAChallenge(ctx context.Context, challenge *model.MFAChallenge) error {
+	return r.db.WithContext(ctx).Create(challenge).Error
+}
+
+// FindMFAChallengeByHash retrieves an MFA challenge by the hash of its token.
+func (r *repository) FindMFAChallengeByHash(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	var challenge model.MFAChallenge
+
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// CountMFAChallengeAttempt counts a failed attempt on an MFA challenge and
+// deletes the challenge once maxAttempts is reached.
+func (r *repository) CountMFAChallengeAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.MFAChallenge{}).
+			Where("id = ?", id).
+			Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ? AND attempts >= ?", id, maxAttempts).Delete(&model.MFAChallenge{}).Error
+	})
+}
+
+// DeleteMFAChallenge deletes an MFA challenge. It reports false if the
+// challenge was already gone, so that a challenge completes only once.
+func (r *repository) DeleteMFAChallenge(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.MFAChallenge{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteExpiredMFAChallenges deletes MFA challenges that expired before now.
+func (r *repository) DeleteExpiredMFAChallenges(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Delete(&model.MFAChallenge{}).Error
+}
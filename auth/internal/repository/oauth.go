@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateOAuthClient inserts a new OAuth client.
+func (r *repository) CreateOAuthClient(ctx context.Context, client *model.OAuthClient) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+// FindOAuthClient retrieves an OAuth client by its ID.
+func (r *repository) FindOAuthClient(ctx context.Context, id string) (*model.OAuthClient, error) {
+	var client model.OAuthClient
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+// CreateAuthorizationCode stores a new authorization code.
+func (r *repository) CreateAuthorizationCode(ctx context.Context, code *model.OAuthAuthorizationCode) error {
+	return r.db.WithContext(ctx).Create(code).Error
+}
+
+// ConsumeAuthorizationCode marks the unused, unexpired code with the given
+// hash as used and returns it. It returns nil when there is no such code, so
+// a code can be exchanged only once even by concurrent requests.
+func (r *repository) ConsumeAuthorizationCode(ctx context.Context, codeHash string, now time.Time) (*model.OAuthAuthorizationCode, error) {
+	var codes []model.OAuthAuthorizationCode
+	if err := r.db.WithContext(ctx).
+		Model(&codes).
+		Clauses(clause.Returning{}).
+		Where("code_hash = ? AND used_at IS NULL AND expires_at > ?", codeHash, now).
+		Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	return &codes[0], nil
+}
+
+// FindAuthorizationCodeByHash retrieves an authorization code by its hash, whether it was used or not.
+func (r *repository) FindAuthorizationCodeByHash(ctx context.Context, codeHash string) (*model.OAuthAuthorizationCode, error) {
+	var code model.OAuthAuthorizationCode
+
+	if err := r.db.WithContext(ctx).Where("code_hash = ?", codeHash).First(&code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// DeleteExpiredAuthorizationCodes deletes authorization codes that expired before now.
+func (r *repository) DeleteExpiredAuthorizationCodes(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Delete(&model.OAuthAuthorizationCode{}).Error
+}
+
+// FindOAuthConsent retrieves the consent a user gave to a client.
+func (r *repository) FindOAuthConsent(ctx context.Context, userID uuid.UUID, clientID string) (*model.OAuthConsent, error) {
+	var consent model.OAuthConsent
+
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND client_id = ?", userID, clientID).
+		First(&consent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// SaveOAuthConsent creates or replaces the consent a user gave to a client.
+func (r *repository) SaveOAuthConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+		}).
+		Create(consent).Error
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateOutboxEvent inserts a new outbox event into the database.
+func (r *repository) CreateOutboxEvent(ctx context.Context, event *model.OutboxEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// ClaimOutboxEvents retrieves up to limit events that are due and leases them
+// until now+lease, so that other instances skip them while they are delivered.
+func (r *repository) ClaimOutboxEvents(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("processed_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+
+		return tx.Model(&model.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ClaimOutboxEvent leases a single event if it is due. It returns nil if the
+// event was already delivered, failed, or is leased by another dispatcher.
+func (r *repository) ClaimOutboxEvent(ctx context.Context, id uuid.UUID, now time.Time, lease time.Duration) (*model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+
+	result := r.db.WithContext(ctx).
+		Model(&events).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND processed_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", id, now).
+		Update("next_attempt_at", now.Add(lease))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	return &events[0], nil
+}
+
+// CompleteOutboxEvent marks an outbox event as delivered.
+func (r *repository) CompleteOutboxEvent(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"processed_at": time.Now(),
+			"attempts":     gorm.Expr("attempts + 1"),
+			"last_error":   "",
+		}).Error
+}
+
+// RetryOutboxEvent records a failed delivery attempt and schedules the next one.
+func (r *repository) RetryOutboxEvent(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      lastError,
+			"next_attempt_at": nextAttemptAt,
+		}).Error
+}
+
+// FailOutboxEvent marks an outbox event as permanently failed.
+func (r *repository) FailOutboxEvent(ctx context.Context, id uuid.UUID, lastError string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": lastError,
+			"failed_at":  time.Now(),
+		}).Error
+}
+
+// DeleteProcessedOutboxEvents removes events that were delivered before the given time.
+func (r *repository) DeleteProcessedOutboxEvents(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Where("processed_at < ?", before).Delete(&model.OutboxEvent{}).Error
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreatePasswordResetToken stores a new password reset token and invalidates
+// the unused tokens issued to the same user before it.
+func (r *repository) CreatePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// ConsumePasswordResetToken marks the unused, unexpired token with the given
+// hash as used and returns it. It returns nil when there is no such token, so
+// a token can be consumed only once even by concurrent requests.
+func (r *repository) ConsumePasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	var tokens []model.PasswordResetToken
+	if err := r.db.WithContext(ctx).
+		Model(&tokens).
+		Clauses(clause.Returning{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return &tokens[0], nil
+}
+
+// DeleteExpiredPasswordResetTokens deletes password reset tokens that expired before now.
+func (r *repository) DeleteExpiredPasswordResetTokens(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Delete(&model.PasswordResetToken{}).Error
+}
+
+// UpdatePassword replaces the password hash of a user record.
+func (r *repository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	result := r.db.WithContext(ctx).Model(&model.Credential{}).Where("id = ?", id).Update("password_hash", passwordHash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReplacePasswordHash replaces the password hash of a user record, provided it
+// still has the given old hash.
+func (r *repository) ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Credential{}).
+		Where("id = ? AND password_hash = ?", id, oldHash).
+		Update("password_hash", newHash).Error
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FindRole retrieves a role by its name.
+func (r *repository) FindRole(ctx context.Context, name string) (*model.Role, error) {
+	var role model.Role
+
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// AssignRole assigns a role to a user. It reports false if the user already had the role.
+func (r *repository) AssignRole(ctx context.Context, userID uuid.UUID, role string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.UserRole{UserID: userID, RoleName: role})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeRole removes a role from a user. It reports false if the user did not have the role.
+func (r *repository) RevokeRole(ctx context.Context, userID uuid.UUID, role string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND role_name = ?", userID, role).
+		Delete(&model.UserRole{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ListUserRoles returns the names of the roles of a user.
+func (r *repository) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	roles := []string{}
+	if err := r.db.WithContext(ctx).
+		Model(&model.UserRole{}).
+		Where("user_id = ?", userID).
+		Order("role_name").
+		Pluck("role_name", &roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// HasPermission reports whether any role of a user grants the permission.
+func (r *repository) HasPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&model.UserRole{}).
+		Joins("JOIN role_permissions ON role_permissions.role_name = user_roles.role_name").
+		Where("user_roles.user_id = ? AND role_permissions.permission_name = ?", userID, permission).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -3,17 +3,114 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/PakornBank/go-grpc-example/auth/internal/model"
-	"github.com/PakornBank/go-grpc-example/auth/internal/service"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrRecordNotFound is returned when a record to be modified does not exist.
+var ErrRecordNotFound = errors.New("record not found")
+
 type Repository interface {
+	// Transaction runs fn with a Repository whose calls all happen in one database transaction.
+	Transaction(ctx context.Context, fn func(tx Repository) error) error
+
 	CreateUser(ctx context.Context, credential *model.Credential) error
 	FindByEmail(ctx context.Context, email string) (*model.Credential, error)
+	FindByID(ctx context.Context, id string) (*model.Credential, error)
 	DeleteByID(ctx context.Context, id string) error
-	// FindByID(ctx context.Context, id string) (*model.Credential, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) error
+	MarkVerificationSent(ctx context.Context, id uuid.UUID, now time.Time, cooldown time.Duration) (bool, error)
+
+	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id uuid.UUID) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeRefreshTokensByUser(ctx context.Context, userID uuid.UUID) error
+
+	CreateSession(ctx context.Context, session *model.Session) error
+	FindSession(ctx context.Context, id uuid.UUID) (*model.Session, error)
+	ListSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]model.Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID, now time.Time, resolution time.Duration) error
+	ExtendSession(ctx context.Context, id uuid.UUID, now, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, userID, id uuid.UUID, now time.Time) error
+	RevokeSessionsByUser(ctx context.Context, userID uuid.UUID, now time.Time) error
+	ListRevokedSessions(ctx context.Context, since time.Time) ([]uuid.UUID, error)
+	DeleteExpiredSessions(ctx context.Context, now, revokedBefore time.Time) error
+
+	CreateLoginEvent(ctx context.Context, event *model.LoginEvent) error
+	ListLoginEvents(ctx context.Context, query LoginHistoryQuery) ([]model.LoginEvent, error)
+	FindLoginSightings(ctx context.Context, userID uuid.UUID, fingerprint, network string) (*LoginSightings, error)
+
+	RevokeToken(ctx context.Context, token *model.RevokedToken) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	ListRevokedTokens(ctx context.Context, since, now time.Time) ([]model.RevokedToken, error)
+	ListUserRevocations(ctx context.Context, since time.Time) ([]model.UserRevocation, error)
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error
+	FindUserRevocation(ctx context.Context, userID uuid.UUID) (*model.UserRevocation, error)
+	DeleteExpiredRevocations(ctx context.Context, now time.Time, maxTokenAge time.Duration) error
+
+	CreateSigningKey(ctx context.Context, key *model.SigningKey, retiredUntil time.Time) error
+	ListSigningKeys(ctx context.Context, now time.Time) ([]model.SigningKey, error)
+	DeleteExpiredSigningKeys(ctx context.Context, now time.Time) error
+
+	CreatePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) error
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error)
+	DeleteExpiredPasswordResetTokens(ctx context.Context, now time.Time) error
+
+	FindLoginThrottles(ctx context.Context, keys []string) ([]model.LoginThrottle, error)
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*model.LoginThrottle, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginThrottle(ctx context.Context, key string) error
+	DeleteStaleLoginThrottles(ctx context.Context, now, before time.Time) error
+
+	SaveMFAFactor(ctx context.Context, factor *model.MFAFactor) (bool, error)
+	FindMFAFactor(ctx context.Context, userID uuid.UUID) (*model.MFAFactor, error)
+	ConfirmMFAFactor(ctx context.Context, userID uuid.UUID, now time.Time, step int64) (bool, error)
+	UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	DeleteMFAFactor(ctx context.Context, userID uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []model.MFARecoveryCode) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, now time.Time) (bool, error)
+	CreateMFAChallenge(ctx context.Context, challenge *model.MFAChallenge) error
+	FindMFAChallengeByHash(ctx context.Context, tokenHash string) (*model.MFAChallenge, error)
+	CountMFAChallengeAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) error
+	DeleteMFAChallenge(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteExpiredMFAChallenges(ctx context.Context, now time.Time) error
+
+	FindRole(ctx context.Context, name string) (*model.Role, error)
+	AssignRole(ctx context.Context, userID uuid.UUID, role string) (bool, error)
+	RevokeRole(ctx context.Context, userID uuid.UUID, role string) (bool, error)
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error)
+	HasPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error)
+
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error)
+	FindAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, now time.Time) error
+	RevokeAPIKeysByUser(ctx context.Context, userID uuid.UUID, now time.Time) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID, now time.Time, resolution time.Duration) error
+
+	CreateOAuthClient(ctx context.Context, client *model.OAuthClient) error
+	FindOAuthClient(ctx context.Context, id string) (*model.OAuthClient, error)
+	CreateAuthorizationCode(ctx context.Context, code *model.OAuthAuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string, now time.Time) (*model.OAuthAuthorizationCode, error)
+	FindAuthorizationCodeByHash(ctx context.Context, codeHash string) (*model.OAuthAuthorizationCode, error)
+	DeleteExpiredAuthorizationCodes(ctx context.Context, now time.Time) error
+	FindOAuthConsent(ctx context.Context, userID uuid.UUID, clientID string) (*model.OAuthConsent, error)
+	SaveOAuthConsent(ctx context.Context, consent *model.OAuthConsent) error
+
+	CreateOutboxEvent(ctx context.Context, event *model.OutboxEvent) error
+	ClaimOutboxEvents(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]model.OutboxEvent, error)
+	ClaimOutboxEvent(ctx context.Context, id uuid.UUID, now time.Time, lease time.Duration) (*model.OutboxEvent, error)
+	CompleteOutboxEvent(ctx context.Context, id uuid.UUID) error
+	RetryOutboxEvent(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt time.Time) error
+	FailOutboxEvent(ctx context.Context, id uuid.UUID, lastError string) error
+	DeleteProcessedOutboxEvents(ctx context.Context, before time.Time) error
 }
 
 type repository struct {
@@ -24,6 +121,14 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
+// Transaction runs fn inside a database transaction. The transaction is
+// committed if fn returns nil and rolled back otherwise.
+func (r *repository) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{db: tx})
+	})
+}
+
 // CreateUser inserts a new user record into the database.
 func (r *repository) CreateUser(ctx context.Context, credential *model.Credential) error {
 	return r.db.WithContext(ctx).Create(credential).Error
@@ -43,32 +148,118 @@ func (r *repository) FindByEmail(ctx context.Context, email string) (*model.Cred
 	return &credential, nil
 }
 
-// DeleteByID deletes a user record from the database.
+// FindByID retrieves a user from the database by their ID.
+func (r *repository) FindByID(ctx context.Context, id string) (*model.Credential, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var credential model.Credential
+	if err := r.db.WithContext(ctx).Where("id = ?", uid).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// DeleteByID deletes a user record from the database together with every
+// record that grants access to the account: roles, API keys, sessions,
+// refresh tokens, MFA factors, challenges and recovery codes, password reset
+// tokens, and OAuth consents and authorization codes.
 func (r *repository) DeleteByID(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&model.Credential{}, id)
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return ErrRecordNotFound
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", uid).Delete(&model.Credential{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		for _, table := range []interface{}{
+			&model.UserRole{},
+			&model.APIKey{},
+			&model.Session{},
+			&model.RefreshToken{},
+			&model.MFAFactor{},
+			&model.MFAChallenge{},
+			&model.MFARecoveryCode{},
+			&model.PasswordResetToken{},
+			&model.OAuthConsent{},
+			&model.OAuthAuthorizationCode{},
+		} {
+			if err := tx.Where("user_id = ?", uid).Delete(table).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateStatus sets the status of a user record.
+func (r *repository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	result := r.db.WithContext(ctx).Model(&model.Credential{}).Where("id = ?", id).Update("status", status)
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		return service.ErrRecordNotFound
+		return ErrRecordNotFound
 	}
 	return nil
 }
 
-//// FindByID retrieves a user from the database by their email address.
-//func (r *repository) FindByID(ctx context.Context, id string) (*model.User, error) {
-//	uid, err := uuid.Parse(id)
-//	if err != nil {
-//		return nil, err
-//	}
-//
-//	var user model.User
-//	if err := r.db.WithContext(ctx).Where("id = ?", uid).First(&user).Error; err != nil {
-//		if errors.Is(err, gorm.ErrRecordNotFound) {
-//			return nil, nil
-//		}
-//		return nil, err
-//	}
-//
-//	return &user, nil
-//}
+// CreateRefreshToken inserts a new refresh token record into the database.
+func (r *repository) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// FindRefreshTokenByHash retrieves a refresh token from the database by its hash.
+func (r *repository) FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RevokeRefreshToken marks a refresh token as revoked. It reports false when
+// the token had already been revoked, so callers can detect concurrent use.
+func (r *repository) RevokeRefreshToken(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token that belongs to the given family.
+func (r *repository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeRefreshTokensByUser revokes every refresh token issued to the given user.
+func (r *repository) RevokeRefreshTokensByUser(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
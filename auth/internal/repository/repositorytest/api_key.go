@@ -0,0 +1,96 @@
+package repositorytest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// CreateAPIKey inserts a new API key.
+func (r *Repository) CreateAPIKey(_ context.Context, key *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.s.apiKeys {
+		if k.Prefix == key.Prefix {
+			return ErrDuplicateKey
+		}
+	}
+	key.ID = newID(key.ID)
+	key.CreatedAt = createdAt(key.CreatedAt)
+	r.s.apiKeys[key.ID] = *key
+	return nil
+}
+
+// ListAPIKeys returns the API keys of a user that were not revoked, newest first.
+func (r *Repository) ListAPIKeys(_ context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []model.APIKey
+	for _, k := range r.s.apiKeys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// FindAPIKeyByPrefix retrieves an API key by its prefix.
+func (r *Repository) FindAPIKeyByPrefix(_ context.Context, prefix string) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.s.apiKeys {
+		if k.Prefix == prefix {
+			return &k, nil
+		}
+	}
+	return nil, nil
+}
+
+// RevokeAPIKey revokes an API key of a user that was not revoked yet.
+func (r *Repository) RevokeAPIKey(_ context.Context, userID, id uuid.UUID, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.s.apiKeys[id]
+	if !ok || k.UserID != userID || k.RevokedAt != nil {
+		return repository.ErrRecordNotFound
+	}
+	k.RevokedAt = timePtr(now)
+	r.s.apiKeys[id] = k
+	return nil
+}
+
+// RevokeAPIKeysByUser revokes every API key of a user.
+func (r *Repository) RevokeAPIKeysByUser(_ context.Context, userID uuid.UUID, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, k := range r.s.apiKeys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			k.RevokedAt = timePtr(now)
+			r.s.apiKeys[id] = k
+		}
+	}
+	return nil
+}
+
+// TouchAPIKey records the use of an API key when the last one is older than resolution.
+func (r *Repository) TouchAPIKey(_ context.Context, id uuid.UUID, now time.Time, resolution time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.s.apiKeys[id]
+	if ok && (k.LastUsedAt == nil || k.LastUsedAt.Before(now.Add(-resolution))) {
+		k.LastUsedAt = timePtr(now)
+		r.s.apiKeys[id] = k
+	}
+	return nil
+}
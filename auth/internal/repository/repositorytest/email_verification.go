@@ -0,0 +1,35 @@
+package repositorytest
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// MarkEmailVerified marks the email of a credential that still has it as verified.
+func (r *Repository) MarkEmailVerified(_ context.Context, id uuid.UUID, email string) error {
+	if !r.updateCredential(id, func(c *model.Credential) bool {
+		if c.Email != email {
+			return false
+		}
+		c.EmailVerified = true
+		return true
+	}) {
+		return repository.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkVerificationSent records a verification email unless one was sent less than cooldown ago.
+func (r *Repository) MarkVerificationSent(_ context.Context, id uuid.UUID, now time.Time, cooldown time.Duration) (bool, error) {
+	return r.updateCredential(id, func(c *model.Credential) bool {
+		if c.VerificationSentAt != nil && c.VerificationSentAt.After(now.Add(-cooldown)) {
+			return false
+		}
+		c.VerificationSentAt = timePtr(now)
+		return true
+	}), nil
+}
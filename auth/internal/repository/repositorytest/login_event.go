@@ -0,0 +1,81 @@
+package repositorytest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// CreateLoginEvent appends an event to the login history.
+func (r *Repository) CreateLoginEvent(_ context.Context, event *model.LoginEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = newID(event.ID)
+	event.CreatedAt = createdAt(event.CreatedAt)
+	r.s.loginEvents = append(r.s.loginEvents, *event)
+	return nil
+}
+
+// ListLoginEvents returns a page of the login history of a user, newest first.
+func (r *Repository) ListLoginEvents(_ context.Context, query repository.LoginHistoryQuery) ([]model.LoginEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []model.LoginEvent
+	for _, e := range r.s.loginEvents {
+		if e.UserID != nil && *e.UserID == query.UserID {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return eventAfter(events[i], events[j].CreatedAt, events[j].ID) })
+
+	page := []model.LoginEvent{}
+	for _, e := range events {
+		if query.AfterCreatedAt != nil && !eventBefore(e, *query.AfterCreatedAt, query.AfterID) {
+			continue
+		}
+		if len(page) == query.Limit {
+			break
+		}
+		page = append(page, e)
+	}
+	return page, nil
+}
+
+// eventAfter reports whether e sorts after the key (createdAt, id).
+func eventAfter(e model.LoginEvent, createdAt time.Time, id uuid.UUID) bool {
+	if !e.CreatedAt.Equal(createdAt) {
+		return e.CreatedAt.After(createdAt)
+	}
+	return e.ID.String() > id.String()
+}
+
+// eventBefore reports whether e sorts before the key (createdAt, id).
+func eventBefore(e model.LoginEvent, createdAt time.Time, id uuid.UUID) bool {
+	if !e.CreatedAt.Equal(createdAt) {
+		return e.CreatedAt.Before(createdAt)
+	}
+	return e.ID.String() < id.String()
+}
+
+// FindLoginSightings checks the successful logins of a user for a device fingerprint and network.
+func (r *Repository) FindLoginSightings(_ context.Context, userID uuid.UUID, fingerprint, network string) (*repository.LoginSightings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sightings := &repository.LoginSightings{}
+	for _, e := range r.s.loginEvents {
+		if e.UserID == nil || *e.UserID != userID || !e.Success {
+			continue
+		}
+		sightings.Logins++
+		sightings.DeviceSeen = sightings.DeviceSeen || e.DeviceFingerprint == fingerprint
+		sightings.NetworkSeen = sightings.NetworkSeen || e.Network == network
+	}
+	return sightings, nil
+}
@@ -0,0 +1,74 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+)
+
+// FindLoginThrottles retrieves the login throttles of the given keys.
+func (r *Repository) FindLoginThrottles(_ context.Context, keys []string) ([]model.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var throttles []model.LoginThrottle
+	for _, key := range keys {
+		if t, ok := r.s.throttles[key]; ok {
+			throttles = append(throttles, t)
+		}
+	}
+	return throttles, nil
+}
+
+// RecordLoginFailure counts a failed login for a key. Failures older than window no longer count.
+func (r *Repository) RecordLoginFailure(_ context.Context, key string, now time.Time, window time.Duration) (*model.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.s.throttles[key]
+	switch {
+	case !ok:
+		t = model.LoginThrottle{Key: key, Failures: 1}
+	case t.LastFailureAt.Before(now.Add(-window)):
+		t.Failures = 1
+	default:
+		t.Failures++
+	}
+	t.LastFailureAt = now
+	r.s.throttles[key] = t
+	return &t, nil
+}
+
+// LockLogin locks a key until the given time.
+func (r *Repository) LockLogin(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.s.throttles[key]; ok {
+		t.LockedUntil = timePtr(until)
+		r.s.throttles[key] = t
+	}
+	return nil
+}
+
+// ResetLoginThrottle clears the failures and lockout of a key.
+func (r *Repository) ResetLoginThrottle(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.s.throttles, key)
+	return nil
+}
+
+// DeleteStaleLoginThrottles deletes unlocked throttles whose last failure is older than before.
+func (r *Repository) DeleteStaleLoginThrottles(_ context.Context, now, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.throttles, func(_ string, t model.LoginThrottle) bool {
+		return t.LastFailureAt.Before(before) && (t.LockedUntil == nil || t.LockedUntil.Before(now))
+	})
+	return nil
+}
@@ -0,0 +1,165 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+// SaveMFAFactor stores an unconfirmed MFA factor, unless the user has a confirmed one.
+func (r *Repository) SaveMFAFactor(_ context.Context, factor *model.MFAFactor) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.s.mfaFactors[factor.UserID]; ok && f.ConfirmedAt != nil {
+		return false, nil
+	}
+	factor.CreatedAt = createdAt(factor.CreatedAt)
+	r.s.mfaFactors[factor.UserID] = *factor
+	return true, nil
+}
+
+// FindMFAFactor retrieves the MFA factor of a user.
+func (r *Repository) FindMFAFactor(_ context.Context, userID uuid.UUID) (*model.MFAFactor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.s.mfaFactors[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// ConfirmMFAFactor enables the unconfirmed MFA factor of a user.
+func (r *Repository) ConfirmMFAFactor(_ context.Context, userID uuid.UUID, now time.Time, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.s.mfaFactors[userID]
+	if !ok || f.ConfirmedAt != nil {
+		return false, nil
+	}
+	f.ConfirmedAt = timePtr(now)
+	f.LastUsedStep = step
+	r.s.mfaFactors[userID] = f
+	return true, nil
+}
+
+// UseMFAStep records the use of a time step, unless it or a later one was used before.
+func (r *Repository) UseMFAStep(_ context.Context, userID uuid.UUID, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.s.mfaFactors[userID]
+	if !ok || f.LastUsedStep >= step {
+		return false, nil
+	}
+	f.LastUsedStep = step
+	r.s.mfaFactors[userID] = f
+	return true, nil
+}
+
+// DeleteMFAFactor removes the MFA factor and recovery codes of a user.
+func (r *Repository) DeleteMFAFactor(_ context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.s.mfaFactors, userID)
+	maps.DeleteFunc(r.s.recoveryCodes, func(_ uuid.UUID, c model.MFARecoveryCode) bool { return c.UserID == userID })
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces every recovery code of a user.
+func (r *Repository) ReplaceRecoveryCodes(_ context.Context, userID uuid.UUID, codes []model.MFARecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.recoveryCodes, func(_ uuid.UUID, c model.MFARecoveryCode) bool { return c.UserID == userID })
+	for _, c := range codes {
+		c.ID = newID(c.ID)
+		r.s.recoveryCodes[c.ID] = c
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used.
+func (r *Repository) UseRecoveryCode(_ context.Context, userID uuid.UUID, codeHash string, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, c := range r.s.recoveryCodes {
+		if c.UserID == userID && c.CodeHash == codeHash && c.UsedAt == nil {
+			c.UsedAt = timePtr(now)
+			r.s.recoveryCodes[id] = c
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateMFAChallenge inserts a new MFA challenge.
+func (r *Repository) CreateMFAChallenge(_ context.Context, challenge *model.MFAChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge.ID = newID(challenge.ID)
+	challenge.CreatedAt = createdAt(challenge.CreatedAt)
+	r.s.mfaChallenges[challenge.ID] = *challenge
+	return nil
+}
+
+// FindMFAChallengeByHash retrieves an MFA challenge by the hash of its token.
+func (r *Repository) FindMFAChallengeByHash(_ context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.s.mfaChallenges {
+		if c.TokenHash == tokenHash {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// CountMFAChallengeAttempt counts a failed attempt and deletes the challenge at maxAttempts.
+func (r *Repository) CountMFAChallengeAttempt(_ context.Context, id uuid.UUID, maxAttempts int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.s.mfaChallenges[id]
+	if !ok {
+		return nil
+	}
+	c.Attempts++
+	if c.Attempts >= maxAttempts {
+		delete(r.s.mfaChallenges, id)
+		return nil
+	}
+	r.s.mfaChallenges[id] = c
+	return nil
+}
+
+// DeleteMFAChallenge deletes an MFA challenge and reports false if it was already gone.
+func (r *Repository) DeleteMFAChallenge(_ context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.s.mfaChallenges[id]; !ok {
+		return false, nil
+	}
+	delete(r.s.mfaChallenges, id)
+	return true, nil
+}
+
+// DeleteExpiredMFAChallenges deletes MFA challenges that expired before now.
+func (r *Repository) DeleteExpiredMFAChallenges(_ context.Context, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.mfaChallenges, func(_ uuid.UUID, c model.MFAChallenge) bool { return c.ExpiresAt.Before(now) })
+	return nil
+}
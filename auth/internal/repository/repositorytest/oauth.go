@@ -0,0 +1,115 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+type consentKey struct {
+	userID   uuid.UUID
+	clientID string
+}
+
+// CreateOAuthClient inserts a new OAuth client.
+func (r *Repository) CreateOAuthClient(_ context.Context, client *model.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.s.oauthClients[client.ID]; ok {
+		return ErrDuplicateKey
+	}
+	client.CreatedAt = createdAt(client.CreatedAt)
+	r.s.oauthClients[client.ID] = *client
+	return nil
+}
+
+// FindOAuthClient retrieves an OAuth client by its ID.
+func (r *Repository) FindOAuthClient(_ context.Context, id string) (*model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.s.oauthClients[id]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// CreateAuthorizationCode stores a new authorization code.
+func (r *Repository) CreateAuthorizationCode(_ context.Context, code *model.OAuthAuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code.ID = newID(code.ID)
+	code.CreatedAt = createdAt(code.CreatedAt)
+	r.s.authCodes[code.ID] = *code
+	return nil
+}
+
+// ConsumeAuthorizationCode marks an unused, unexpired code as used and returns it.
+func (r *Repository) ConsumeAuthorizationCode(_ context.Context, codeHash string, now time.Time) (*model.OAuthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, c := range r.s.authCodes {
+		if c.CodeHash == codeHash && c.UsedAt == nil && c.ExpiresAt.After(now) {
+			c.UsedAt = timePtr(now)
+			r.s.authCodes[id] = c
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindAuthorizationCodeByHash retrieves an authorization code by its hash, used or not.
+func (r *Repository) FindAuthorizationCodeByHash(_ context.Context, codeHash string) (*model.OAuthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.s.authCodes {
+		if c.CodeHash == codeHash {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteExpiredAuthorizationCodes deletes authorization codes that expired before now.
+func (r *Repository) DeleteExpiredAuthorizationCodes(_ context.Context, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.authCodes, func(_ uuid.UUID, c model.OAuthAuthorizationCode) bool { return c.ExpiresAt.Before(now) })
+	return nil
+}
+
+// FindOAuthConsent retrieves the consent a user gave to a client.
+func (r *Repository) FindOAuthConsent(_ context.Context, userID uuid.UUID, clientID string) (*model.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.s.consents[consentKey{userID: userID, clientID: clientID}]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// SaveOAuthConsent creates or replaces the consent a user gave to a client.
+func (r *Repository) SaveOAuthConsent(_ context.Context, consent *model.OAuthConsent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := consentKey{userID: consent.UserID, clientID: consent.ClientID}
+	if existing, ok := r.s.consents[key]; ok {
+		consent.CreatedAt = existing.CreatedAt
+	}
+	consent.CreatedAt = createdAt(consent.CreatedAt)
+	consent.UpdatedAt = time.Now()
+	r.s.consents[key] = *consent
+	return nil
+}
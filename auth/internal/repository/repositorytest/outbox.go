@@ -0,0 +1,149 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+// CreateOutboxEvent inserts a new outbox event.
+func (r *Repository) CreateOutboxEvent(_ context.Context, event *model.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = newID(event.ID)
+	event.CreatedAt = createdAt(event.CreatedAt)
+	r.s.outboxEvents[event.ID] = *event
+	return nil
+}
+
+// outboxEventDue reports whether an event is waiting for delivery at now.
+func outboxEventDue(e model.OutboxEvent, now time.Time) bool {
+	return e.ProcessedAt == nil && e.FailedAt == nil && !e.NextAttemptAt.After(now)
+}
+
+// ClaimOutboxEvents leases up to limit due events until now+lease.
+func (r *Repository) ClaimOutboxEvents(_ context.Context, now time.Time, limit int, lease time.Duration) ([]model.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []model.OutboxEvent
+	for _, e := range r.s.outboxEvents {
+		if outboxEventDue(e, now) {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].NextAttemptAt.Before(events[j].NextAttemptAt) })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	for _, e := range events {
+		e.NextAttemptAt = now.Add(lease)
+		r.s.outboxEvents[e.ID] = e
+	}
+	return events, nil
+}
+
+// ClaimOutboxEvent leases a single event if it is due.
+func (r *Repository) ClaimOutboxEvent(_ context.Context, id uuid.UUID, now time.Time, lease time.Duration) (*model.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.s.outboxEvents[id]
+	if !ok || !outboxEventDue(e, now) {
+		return nil, nil
+	}
+	e.NextAttemptAt = now.Add(lease)
+	r.s.outboxEvents[id] = e
+	return &e, nil
+}
+
+// updateOutboxEvent applies fn to an outbox event.
+func (r *Repository) updateOutboxEvent(id uuid.UUID, fn func(e *model.OutboxEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.s.outboxEvents[id]; ok {
+		fn(&e)
+		r.s.outboxEvents[id] = e
+	}
+}
+
+// CompleteOutboxEvent marks an outbox event as delivered.
+func (r *Repository) CompleteOutboxEvent(_ context.Context, id uuid.UUID) error {
+	r.updateOutboxEvent(id, func(e *model.OutboxEvent) {
+		e.ProcessedAt = timePtr(time.Now())
+		e.Attempts++
+		e.LastError = ""
+	})
+	return nil
+}
+
+// RetryOutboxEvent records a failed delivery attempt and schedules the next one.
+func (r *Repository) RetryOutboxEvent(_ context.Context, id uuid.UUID, lastError string, nextAttemptAt time.Time) error {
+	r.updateOutboxEvent(id, func(e *model.OutboxEvent) {
+		e.Attempts++
+		e.LastError = lastError
+		e.NextAttemptAt = nextAttemptAt
+	})
+	return nil
+}
+
+// FailOutboxEvent marks an outbox event as permanently failed.
+func (r *Repository) FailOutboxEvent(_ context.Context, id uuid.UUID, lastError string) error {
+	r.updateOutboxEvent(id, func(e *model.OutboxEvent) {
+		e.Attempts++
+		e.LastError = lastError
+		e.FailedAt = timePtr(time.Now())
+	})
+	return nil
+}
+
+// DeleteProcessedOutboxEvents removes events that were delivered before the given time.
+func (r *Repository) DeleteProcessedOutboxEvents(_ context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.outboxEvents, func(_ uuid.UUID, e model.OutboxEvent) bool {
+		return e.ProcessedAt != nil && e.ProcessedAt.Before(before)
+	})
+	return nil
+}
+
+// OutboxEvent returns an outbox event, so that tests can check its delivery state.
+func (r *Repository) OutboxEvent(id uuid.UUID) (model.OutboxEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.s.outboxEvents[id]
+	return e, ok
+}
+
+// OutboxEvents returns every outbox event, oldest first.
+func (r *Repository) OutboxEvents() []model.OutboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := slices.Collect(maps.Values(r.s.outboxEvents))
+	sort.Slice(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return events
+}
+
+// RescheduleOutboxEvents makes every pending outbox event due at the given
+// time, so that tests do not wait out the retry backoff.
+func (r *Repository) RescheduleOutboxEvents(at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, e := range r.s.outboxEvents {
+		if e.ProcessedAt == nil && e.FailedAt == nil {
+			e.NextAttemptAt = at
+			r.s.outboxEvents[id] = e
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// UpdatePassword replaces the password hash of a credential.
+func (r *Repository) UpdatePassword(_ context.Context, id uuid.UUID, passwordHash string) error {
+	if !r.updateCredential(id, func(c *model.Credential) bool { c.PasswordHash = passwordHash; return true }) {
+		return repository.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReplacePasswordHash replaces the password hash of a credential that still has oldHash.
+func (r *Repository) ReplacePasswordHash(_ context.Context, id uuid.UUID, oldHash, newHash string) error {
+	r.updateCredential(id, func(c *model.Credential) bool {
+		if c.PasswordHash != oldHash {
+			return false
+		}
+		c.PasswordHash = newHash
+		return true
+	})
+	return nil
+}
+
+// CreatePasswordResetToken stores a reset token and invalidates the unused earlier ones of the user.
+func (r *Repository) CreatePasswordResetToken(_ context.Context, token *model.PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.s.resetTokens {
+		if t.UserID == token.UserID && t.UsedAt == nil {
+			t.UsedAt = timePtr(now)
+			r.s.resetTokens[id] = t
+		}
+	}
+	token.ID = newID(token.ID)
+	token.CreatedAt = createdAt(token.CreatedAt)
+	r.s.resetTokens[token.ID] = *token
+	return nil
+}
+
+// ConsumePasswordResetToken marks an unused, unexpired reset token as used and returns it.
+func (r *Repository) ConsumePasswordResetToken(_ context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, t := range r.s.resetTokens {
+		if t.TokenHash == tokenHash && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = timePtr(now)
+			r.s.resetTokens[id] = t
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteExpiredPasswordResetTokens deletes reset tokens that expired before now.
+func (r *Repository) DeleteExpiredPasswordResetTokens(_ context.Context, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.resetTokens, func(_ uuid.UUID, t model.PasswordResetToken) bool { return t.ExpiresAt.Before(now) })
+	return nil
+}
@@ -0,0 +1,80 @@
+package repositorytest
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+type userRoleKey struct {
+	userID uuid.UUID
+	role   string
+}
+
+// FindRole retrieves a role by its name.
+func (r *Repository) FindRole(_ context.Context, name string) (*model.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.s.roles[name]; !ok {
+		return nil, nil
+	}
+	return &model.Role{Name: name}, nil
+}
+
+// AssignRole assigns a role to a user and reports false if the user already had it.
+func (r *Repository) AssignRole(_ context.Context, userID uuid.UUID, role string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := userRoleKey{userID: userID, role: role}
+	if _, ok := r.s.userRoles[key]; ok {
+		return false, nil
+	}
+	r.s.userRoles[key] = model.UserRole{UserID: userID, RoleName: role, CreatedAt: time.Now()}
+	return true, nil
+}
+
+// RevokeRole removes a role from a user and reports false if the user did not have it.
+func (r *Repository) RevokeRole(_ context.Context, userID uuid.UUID, role string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := userRoleKey{userID: userID, role: role}
+	if _, ok := r.s.userRoles[key]; !ok {
+		return false, nil
+	}
+	delete(r.s.userRoles, key)
+	return true, nil
+}
+
+// ListUserRoles returns the names of the roles of a user in order.
+func (r *Repository) ListUserRoles(_ context.Context, userID uuid.UUID) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	roles := []string{}
+	for key := range r.s.userRoles {
+		if key.userID == userID {
+			roles = append(roles, key.role)
+		}
+	}
+	slices.Sort(roles)
+	return roles, nil
+}
+
+// HasPermission reports whether any role of a user grants the permission.
+func (r *Repository) HasPermission(_ context.Context, userID uuid.UUID, permission string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.s.userRoles {
+		if key.userID == userID && slices.Contains(r.s.roles[key.role], permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
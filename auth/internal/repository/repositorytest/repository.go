@@ -0,0 +1,354 @@
+// Package repositorytest provides an in-memory repository.Repository for tests.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrDuplicateKey is returned when a record would break a unique constraint.
+var ErrDuplicateKey = errors.New("duplicate key value violates unique constraint")
+
+// state holds every table. Records are stored by value, so that callers
+// cannot change them without going through the repository.
+type state struct {
+	credentials     map[uuid.UUID]model.Credential
+	refreshTokens   map[uuid.UUID]model.RefreshToken
+	sessions        map[uuid.UUID]model.Session
+	loginEvents     []model.LoginEvent
+	revokedTokens   map[string]model.RevokedToken
+	userRevocations map[uuid.UUID]model.UserRevocation
+	signingKeys     map[string]model.SigningKey
+	resetTokens     map[uuid.UUID]model.PasswordResetToken
+	throttles       map[string]model.LoginThrottle
+	mfaFactors      map[uuid.UUID]model.MFAFactor
+	recoveryCodes   map[uuid.UUID]model.MFARecoveryCode
+	mfaChallenges   map[uuid.UUID]model.MFAChallenge
+	roles           map[string][]string
+	userRoles       map[userRoleKey]model.UserRole
+	apiKeys         map[uuid.UUID]model.APIKey
+	oauthClients    map[string]model.OAuthClient
+	authCodes       map[uuid.UUID]model.OAuthAuthorizationCode
+	consents        map[consentKey]model.OAuthConsent
+	outboxEvents    map[uuid.UUID]model.OutboxEvent
+}
+
+func (s *state) clone() *state {
+	return &state{
+		credentials:     maps.Clone(s.credentials),
+		refreshTokens:   maps.Clone(s.refreshTokens),
+		sessions:        maps.Clone(s.sessions),
+		loginEvents:     slices.Clone(s.loginEvents),
+		revokedTokens:   maps.Clone(s.revokedTokens),
+		userRevocations: maps.Clone(s.userRevocations),
+		signingKeys:     maps.Clone(s.signingKeys),
+		resetTokens:     maps.Clone(s.resetTokens),
+		throttles:       maps.Clone(s.throttles),
+		mfaFactors:      maps.Clone(s.mfaFactors),
+		recoveryCodes:   maps.Clone(s.recoveryCodes),
+		mfaChallenges:   maps.Clone(s.mfaChallenges),
+		roles:           maps.Clone(s.roles),
+		userRoles:       maps.Clone(s.userRoles),
+		apiKeys:         maps.Clone(s.apiKeys),
+		oauthClients:    maps.Clone(s.oauthClients),
+		authCodes:       maps.Clone(s.authCodes),
+		consents:        maps.Clone(s.consents),
+		outboxEvents:    maps.Clone(s.outboxEvents),
+	}
+}
+
+// Repository is an in-memory repository.Repository. It behaves like the
+// database implementation, including the roles that the migration seeds.
+type Repository struct {
+	mu sync.Mutex
+	s  *state
+
+	// txMu serializes transactions, which roll back by restoring a snapshot.
+	txMu sync.Mutex
+}
+
+var _ repository.Repository = (*Repository)(nil)
+
+// New creates an empty Repository with the default roles.
+func New() *Repository {
+	s := &state{
+		credentials:     map[uuid.UUID]model.Credential{},
+		refreshTokens:   map[uuid.UUID]model.RefreshToken{},
+		sessions:        map[uuid.UUID]model.Session{},
+		revokedTokens:   map[string]model.RevokedToken{},
+		userRevocations: map[uuid.UUID]model.UserRevocation{},
+		signingKeys:     map[string]model.SigningKey{},
+		resetTokens:     map[uuid.UUID]model.PasswordResetToken{},
+		throttles:       map[string]model.LoginThrottle{},
+		mfaFactors:      map[uuid.UUID]model.MFAFactor{},
+		recoveryCodes:   map[uuid.UUID]model.MFARecoveryCode{},
+		mfaChallenges:   map[uuid.UUID]model.MFAChallenge{},
+		roles:           map[string][]string{},
+		userRoles:       map[userRoleKey]model.UserRole{},
+		apiKeys:         map[uuid.UUID]model.APIKey{},
+		oauthClients:    map[string]model.OAuthClient{},
+		authCodes:       map[uuid.UUID]model.OAuthAuthorizationCode{},
+		consents:        map[consentKey]model.OAuthConsent{},
+		outboxEvents:    map[uuid.UUID]model.OutboxEvent{},
+	}
+	for role, permissions := range model.DefaultRoles {
+		s.roles[role] = slices.Clone(permissions)
+	}
+	return &Repository{s: s}
+}
+
+// Transaction runs fn and restores the previous state if it fails.
+func (r *Repository) Transaction(_ context.Context, fn func(tx repository.Repository) error) error {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	r.mu.Lock()
+	snapshot := r.s.clone()
+	r.mu.Unlock()
+
+	if err := fn(r); err != nil {
+		r.mu.Lock()
+		r.s = snapshot
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// newID returns id, or a new ID if it is not set, like the column default.
+func newID(id uuid.UUID) uuid.UUID {
+	if id == uuid.Nil {
+		return uuid.New()
+	}
+	return id
+}
+
+// createdAt returns t, or the current time if it is not set, like gorm does.
+func createdAt(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// CreateUser inserts a new credential.
+func (r *Repository) CreateUser(_ context.Context, credential *model.Credential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential.ID = newID(credential.ID)
+	if _, ok := r.s.credentials[credential.ID]; ok {
+		return ErrDuplicateKey
+	}
+	for _, c := range r.s.credentials {
+		if c.Email == credential.Email {
+			return ErrDuplicateKey
+		}
+	}
+	if credential.Status == "" {
+		credential.Status = model.CredentialStatusActive
+	}
+	r.s.credentials[credential.ID] = *credential
+	return nil
+}
+
+// FindByEmail retrieves a credential by its email.
+func (r *Repository) FindByEmail(_ context.Context, email string) (*model.Credential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.s.credentials {
+		if c.Email == email {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindByID retrieves a credential by its ID.
+func (r *Repository) FindByID(_ context.Context, id string) (*model.Credential, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.s.credentials[uid]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// DeleteByID deletes a credential with every record that grants access to the account.
+func (r *Repository) DeleteByID(_ context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.s.credentials[uid]; !ok {
+		return repository.ErrRecordNotFound
+	}
+	delete(r.s.credentials, uid)
+	maps.DeleteFunc(r.s.userRoles, func(k userRoleKey, _ model.UserRole) bool { return k.userID == uid })
+	maps.DeleteFunc(r.s.apiKeys, func(_ uuid.UUID, k model.APIKey) bool { return k.UserID == uid })
+	maps.DeleteFunc(r.s.sessions, func(_ uuid.UUID, s model.Session) bool { return s.UserID == uid })
+	maps.DeleteFunc(r.s.refreshTokens, func(_ uuid.UUID, t model.RefreshToken) bool { return t.UserID == uid })
+	delete(r.s.mfaFactors, uid)
+	maps.DeleteFunc(r.s.mfaChallenges, func(_ uuid.UUID, c model.MFAChallenge) bool { return c.UserID == uid })
+	maps.DeleteFunc(r.s.recoveryCodes, func(_ uuid.UUID, c model.MFARecoveryCode) bool { return c.UserID == uid })
+	maps.DeleteFunc(r.s.resetTokens, func(_ uuid.UUID, t model.PasswordResetToken) bool { return t.UserID == uid })
+	maps.DeleteFunc(r.s.consents, func(k consentKey, _ model.OAuthConsent) bool { return k.userID == uid })
+	maps.DeleteFunc(r.s.authCodes, func(_ uuid.UUID, c model.OAuthAuthorizationCode) bool { return c.UserID == uid })
+	return nil
+}
+
+// containsFunc reports whether a record of m matches.
+func containsFunc[K comparable, V any](m map[K]V, match func(K, V) bool) bool {
+	for k, v := range m {
+		if match(k, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserRecords returns the names of the tables that still hold records of a
+// user, so that tests can check what deleting an account leaves behind.
+func (r *Repository) UserRecords(userID uuid.UUID) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tables := []struct {
+		name  string
+		found bool
+	}{
+		{"credentials", containsFunc(r.s.credentials, func(id uuid.UUID, _ model.Credential) bool { return id == userID })},
+		{"user_roles", containsFunc(r.s.userRoles, func(k userRoleKey, _ model.UserRole) bool { return k.userID == userID })},
+		{"api_keys", containsFunc(r.s.apiKeys, func(_ uuid.UUID, k model.APIKey) bool { return k.UserID == userID })},
+		{"sessions", containsFunc(r.s.sessions, func(_ uuid.UUID, s model.Session) bool { return s.UserID == userID })},
+		{"refresh_tokens", containsFunc(r.s.refreshTokens, func(_ uuid.UUID, t model.RefreshToken) bool { return t.UserID == userID })},
+		{"mfa_factors", containsFunc(r.s.mfaFactors, func(id uuid.UUID, _ model.MFAFactor) bool { return id == userID })},
+		{"mfa_challenges", containsFunc(r.s.mfaChallenges, func(_ uuid.UUID, c model.MFAChallenge) bool { return c.UserID == userID })},
+		{"mfa_recovery_codes", containsFunc(r.s.recoveryCodes, func(_ uuid.UUID, c model.MFARecoveryCode) bool { return c.UserID == userID })},
+		{"password_reset_tokens", containsFunc(r.s.resetTokens, func(_ uuid.UUID, t model.PasswordResetToken) bool { return t.UserID == userID })},
+		{"oauth_consents", containsFunc(r.s.consents, func(k consentKey, _ model.OAuthConsent) bool { return k.userID == userID })},
+		{"oauth_authorization_codes", containsFunc(r.s.authCodes, func(_ uuid.UUID, c model.OAuthAuthorizationCode) bool { return c.UserID == userID })},
+	}
+
+	var names []string
+	for _, t := range tables {
+		if t.found {
+			names = append(names, t.name)
+		}
+	}
+	return names
+}
+
+// updateCredential applies fn to a credential and reports whether it exists.
+func (r *Repository) updateCredential(id uuid.UUID, fn func(c *model.Credential) bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.s.credentials[id]
+	if !ok || !fn(&c) {
+		return false
+	}
+	r.s.credentials[id] = c
+	return true
+}
+
+// UpdateStatus sets the status of a credential.
+func (r *Repository) UpdateStatus(_ context.Context, id uuid.UUID, status string) error {
+	if !r.updateCredential(id, func(c *model.Credential) bool { c.Status = status; return true }) {
+		return repository.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateRefreshToken inserts a new refresh token.
+func (r *Repository) CreateRefreshToken(_ context.Context, token *model.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token.ID = newID(token.ID)
+	token.CreatedAt = createdAt(token.CreatedAt)
+	for _, t := range r.s.refreshTokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicateKey
+		}
+	}
+	r.s.refreshTokens[token.ID] = *token
+	return nil
+}
+
+// FindRefreshTokenByHash retrieves a refresh token by its hash.
+func (r *Repository) FindRefreshTokenByHash(_ context.Context, tokenHash string) (*model.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.s.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+// RevokeRefreshToken revokes a refresh token and reports false if it was already revoked.
+func (r *Repository) RevokeRefreshToken(_ context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.s.refreshTokens[id]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+	t.RevokedAt = timePtr(time.Now())
+	r.s.refreshTokens[id] = t
+	return true, nil
+}
+
+// revokeRefreshTokens revokes the refresh tokens that match.
+func (r *Repository) revokeRefreshTokens(match func(t model.RefreshToken) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.s.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = timePtr(now)
+			r.s.refreshTokens[id] = t
+		}
+	}
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token of a family.
+func (r *Repository) RevokeRefreshTokenFamily(_ context.Context, familyID uuid.UUID) error {
+	r.revokeRefreshTokens(func(t model.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+// RevokeRefreshTokensByUser revokes every refresh token of a user.
+func (r *Repository) RevokeRefreshTokensByUser(_ context.Context, userID uuid.UUID) error {
+	r.revokeRefreshTokens(func(t model.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
@@ -0,0 +1,98 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+// RevokeToken records an access token as revoked. Revoking it twice is a no-op.
+func (r *Repository) RevokeToken(_ context.Context, token *model.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.s.revokedTokens[token.JTI]; ok {
+		return nil
+	}
+	token.CreatedAt = createdAt(token.CreatedAt)
+	r.s.revokedTokens[token.JTI] = *token
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token was revoked.
+func (r *Repository) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.s.revokedTokens[jti]
+	return ok, nil
+}
+
+// ListRevokedTokens returns the unexpired access tokens revoked since the given time, oldest first.
+func (r *Repository) ListRevokedTokens(_ context.Context, since, now time.Time) ([]model.RevokedToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var tokens []model.RevokedToken
+	for _, t := range r.s.revokedTokens {
+		if !t.CreatedAt.Before(since) && t.ExpiresAt.After(now) {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// ListUserRevocations returns the revocation cutoffs set since the given time.
+func (r *Repository) ListUserRevocations(_ context.Context, since time.Time) ([]model.UserRevocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var revocations []model.UserRevocation
+	for _, u := range r.s.userRevocations {
+		if !u.RevokedBefore.Before(since) {
+			revocations = append(revocations, u)
+		}
+	}
+	sort.Slice(revocations, func(i, j int) bool { return revocations[i].RevokedBefore.Before(revocations[j].RevokedBefore) })
+	return revocations, nil
+}
+
+// RevokeUserTokens revokes every access token issued to a user before the given time.
+func (r *Repository) RevokeUserTokens(_ context.Context, userID uuid.UUID, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.s.userRevocations[userID] = model.UserRevocation{UserID: userID, RevokedBefore: before}
+	return nil
+}
+
+// FindUserRevocation retrieves the revocation cutoff of a user.
+func (r *Repository) FindUserRevocation(_ context.Context, userID uuid.UUID) (*model.UserRevocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.s.userRevocations[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+// DeleteExpiredRevocations removes revocations that can no longer match a valid
+// token, together with expired refresh tokens.
+func (r *Repository) DeleteExpiredRevocations(_ context.Context, now time.Time, maxTokenAge time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.revokedTokens, func(_ string, t model.RevokedToken) bool { return t.ExpiresAt.Before(now) })
+	maps.DeleteFunc(r.s.userRevocations, func(_ uuid.UUID, u model.UserRevocation) bool {
+		return u.RevokedBefore.Before(now.Add(-maxTokenAge))
+	})
+	maps.DeleteFunc(r.s.refreshTokens, func(_ uuid.UUID, t model.RefreshToken) bool { return t.ExpiresAt.Before(now) })
+	return nil
+}
@@ -0,0 +1,140 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// CreateSession inserts a new session.
+func (r *Repository) CreateSession(_ context.Context, session *model.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.s.sessions[session.ID]; ok {
+		return ErrDuplicateKey
+	}
+	session.CreatedAt = createdAt(session.CreatedAt)
+	r.s.sessions[session.ID] = *session
+	return nil
+}
+
+// FindSession retrieves a session by its ID.
+func (r *Repository) FindSession(_ context.Context, id uuid.UUID) (*model.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// ListSessions returns the live sessions of a user, most recently used first.
+func (r *Repository) ListSessions(_ context.Context, userID uuid.UUID, now time.Time) ([]model.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sessions []model.Session
+	for _, s := range r.s.sessions {
+		if s.UserID == userID && s.RevokedAt == nil && s.ExpiresAt.After(now) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+// updateSession applies fn to a session and reports whether it exists and fn accepted it.
+func (r *Repository) updateSession(id uuid.UUID, fn func(s *model.Session) bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.s.sessions[id]
+	if !ok || !fn(&s) {
+		return false
+	}
+	r.s.sessions[id] = s
+	return true
+}
+
+// TouchSession records the use of a session when the last one is older than resolution.
+func (r *Repository) TouchSession(_ context.Context, id uuid.UUID, now time.Time, resolution time.Duration) error {
+	r.updateSession(id, func(s *model.Session) bool {
+		if !s.LastUsedAt.Before(now.Add(-resolution)) {
+			return false
+		}
+		s.LastUsedAt = now
+		return true
+	})
+	return nil
+}
+
+// ExtendSession records a refresh of a session and moves its expiry.
+func (r *Repository) ExtendSession(_ context.Context, id uuid.UUID, now, expiresAt time.Time) error {
+	r.updateSession(id, func(s *model.Session) bool {
+		s.LastUsedAt = now
+		s.ExpiresAt = expiresAt
+		return true
+	})
+	return nil
+}
+
+// RevokeSession revokes a live session of a user.
+func (r *Repository) RevokeSession(_ context.Context, userID, id uuid.UUID, now time.Time) error {
+	if !r.updateSession(id, func(s *model.Session) bool {
+		if s.UserID != userID || s.RevokedAt != nil {
+			return false
+		}
+		s.RevokedAt = timePtr(now)
+		return true
+	}) {
+		return repository.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeSessionsByUser revokes every session of a user.
+func (r *Repository) RevokeSessionsByUser(_ context.Context, userID uuid.UUID, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, s := range r.s.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = timePtr(now)
+			r.s.sessions[id] = s
+		}
+	}
+	return nil
+}
+
+// ListRevokedSessions returns the IDs of the sessions revoked since the given time.
+func (r *Repository) ListRevokedSessions(_ context.Context, since time.Time) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []uuid.UUID{}
+	for id, s := range r.s.sessions {
+		if s.RevokedAt != nil && !s.RevokedAt.Before(since) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteExpiredSessions removes sessions that expired, or were revoked before revokedBefore.
+func (r *Repository) DeleteExpiredSessions(_ context.Context, now, revokedBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.sessions, func(_ uuid.UUID, s model.Session) bool {
+		return s.ExpiresAt.Before(now) || (s.RevokedAt != nil && s.RevokedAt.Before(revokedBefore))
+	})
+	return nil
+}
@@ -0,0 +1,54 @@
+package repositorytest
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+)
+
+// CreateSigningKey inserts a signing key and retires the active ones until retiredUntil.
+func (r *Repository) CreateSigningKey(_ context.Context, key *model.SigningKey, retiredUntil time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, k := range r.s.signingKeys {
+		if k.RotatedAt == nil {
+			k.RotatedAt = timePtr(now)
+			k.ExpiresAt = timePtr(retiredUntil)
+			r.s.signingKeys[id] = k
+		}
+	}
+	key.CreatedAt = createdAt(key.CreatedAt)
+	r.s.signingKeys[key.ID] = *key
+	return nil
+}
+
+// ListSigningKeys returns the signing keys that can still verify tokens, newest first.
+func (r *Repository) ListSigningKeys(_ context.Context, now time.Time) ([]model.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []model.SigningKey
+	for _, k := range r.s.signingKeys {
+		if k.ExpiresAt == nil || k.ExpiresAt.After(now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// DeleteExpiredSigningKeys removes signing keys whose grace period has ended.
+func (r *Repository) DeleteExpiredSigningKeys(_ context.Context, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maps.DeleteFunc(r.s.signingKeys, func(_ string, k model.SigningKey) bool {
+		return k.ExpiresAt != nil && k.ExpiresAt.Before(now)
+	})
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokeToken records an access token as revoked. Revoking the same token twice is a no-op.
+func (r *repository) RevokeToken(ctx context.Context, token *model.RevokedToken) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(token).Error
+}
+
+// IsTokenRevoked reports whether the access token with the given ID has been revoked.
+func (r *repository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&model.RevokedToken{}).
+		Where("jti = ?", jti).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListRevokedTokens returns the access tokens revoked since the given time
+// that have not expired yet, oldest first.
+func (r *repository) ListRevokedTokens(ctx context.Context, since, now time.Time) ([]model.RevokedToken, error) {
+	var tokens []model.RevokedToken
+	if err := r.db.WithContext(ctx).
+		Where("created_at >= ? AND expires_at > ?", since, now).
+		Order("created_at").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ListUserRevocations returns the revocation cutoffs set since the given time.
+func (r *repository) ListUserRevocations(ctx context.Context, since time.Time) ([]model.UserRevocation, error) {
+	var revocations []model.UserRevocation
+	if err := r.db.WithContext(ctx).
+		Where("revoked_before >= ?", since).
+		Order("revoked_before").
+		Find(&revocations).Error; err != nil {
+		return nil, err
+	}
+	return revocations, nil
+}
+
+// RevokeUserTokens revokes every access token issued to the user before the given time.
+func (r *repository) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_before"}),
+		}).
+		Create(&model.UserRevocation{UserID: userID, RevokedBefore: before}).Error
+}
+
+// FindUserRevocation retrieves the revocation cutoff of a user, if there is one.
+func (r *repository) FindUserRevocation(ctx context.Context, userID uuid.UUID) (*model.UserRevocation, error) {
+	var revocation model.UserRevocation
+
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&revocation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &revocation, nil
+}
+
+// DeleteExpiredRevocations removes revocation records that can no longer match a
+// valid token, together with refresh tokens that have expired.
+func (r *repository) DeleteExpiredRevocations(ctx context.Context, now time.Time, maxTokenAge time.Duration) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", now).Delete(&model.RevokedToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("revoked_before < ?", now.Add(-maxTokenAge)).Delete(&model.UserRevocation{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at < ?", now).Delete(&model.RefreshToken{}).Error
+	})
+}
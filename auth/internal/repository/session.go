@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateSession inserts a new session.
+func (r *repository) CreateSession(ctx context.Context, session *model.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+// FindSession retrieves a session by its ID.
+func (r *repository) FindSession(ctx context.Context, id uuid.UUID) (*model.Session, error) {
+	var session model.Session
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ListSessions returns the sessions of a user that are neither revoked nor
+// expired, most recently used first.
+func (r *repository) ListSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// TouchSession records that a session was used. The timestamp is only
+// written when the previous one is older than resolution.
+func (r *repository) TouchSession(ctx context.Context, id uuid.UUID, now time.Time, resolution time.Duration) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("id = ? AND last_used_at < ?", id, now.Add(-resolution)).
+		Update("last_used_at", now).Error
+}
+
+// ExtendSession records that a session was refreshed and moves its expiry to
+// that of the new refresh token.
+func (r *repository) ExtendSession(ctx context.Context, id uuid.UUID, now, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": now, "expires_at": expiresAt}).Error
+}
+
+// RevokeSession revokes a session of a user. It returns ErrRecordNotFound if
+// the user has no such session, or it was already revoked.
+func (r *repository) RevokeSession(ctx context.Context, userID, id uuid.UUID, now time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeSessionsByUser revokes every session of a user.
+func (r *repository) RevokeSessionsByUser(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// ListRevokedSessions returns the IDs of the sessions revoked since the given time.
+func (r *repository) ListRevokedSessions(ctx context.Context, since time.Time) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	if err := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("revoked_at >= ?", since).
+		Order("revoked_at").
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// DeleteExpiredSessions removes sessions that expired, or were revoked before
+// revokedBefore. A missing session counts as revoked, so the tokens of removed
+// sessions stay rejected.
+func (r *repository) DeleteExpiredSessions(ctx context.Context, now, revokedBefore time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("expires_at < ? OR revoked_at < ?", now, revokedBefore).
+		Delete(&model.Session{}).Error
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"gorm.io/gorm"
+)
+
+// CreateSigningKey inserts a new signing key and retires every key that is
+// still active, keeping them valid for verification until retiredUntil.
+func (r *repository) CreateSigningKey(ctx context.Context, key *model.SigningKey, retiredUntil time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.SigningKey{}).
+			Where("rotated_at IS NULL").
+			Updates(map[string]interface{}{
+				"rotated_at": time.Now(),
+				"expires_at": retiredUntil,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+}
+
+// ListSigningKeys retrieves every signing key that can still verify tokens, newest first.
+func (r *repository) ListSigningKeys(ctx context.Context, now time.Time) ([]model.SigningKey, error) {
+	var keys []model.SigningKey
+
+	if err := r.db.WithContext(ctx).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// DeleteExpiredSigningKeys removes signing keys whose grace period has ended.
+func (r *repository) DeleteExpiredSigningKeys(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&model.SigningKey{}).Error
+}
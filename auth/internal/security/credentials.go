@@ -29,3 +29,24 @@ func NewCredentials(cfg *config.Config) credentials.TransportCredentials {
 		ClientAuth:   tls.RequireAndVerifyClientCert,
 	})
 }
+
+// NewClientCredentials returns the credentials used to call other gRPC services.
+// The server certificate is presented as the client certificate.
+func NewClientCredentials(cfg *config.Config) credentials.TransportCredentials {
+	caCert, err := os.ReadFile(cfg.CACertPath)
+	if err != nil {
+		log.Fatalf("Failed to load CA cert: %v", err)
+	}
+	certPool := x509.NewCertPool()
+	certPool.AppendCertsFromPEM(caCert)
+
+	clientCert, err := tls.LoadX509KeyPair(cfg.ServerCertPath, cfg.ServerKeyPath)
+	if err != nil {
+		log.Fatalf("Failed to load client cert: %v", err)
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      certPool,
+	})
+}
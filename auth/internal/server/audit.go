@@ -0,0 +1,39 @@
+package server
+
+import (
+	pb "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
+)
+
+// AuditedMethods lists the state-changing RPCs that are written to the audit
+// log. Logins and token refreshes are recorded in the login history instead.
+var AuditedMethods = map[string]audit.TargetFunc{
+	pb.AuthService_Register_FullMethodName:                nil,
+	pb.AuthService_DeleteUser_FullMethodName:              nil,
+	pb.AuthService_Logout_FullMethodName:                  nil,
+	pb.AuthService_RevokeAllSessions_FullMethodName:       nil,
+	pb.AuthService_DeleteAccount_FullMethodName:           nil,
+	pb.AuthService_RequestPasswordReset_FullMethodName:    nil,
+	pb.AuthService_ConfirmPasswordReset_FullMethodName:    nil,
+	pb.AuthService_VerifyEmail_FullMethodName:             nil,
+	pb.AuthService_ResendVerificationEmail_FullMethodName: nil,
+	pb.AuthService_ChangePassword_FullMethodName:          nil,
+	pb.AuthService_UnlockAccount_FullMethodName:           nil,
+	pb.AuthService_EnrollMFA_FullMethodName:               nil,
+	pb.AuthService_ConfirmMFA_FullMethodName:              nil,
+	pb.AuthService_DisableMFA_FullMethodName:              nil,
+	pb.AuthService_AssignRole_FullMethodName:              nil,
+	pb.AuthService_RevokeRole_FullMethodName:              nil,
+	pb.AuthService_CreateOAuthClient_FullMethodName:       oauthClientTarget,
+	pb.AuthService_CreateAPIKey_FullMethodName:            nil,
+	pb.AuthService_RevokeAPIKey_FullMethodName:            nil,
+	pb.AuthService_RevokeSession_FullMethodName:           nil,
+}
+
+// oauthClientTarget returns the ID of the OAuth client that was registered.
+func oauthClientTarget(_, resp any) string {
+	if res, ok := resp.(*pb.CreateOAuthClientResponse); ok {
+		return res.ClientId
+	}
+	return ""
+}
@@ -4,14 +4,29 @@ import (
 	"context"
 	"errors"
 	"log"
+	"time"
 
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
 	"github.com/PakornBank/go-grpc-example/auth/internal/service"
 	pb "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Metadata keys under which the gateway forwards the IP and user agent of the client.
+const (
+	clientIPKey        = "x-client-ip"
+	clientUserAgentKey = "x-client-user-agent"
+)
+
+// oauthErrorDomain is the ErrorInfo domain of errors that carry an OAuth error code.
+const oauthErrorDomain = "oauth2"
+
 // Server handles authentication gRPC requests.
 type Server struct {
 	pb.UnimplementedAuthServiceServer
@@ -27,11 +42,14 @@ func NewServer(service service.Service) *Server {
 
 // Register handles user registration.
 func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
-	userID, err := s.service.Register(ctx, req.Email, req.Password)
+	userID, err := s.service.Register(ctx, req.Email, req.Password, req.FullName, req.Locale)
 	if err != nil {
 		if errors.Is(err, service.ErrEmailAlreadyExists) {
 			return nil, status.Error(codes.AlreadyExists, "email already exists")
 		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			return nil, weakPassword(err)
+		}
 		log.Printf("register error: %v", err)
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -40,16 +58,36 @@ func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.Reg
 }
 
 func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
-	token, err := s.service.Login(ctx, req.Email, req.Password)
+	result, err := s.service.Login(ctx, req.Email, req.Password, clientInfo(ctx))
 	if err != nil {
+		var locked *service.LockedError
+		if errors.As(err, &locked) {
+			return nil, retryLater("too many failed login attempts", locked.RetryAfter)
+		}
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
 		}
+		if errors.Is(err, service.ErrAccountPending) {
+			return nil, status.Error(codes.FailedPrecondition, "account is being created")
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "email not verified")
+		}
 		log.Printf("login error: %v", err)
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 
-	return &pb.LoginResponse{Token: token}, nil
+	if result.MFAToken != "" {
+		return &pb.LoginResponse{
+			MfaRequired: true,
+			MfaToken:    result.MFAToken,
+		}, nil
+	}
+
+	return &pb.LoginResponse{
+		Token:        result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+	}, nil
 }
 
 func (s *Server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
@@ -66,11 +104,17 @@ func (s *Server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*em
 }
 
 func (s *Server) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (*pb.VerifyTokenResponse, error) {
-	userID, email, valid, err := s.service.VerifyToken(req.Token)
+	userID, email, valid, err := s.service.VerifyToken(ctx, req.Token)
 	if err != nil {
 		if errors.Is(err, service.ErrTokenExpired) {
 			return nil, status.Error(codes.Unauthenticated, "token expired")
 		}
+		if errors.Is(err, service.ErrTokenRevoked) {
+			return nil, status.Error(codes.Unauthenticated, "token revoked")
+		}
+		if errors.Is(err, service.ErrClientToken) {
+			return nil, status.Error(codes.Unauthenticated, "token was issued to an OAuth client")
+		}
 		log.Printf("JWT verification failed: %v", err)
 		return nil, status.Error(codes.Unauthenticated, "invalid token")
 	}
@@ -81,3 +125,764 @@ func (s *Server) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (*
 		Valid:  valid,
 	}, nil
 }
+
+// RefreshToken exchanges a refresh token for a new token pair.
+func (s *Server) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	tokens, err := s.service.RefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTokenExpired):
+			return nil, status.Error(codes.Unauthenticated, "refresh token expired")
+		case errors.Is(err, service.ErrTokenReused):
+			return nil, status.Error(codes.Unauthenticated, "refresh token reused")
+		case errors.Is(err, service.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		log.Printf("refresh token error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.RefreshTokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// Logout revokes an access token and the refresh tokens issued with it.
+func (s *Server) Logout(ctx context.Context, req *pb.LogoutRequest) (*emptypb.Empty, error) {
+	if err := s.service.Logout(ctx, req.Token, req.RefreshToken); err != nil {
+		if errors.Is(err, service.ErrTokenExpired) {
+			return nil, status.Error(codes.Unauthenticated, "token expired")
+		}
+		if errors.Is(err, service.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		log.Printf("logout error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// RevokeAllSessions revokes every token issued to a user.
+func (s *Server) RevokeAllSessions(ctx context.Context, req *pb.RevokeAllSessionsRequest) (*emptypb.Empty, error) {
+	if err := s.service.RevokeAllSessions(ctx, req.UserId); err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		}
+		log.Printf("revoke all sessions error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// GetJWKS returns the public keys that verify access tokens.
+func (s *Server) GetJWKS(_ context.Context, _ *pb.GetJWKSRequest) (*pb.GetJWKSResponse, error) {
+	jwks := s.service.PublicKeys()
+
+	res := &pb.GetJWKSResponse{Keys: make([]*pb.JSONWebKey, 0, len(jwks))}
+	for _, jwk := range jwks {
+		res.Keys = append(res.Keys, &pb.JSONWebKey{
+			Kty: jwk.Kty,
+			Kid: jwk.Kid,
+			Use: jwk.Use,
+			Alg: jwk.Alg,
+			N:   jwk.N,
+			E:   jwk.E,
+			Crv: jwk.Crv,
+			X:   jwk.X,
+		})
+	}
+
+	return res, nil
+}
+
+// ListRevocations returns the revocations since the given time, so that the
+// gateway can reject revoked tokens it verifies locally.
+func (s *Server) ListRevocations(ctx context.Context, req *pb.ListRevocationsRequest) (*pb.ListRevocationsResponse, error) {
+	var since time.Time
+	if req.Since != nil {
+		since = req.Since.AsTime()
+	}
+
+	revocations, err := s.service.ListRevocations(ctx, since)
+	if err != nil {
+		log.Printf("list revocations error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	res := &pb.ListRevocationsResponse{
+		Tokens: make([]*pb.RevokedToken, 0, len(revocations.Tokens)),
+		Users:  make([]*pb.UserRevocation, 0, len(revocations.Users)),
+		AsOf:   timestamppb.New(revocations.AsOf),
+	}
+	for _, token := range revocations.Tokens {
+		res.Tokens = append(res.Tokens, &pb.RevokedToken{
+			Jti:       token.JTI,
+			ExpiresAt: timestamppb.New(token.ExpiresAt),
+		})
+	}
+	for _, user := range revocations.Users {
+		res.Users = append(res.Users, &pb.UserRevocation{
+			UserId:        user.UserID.String(),
+			RevokedBefore: timestamppb.New(user.RevokedBefore),
+		})
+	}
+	for _, id := range revocations.Sessions {
+		res.SessionIds = append(res.SessionIds, id.String())
+	}
+
+	return res, nil
+}
+
+// DeleteAccount deletes a user account from every service after checking its password.
+func (s *Server) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (*emptypb.Empty, error) {
+	if err := s.service.DeleteAccount(ctx, req.UserId, req.Password); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.PermissionDenied, "invalid password")
+		case errors.Is(err, service.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("delete account error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// RequestPasswordReset sends a password reset token to the given email if it is registered.
+func (s *Server) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*emptypb.Empty, error) {
+	if err := s.service.RequestPasswordReset(ctx, req.Email, req.Locale); err != nil {
+		log.Printf("request password reset error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ConfirmPasswordReset sets a new password using a password reset token.
+func (s *Server) ConfirmPasswordReset(ctx context.Context, req *pb.ConfirmPasswordResetRequest) (*emptypb.Empty, error) {
+	if err := s.service.ConfirmPasswordReset(ctx, req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired reset token")
+		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			return nil, weakPassword(err)
+		}
+		log.Printf("confirm password reset error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// VerifyEmail marks the email of a user as verified using a verification token.
+func (s *Server) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*emptypb.Empty, error) {
+	if err := s.service.VerifyEmail(ctx, req.Token); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidVerificationToken):
+			return nil, status.Error(codes.InvalidArgument, "invalid verification token")
+		case errors.Is(err, service.ErrTokenExpired):
+			return nil, status.Error(codes.InvalidArgument, "verification token expired")
+		}
+		log.Printf("verify email error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ResendVerificationEmail sends a new verification email to an unverified account.
+func (s *Server) ResendVerificationEmail(ctx context.Context, req *pb.ResendVerificationEmailRequest) (*emptypb.Empty, error) {
+	if err := s.service.ResendVerificationEmail(ctx, req.Email, req.Locale); err != nil {
+		log.Printf("resend verification email error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ChangePassword replaces the password of a user after checking the current one.
+func (s *Server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	tokens, err := s.service.ChangePassword(ctx, req.UserId, req.OldPassword, req.NewPassword, req.RevokeOtherSessions, clientInfo(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.PermissionDenied, "invalid password")
+		case errors.Is(err, service.ErrWeakPassword):
+			return nil, weakPassword(err)
+		case errors.Is(err, service.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("change password error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	if tokens == nil {
+		return &pb.ChangePasswordResponse{}, nil
+	}
+
+	return &pb.ChangePasswordResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// UnlockAccount clears the failed logins and lockout of an email. It is meant
+// for administrators.
+func (s *Server) UnlockAccount(ctx context.Context, req *pb.UnlockAccountRequest) (*emptypb.Empty, error) {
+	if err := s.service.UnlockAccount(ctx, req.Email); err != nil {
+		log.Printf("unlock account error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// clientInfo returns the client IP and user agent forwarded by the gateway, if any.
+func clientInfo(ctx context.Context) service.ClientInfo {
+	var client service.ClientInfo
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return client
+	}
+	if values := md.Get(clientIPKey); len(values) > 0 {
+		client.IP = values[0]
+	}
+	if values := md.Get(clientUserAgentKey); len(values) > 0 {
+		client.UserAgent = values[0]
+	}
+	return client
+}
+
+// retryLater returns a ResourceExhausted error that tells the client when to retry.
+func retryLater(msg string, retryAfter time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, msg).WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, msg)
+	}
+	return st.Err()
+}
+
+// EnrollMFA creates a new TOTP secret for a user.
+func (s *Server) EnrollMFA(ctx context.Context, req *pb.EnrollMFARequest) (*pb.EnrollMFAResponse, error) {
+	enrollment, err := s.service.EnrollMFA(ctx, req.UserId)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMFAAlreadyEnabled):
+			return nil, status.Error(codes.FailedPrecondition, "MFA is already enabled")
+		case errors.Is(err, service.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("enroll MFA error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.EnrollMFAResponse{
+		Secret:     enrollment.Secret,
+		OtpauthUri: enrollment.URI,
+	}, nil
+}
+
+// ConfirmMFA enables MFA with a first code and returns the recovery codes.
+func (s *Server) ConfirmMFA(ctx context.Context, req *pb.ConfirmMFARequest) (*pb.ConfirmMFAResponse, error) {
+	recoveryCodes, err := s.service.ConfirmMFA(ctx, req.UserId, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode):
+			return nil, status.Error(codes.InvalidArgument, "invalid MFA code")
+		case errors.Is(err, service.ErrMFANotEnrolled), errors.Is(err, service.ErrMFAAlreadyEnabled):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, service.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("confirm MFA error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.ConfirmMFAResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// DisableMFA turns MFA off after checking the password of the user.
+func (s *Server) DisableMFA(ctx context.Context, req *pb.DisableMFARequest) (*emptypb.Empty, error) {
+	if err := s.service.DisableMFA(ctx, req.UserId, req.Password); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.PermissionDenied, "invalid password")
+		case errors.Is(err, service.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("disable MFA error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// VerifyMFA completes a login that requires MFA.
+func (s *Server) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (*pb.LoginResponse, error) {
+	tokens, err := s.service.VerifyMFA(ctx, req.MfaToken, req.Code, clientInfo(ctx))
+	if err != nil {
+		var locked *service.LockedError
+		if errors.As(err, &locked) {
+			return nil, retryLater("too many failed login attempts", locked.RetryAfter)
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode):
+			return nil, status.Error(codes.Unauthenticated, "invalid MFA code")
+		case errors.Is(err, service.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired MFA token")
+		}
+		log.Printf("verify MFA error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// AssignRole assigns a role to a user. It is meant for administrators.
+func (s *Server) AssignRole(ctx context.Context, req *pb.AssignRoleRequest) (*emptypb.Empty, error) {
+	if err := s.service.AssignRole(ctx, req.UserId, req.Role); err != nil {
+		return nil, roleError("assign role", err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// RevokeRole removes a role from a user. It is meant for administrators.
+func (s *Server) RevokeRole(ctx context.Context, req *pb.RevokeRoleRequest) (*emptypb.Empty, error) {
+	if err := s.service.RevokeRole(ctx, req.UserId, req.Role); err != nil {
+		return nil, roleError("revoke role", err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListUserRoles returns the roles of a user.
+func (s *Server) ListUserRoles(ctx context.Context, req *pb.ListUserRolesRequest) (*pb.ListUserRolesResponse, error) {
+	roles, err := s.service.ListUserRoles(ctx, req.UserId)
+	if err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		}
+		log.Printf("list user roles error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.ListUserRolesResponse{Roles: roles}, nil
+}
+
+// CheckPermission reports whether any role of a user grants a permission.
+func (s *Server) CheckPermission(ctx context.Context, req *pb.CheckPermissionRequest) (*pb.CheckPermissionResponse, error) {
+	allowed, err := s.service.CheckPermission(ctx, req.UserId, req.Permission)
+	if err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		}
+		log.Printf("check permission error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.CheckPermissionResponse{Allowed: allowed}, nil
+}
+
+// roleError maps the errors of AssignRole and RevokeRole to gRPC errors.
+func roleError(op string, err error) error {
+	switch {
+	case errors.Is(err, service.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, service.ErrRoleNotFound):
+		return status.Error(codes.NotFound, "role not found")
+	}
+	log.Printf("%s error: %v", op, err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// CreateOAuthClient registers an OAuth client. It is meant for administrators.
+func (s *Server) CreateOAuthClient(ctx context.Context, req *pb.CreateOAuthClientRequest) (*pb.CreateOAuthClientResponse, error) {
+	registration, err := s.service.CreateOAuthClient(ctx, service.OAuthClientSpec{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectUris,
+		GrantTypes:   req.GrantTypes,
+		Scope:        req.Scope,
+		Confidential: req.Confidential,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidClientMetadata) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Printf("create OAuth client error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.CreateOAuthClientResponse{
+		ClientId:     registration.ClientID,
+		ClientSecret: registration.ClientSecret,
+	}, nil
+}
+
+// Authorize handles an authorization request made by a signed in user.
+func (s *Server) Authorize(ctx context.Context, req *pb.AuthorizeRequest) (*pb.AuthorizeResponse, error) {
+	result, err := s.service.Authorize(ctx, service.AuthorizeRequest{
+		UserID:              req.UserId,
+		ClientID:            req.ClientId,
+		ResponseType:        req.ResponseType,
+		RedirectURI:         req.RedirectUri,
+		Scope:               req.Scope,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		Prompt:              req.Prompt,
+		Consent:             service.Consent(req.Consent),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, oauthError("authorize", err)
+	}
+
+	return &pb.AuthorizeResponse{
+		Code:            result.Code,
+		Scope:           result.Scope,
+		ConsentRequired: result.ConsentRequired,
+		ClientName:      result.ClientName,
+	}, nil
+}
+
+// Token handles a request to the OAuth token endpoint.
+func (s *Server) Token(ctx context.Context, req *pb.TokenRequest) (*pb.TokenResponse, error) {
+	token, err := s.service.Token(ctx, service.TokenRequest{
+		GrantType:    req.GrantType,
+		ClientID:     req.ClientId,
+		ClientSecret: req.ClientSecret,
+		Code:         req.Code,
+		RedirectURI:  req.RedirectUri,
+		CodeVerifier: req.CodeVerifier,
+		RefreshToken: req.RefreshToken,
+		Scope:        req.Scope,
+	})
+	if err != nil {
+		return nil, oauthError("token", err)
+	}
+
+	return &pb.TokenResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(token.ExpiresIn.Seconds()),
+		RefreshToken: token.RefreshToken,
+		Scope:        token.Scope,
+		IdToken:      token.IDToken,
+	}, nil
+}
+
+// GetOpenIDConfiguration returns the OpenID Connect provider metadata known to the auth service.
+func (s *Server) GetOpenIDConfiguration(_ context.Context, _ *pb.GetOpenIDConfigurationRequest) (*pb.GetOpenIDConfigurationResponse, error) {
+	config, err := s.service.OpenIDConfiguration()
+	if err != nil {
+		if errors.Is(err, service.ErrOIDCNotConfigured) {
+			return nil, status.Error(codes.FailedPrecondition, "OpenID Connect is not enabled")
+		}
+		log.Printf("get OpenID configuration error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.GetOpenIDConfigurationResponse{
+		Issuer:                  config.Issuer,
+		IdTokenSigningAlgValues: config.SigningAlgorithms,
+	}, nil
+}
+
+// UserInfo returns the claims about the user of an access token issued for the openid scope.
+func (s *Server) UserInfo(ctx context.Context, req *pb.UserInfoRequest) (*pb.UserInfoResponse, error) {
+	info, err := s.service.UserInfo(ctx, req.AccessToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInsufficientScope):
+			return nil, status.Error(codes.PermissionDenied, "token was not issued for the openid scope")
+		case errors.Is(err, service.ErrTokenExpired):
+			return nil, status.Error(codes.Unauthenticated, "token expired")
+		case errors.Is(err, service.ErrTokenRevoked):
+			return nil, status.Error(codes.Unauthenticated, "token revoked")
+		}
+		log.Printf("user info error: %v", err)
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return &pb.UserInfoResponse{
+		Sub:           info.Subject,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Scope:         info.Scope,
+	}, nil
+}
+
+// IntrospectToken tells a confidential client whether an access token is
+// active. Inactive tokens are not an error.
+func (s *Server) IntrospectToken(ctx context.Context, req *pb.IntrospectTokenRequest) (*pb.IntrospectTokenResponse, error) {
+	introspection, err := s.service.IntrospectToken(ctx, service.IntrospectionRequest{
+		ClientID:     req.ClientId,
+		ClientSecret: req.ClientSecret,
+		Token:        req.Token,
+	})
+	if err != nil {
+		return nil, oauthError("introspect token", err)
+	}
+	if !introspection.Active {
+		return &pb.IntrospectTokenResponse{}, nil
+	}
+
+	return &pb.IntrospectTokenResponse{
+		Active:    true,
+		Sub:       introspection.Subject,
+		Exp:       introspection.ExpiresAt.Unix(),
+		Iat:       introspection.IssuedAt.Unix(),
+		Scope:     introspection.Scope,
+		ClientId:  introspection.ClientID,
+		TokenType: introspection.TokenType,
+	}, nil
+}
+
+// CreateAPIKey creates an API key for a user. The key is only returned here.
+func (s *Server) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	spec := service.APIKeySpec{
+		Name:  req.Name,
+		Scope: req.Scope,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.AsTime()
+		spec.ExpiresAt = &expiresAt
+	}
+
+	created, err := s.service.CreateAPIKey(ctx, req.UserId, spec)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		case errors.Is(err, service.ErrInvalidAPIKeySpec):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Printf("create API key error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.CreateAPIKeyResponse{
+		ApiKey: toAPIKeyProto(created.APIKey),
+		Key:    created.Key,
+	}, nil
+}
+
+// ListAPIKeys returns the API keys of a user that were not revoked.
+func (s *Server) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	keys, err := s.service.ListAPIKeys(ctx, req.UserId)
+	if err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		}
+		log.Printf("list API keys error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	res := &pb.ListAPIKeysResponse{ApiKeys: make([]*pb.APIKey, 0, len(keys))}
+	for i := range keys {
+		res.ApiKeys = append(res.ApiKeys, toAPIKeyProto(&keys[i]))
+	}
+	return res, nil
+}
+
+// RevokeAPIKey revokes an API key of a user.
+func (s *Server) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*emptypb.Empty, error) {
+	if err := s.service.RevokeAPIKey(ctx, req.UserId, req.Id); err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "API key not found")
+		}
+		log.Printf("revoke API key error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// VerifyAPIKey checks an API key and returns the user it authenticates.
+func (s *Server) VerifyAPIKey(ctx context.Context, req *pb.VerifyAPIKeyRequest) (*pb.VerifyAPIKeyResponse, error) {
+	identity, err := s.service.VerifyAPIKey(ctx, req.Key)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		case errors.Is(err, service.ErrAPIKeyExpired):
+			return nil, status.Error(codes.Unauthenticated, "API key expired")
+		case errors.Is(err, service.ErrEmailNotVerified):
+			return nil, status.Error(codes.FailedPrecondition, "email not verified")
+		}
+		log.Printf("verify API key error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &pb.VerifyAPIKeyResponse{
+		UserId: identity.UserID,
+		Email:  identity.Email,
+		KeyId:  identity.KeyID,
+		Scope:  identity.Scope,
+	}, nil
+}
+
+// ListSessions returns the sessions of a user that are neither revoked nor expired.
+func (s *Server) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	sessions, err := s.service.ListSessions(ctx, req.UserId)
+	if err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		}
+		log.Printf("list sessions error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	res := &pb.ListSessionsResponse{Sessions: make([]*pb.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		res.Sessions = append(res.Sessions, &pb.Session{
+			Id:         session.ID.String(),
+			UserAgent:  session.UserAgent,
+			IpAddress:  session.IPAddress,
+			CreatedAt:  timestamppb.New(session.CreatedAt),
+			LastUsedAt: timestamppb.New(session.LastUsedAt),
+			ExpiresAt:  timestamppb.New(session.ExpiresAt),
+		})
+	}
+	return res, nil
+}
+
+// RevokeSession signs a user out of one session.
+func (s *Server) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*emptypb.Empty, error) {
+	if err := s.service.RevokeSession(ctx, req.UserId, req.Id); err != nil {
+		if errors.Is(err, service.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+		log.Printf("revoke session error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// TouchSessions records the use of the sessions whose tokens the gateway verified locally.
+func (s *Server) TouchSessions(ctx context.Context, req *pb.TouchSessionsRequest) (*emptypb.Empty, error) {
+	if err := s.service.TouchSessions(ctx, req.Ids); err != nil {
+		log.Printf("touch sessions error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// GetLoginHistory returns a page of the login history of a user, newest first.
+func (s *Server) GetLoginHistory(ctx context.Context, req *pb.GetLoginHistoryRequest) (*pb.GetLoginHistoryResponse, error) {
+	events, nextPageToken, err := s.service.GetLoginHistory(ctx, req.UserId, int(req.PageSize), req.PageToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRecordNotFound):
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		case errors.Is(err, service.ErrInvalidPageToken):
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+		log.Printf("get login history error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	res := &pb.GetLoginHistoryResponse{
+		Events:        make([]*pb.LoginEvent, 0, len(events)),
+		NextPageToken: nextPageToken,
+	}
+	for _, event := range events {
+		res.Events = append(res.Events, &pb.LoginEvent{
+			Id:        event.ID.String(),
+			Success:   event.Success,
+			Reason:    event.Reason,
+			IpAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			NewDevice: event.NewDevice,
+			CreatedAt: timestamppb.New(event.CreatedAt),
+		})
+	}
+	return res, nil
+}
+
+// toAPIKeyProto converts an API key to its protobuf message.
+func toAPIKeyProto(key *model.APIKey) *pb.APIKey {
+	res := &pb.APIKey{
+		Id:        key.ID.String(),
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scope:     key.Scope,
+		CreatedAt: timestamppb.New(key.CreatedAt),
+	}
+	if key.ExpiresAt != nil {
+		res.ExpiresAt = timestamppb.New(*key.ExpiresAt)
+	}
+	if key.LastUsedAt != nil {
+		res.LastUsedAt = timestamppb.New(*key.LastUsedAt)
+	}
+	return res
+}
+
+// oauthError maps an OAuth error to a gRPC error whose ErrorInfo carries the
+// OAuth error code as its reason, and whether it may be sent to the redirect URI.
+func oauthError(op string, err error) error {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("%s error: %v", op, err)
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	code := codes.InvalidArgument
+	switch oauthErr.Code {
+	case service.OAuthInvalidClient:
+		code = codes.Unauthenticated
+	case service.OAuthAccessDenied, service.OAuthUnauthorizedClient:
+		code = codes.PermissionDenied
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason: oauthErr.Code,
+		Domain: oauthErrorDomain,
+	}
+	if oauthErr.Redirect {
+		info.Metadata = map[string]string{"redirect": "true"}
+	}
+
+	st, detailErr := status.New(code, oauthErr.Description).WithDetails(info)
+	if detailErr != nil {
+		return status.Error(code, oauthErr.Description)
+	}
+	return st.Err()
+}
+
+// weakPassword returns an InvalidArgument error with a field violation for
+// every rule of the password policy that was broken.
+func weakPassword(err error) error {
+	var policyErr *service.PolicyError
+	if !errors.As(err, &policyErr) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, v := range policyErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	st, detailErr := status.New(codes.InvalidArgument, "password does not meet the policy").WithDetails(badRequest)
+	if detailErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/outbox"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// DeleteAccount checks the password of a user, then deletes its credential,
+// revokes its tokens and schedules the deletion of its profile in the user
+// service. The profile deletion is retried until the user service accepts it.
+func (s *service) DeleteAccount(ctx context.Context, userID, password string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrRecordNotFound
+	}
+
+	credential, err := s.repository.FindByID(ctx, uid.String())
+	if err != nil {
+		return fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if credential == nil {
+		return ErrRecordNotFound
+	}
+
+	if err := s.checkPassword(credential, password); err != nil {
+		return err
+	}
+
+	event, err := outbox.NewEvent(outbox.EventDeleteUserProfile, outbox.DeleteUserProfilePayload{
+		UserID: uid.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.Transaction(ctx, func(tx repository.Repository) error {
+		if err := tx.DeleteByID(ctx, uid.String()); err != nil {
+			return err
+		}
+		if err := revokeAllSessions(ctx, tx, uid, time.Now()); err != nil {
+			return err
+		}
+		return tx.CreateOutboxEvent(ctx, event)
+	}); err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	s.outbox.Wake()
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	u := newMFAUser(t)
+	s := u.s
+	store := s.repository.(*repositorytest.Repository)
+	credential, err := s.repository.FindByEmail(ctx, testEmail)
+	if err != nil || credential == nil {
+		t.Fatalf("FindByEmail = %v, %v", credential, err)
+	}
+	userID := credential.ID.String()
+
+	// Leave a record in every table that grants access to the account.
+	if _, err := s.VerifyMFA(ctx, u.login(t), u.code(t, 1), ClientInfo{}); err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	u.login(t)
+	if err := s.RequestPasswordReset(ctx, testEmail, ""); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	authorize(t, s, userID, oauthClient(t, s, false).ClientID)
+	if _, err := s.CreateAPIKey(ctx, userID, APIKeySpec{Name: "ci"}); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	other, err := s.Register(ctx, "bob@example.com", testPassword, "Bob", "")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := s.CreateAPIKey(ctx, other, APIKeySpec{Name: "ci"}); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	records := store.UserRecords(credential.ID)
+	for _, table := range []string{
+		"credentials", "api_keys", "sessions", "refresh_tokens",
+		"mfa_factors", "mfa_challenges", "mfa_recovery_codes",
+		"password_reset_tokens", "oauth_consents", "oauth_authorization_codes",
+	} {
+		if !slices.Contains(records, table) {
+			t.Fatalf("user has no %s before the deletion, got %v", table, records)
+		}
+	}
+
+	if err := s.DeleteAccount(ctx, userID, "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("DeleteAccount with a wrong password error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if err := s.DeleteAccount(ctx, userID, testPassword); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+
+	if records := store.UserRecords(credential.ID); len(records) != 0 {
+		t.Errorf("records left after the deletion: %v", records)
+	}
+	if otherCredential, _ := s.repository.FindByEmail(ctx, "bob@example.com"); otherCredential == nil ||
+		!slices.Contains(store.UserRecords(otherCredential.ID), "api_keys") {
+		t.Errorf("records of another user were deleted")
+	}
+	if err := s.DeleteAccount(ctx, userID, testPassword); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("second DeleteAccount error = %v, want %v", err, ErrRecordNotFound)
+	}
+}
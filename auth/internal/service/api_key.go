@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyMarker starts every API key, so that leaked keys are easy to spot.
+	apiKeyMarker          = "gak"
+	apiKeyPrefixByteCount = 6
+	// apiKeyTouchResolution is how stale the last used time of a key may get
+	// before a request writes it again.
+	apiKeyTouchResolution = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey     = errors.New("invalid API key")
+	ErrAPIKeyExpired     = errors.New("API key expired")
+	ErrInvalidAPIKeySpec = errors.New("invalid API key spec")
+)
+
+// APIKeySpec describes an API key to create. Scope is an optional space
+// delimited list, and a nil ExpiresAt creates a key that does not expire.
+type APIKeySpec struct {
+	Name      string
+	Scope     string
+	ExpiresAt *time.Time
+}
+
+// CreatedAPIKey holds a new API key. The key itself is only returned here.
+type CreatedAPIKey struct {
+	Key    string
+	APIKey *model.APIKey
+}
+
+// APIKeyIdentity is the user an API key authenticates, with the scope of the key.
+type APIKeyIdentity struct {
+	KeyID  string
+	UserID string
+	Email  string
+	Scope  string
+}
+
+// CreateAPIKey creates an API key for a user. The key has the form
+// gak_<prefix>_<secret>; the prefix identifies the key and only the hash of
+// the whole key is stored.
+func (s *service) CreateAPIKey(ctx context.Context, userID string, spec APIKeySpec) (*CreatedAPIKey, error) {
+	credential, err := s.findCredential(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	spec.Name = strings.TrimSpace(spec.Name)
+	if spec.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidAPIKeySpec)
+	}
+	scopes := strings.Fields(spec.Scope)
+	for _, scope := range scopes {
+		if !validScopeToken(scope) {
+			return nil, fmt.Errorf("%w: invalid scope %q", ErrInvalidAPIKeySpec, scope)
+		}
+	}
+	if spec.ExpiresAt != nil && !spec.ExpiresAt.After(s.now()) {
+		return nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidAPIKeySpec)
+	}
+
+	b := make([]byte, apiKeyPrefixByteCount)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate API key prefix: %w", err)
+	}
+	prefix := hex.EncodeToString(b)
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := apiKeyMarker + "_" + prefix + "_" + secret
+
+	apiKey := &model.APIKey{
+		UserID:    credential.ID,
+		Name:      spec.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(key),
+		Scope:     strings.Join(scopes, " "),
+		ExpiresAt: spec.ExpiresAt,
+	}
+	if err := s.repository.CreateAPIKey(ctx, apiKey); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return &CreatedAPIKey{Key: key, APIKey: apiKey}, nil
+}
+
+// ListAPIKeys returns the API keys of a user that were not revoked.
+func (s *service) ListAPIKeys(ctx context.Context, userID string) ([]model.APIKey, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	keys, err := s.repository.ListAPIKeys(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key of a user. The key stops working right away.
+func (s *service) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrRecordNotFound
+	}
+	kid, err := uuid.Parse(keyID)
+	if err != nil {
+		return ErrRecordNotFound
+	}
+
+	if err := s.repository.RevokeAPIKey(ctx, uid, kid, s.now()); err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return nil
+}
+
+// VerifyAPIKey checks an API key and returns the user it authenticates. Keys
+// of users who could not log in, such as pending accounts, are rejected.
+func (s *service) VerifyAPIKey(ctx context.Context, key string) (*APIKeyIdentity, error) {
+	marker, rest, _ := strings.Cut(key, "_")
+	prefix, secret, _ := strings.Cut(rest, "_")
+	if marker != apiKeyMarker || prefix == "" || secret == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.repository.FindAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API key: %w", err)
+	}
+	if apiKey == nil || subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashToken(key))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if apiKey.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	now := s.now()
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	user, err := s.repository.FindByID(ctx, apiKey.UserID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if user == nil || user.Status == model.CredentialStatusPending {
+		return nil, ErrInvalidAPIKey
+	}
+	if s.requireEmailVerification && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	if err := s.repository.TouchAPIKey(ctx, apiKey.ID, now, apiKeyTouchResolution); err != nil {
+		log.Printf("failed to record use of API key %s: %v", apiKey.ID, err)
+	}
+
+	return &APIKeyIdentity{
+		KeyID:  apiKey.ID.String(),
+		UserID: user.ID.String(),
+		Email:  user.Email,
+		Scope:  apiKey.Scope,
+	}, nil
+}
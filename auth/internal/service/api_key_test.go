@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+// createAPIKey creates an API key for a user and returns the secret key.
+func createAPIKey(t *testing.T, s *service, userID string) string {
+	t.Helper()
+
+	created, err := s.CreateAPIKey(context.Background(), userID, APIKeySpec{Name: "ci"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	return created.Key
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		unknown   bool
+		spec      APIKeySpec
+		wantScope string
+		wantErr   error
+	}{
+		{name: "without scope or expiry", spec: APIKeySpec{Name: "ci"}},
+		{name: "scope is normalized", spec: APIKeySpec{Name: "ci", Scope: " users:list  roles:manage "}, wantScope: "users:list roles:manage"},
+		{name: "future expiry", spec: APIKeySpec{Name: "ci", ExpiresAt: &future}},
+		{name: "past expiry", spec: APIKeySpec{Name: "ci", ExpiresAt: &past}, wantErr: ErrInvalidAPIKeySpec},
+		{name: "missing name", spec: APIKeySpec{Name: "  "}, wantErr: ErrInvalidAPIKeySpec},
+		{name: "invalid scope", spec: APIKeySpec{Name: "ci", Scope: `users:"list"`}, wantErr: ErrInvalidAPIKeySpec},
+		{name: "unknown user", unknown: true, spec: APIKeySpec{Name: "ci"}, wantErr: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			if tt.unknown {
+				userID = uuid.NewString()
+			}
+
+			created, err := s.CreateAPIKey(context.Background(), userID, tt.spec)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateAPIKey error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !strings.HasPrefix(created.Key, apiKeyMarker+"_"+created.APIKey.Prefix+"_") {
+				t.Errorf("key %q does not start with %s_%s_", created.Key, apiKeyMarker, created.APIKey.Prefix)
+			}
+			if created.APIKey.KeyHash == "" || strings.Contains(created.Key, created.APIKey.KeyHash) {
+				t.Error("key is not stored hashed")
+			}
+			if created.APIKey.Scope != tt.wantScope {
+				t.Errorf("scope = %q, want %q", created.APIKey.Scope, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		// key returns the key to verify, given the created one.
+		key func(key string) string
+		// before runs after the key was created.
+		before  func(t *testing.T, s *service, clock *testClock, userID, keyID string)
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "valid until expiry", expiresIn: time.Hour, before: func(_ *testing.T, _ *service, clock *testClock, _, _ string) { clock.advance(59 * time.Minute) }},
+		{name: "expired", expiresIn: time.Hour, before: func(_ *testing.T, _ *service, clock *testClock, _, _ string) { clock.advance(time.Hour) }, wantErr: ErrAPIKeyExpired},
+		{
+			name: "revoked",
+			before: func(t *testing.T, s *service, _ *testClock, userID, keyID string) {
+				if err := s.RevokeAPIKey(context.Background(), userID, keyID); err != nil {
+					t.Fatalf("RevokeAPIKey: %v", err)
+				}
+			},
+			wantErr: ErrInvalidAPIKey,
+		},
+		{name: "wrong secret with a known prefix", key: func(key string) string { return key[:len(key)-4] + "AAAA" }, wantErr: ErrInvalidAPIKey},
+		{name: "unknown prefix", key: func(string) string { return apiKeyMarker + "_000000000000_secret" }, wantErr: ErrInvalidAPIKey},
+		{name: "other marker", key: func(key string) string { return "xyz" + strings.TrimPrefix(key, apiKeyMarker) }, wantErr: ErrInvalidAPIKey},
+		{name: "missing secret", key: func(key string) string { return key[:strings.LastIndex(key, "_")] }, wantErr: ErrInvalidAPIKey},
+		{name: "empty", key: func(string) string { return "" }, wantErr: ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clock := newTestClock()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			s.now = clock.now
+			userID := registerUser(t, s).ID.String()
+
+			spec := APIKeySpec{Name: "ci", Scope: "users:list"}
+			if tt.expiresIn != 0 {
+				expiresAt := clock.now().Add(tt.expiresIn)
+				spec.ExpiresAt = &expiresAt
+			}
+			created, err := s.CreateAPIKey(ctx, userID, spec)
+			if err != nil {
+				t.Fatalf("CreateAPIKey: %v", err)
+			}
+			keyID := created.APIKey.ID.String()
+			if tt.before != nil {
+				tt.before(t, s, clock, userID, keyID)
+			}
+			key := created.Key
+			if tt.key != nil {
+				key = tt.key(key)
+			}
+
+			identity, err := s.VerifyAPIKey(ctx, key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAPIKey error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if identity.KeyID != keyID || identity.UserID != userID || identity.Email != testEmail || identity.Scope != "users:list" {
+				t.Errorf("VerifyAPIKey = %+v, want key %s of %s with scope users:list", identity, keyID, userID)
+			}
+
+			keys, err := s.ListAPIKeys(ctx, userID)
+			if err != nil || len(keys) != 1 {
+				t.Fatalf("ListAPIKeys = %v, %v, want the key", keys, err)
+			}
+			if used := keys[0].LastUsedAt; used == nil || !used.Equal(clock.now()) {
+				t.Errorf("LastUsedAt = %v, want %v", used, clock.now())
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		// userID and keyID return the IDs to revoke, given those of the created key.
+		userID  func(userID string) string
+		keyID   func(keyID string) string
+		wantErr error
+	}{
+		{name: "own key"},
+		{name: "key of another user", userID: func(string) string { return uuid.NewString() }, wantErr: ErrRecordNotFound},
+		{name: "unknown key", keyID: func(string) string { return uuid.NewString() }, wantErr: ErrRecordNotFound},
+		{name: "malformed key ID", keyID: func(string) string { return "not-a-uuid" }, wantErr: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			created, err := s.CreateAPIKey(ctx, userID, APIKeySpec{Name: "ci"})
+			if err != nil {
+				t.Fatalf("CreateAPIKey: %v", err)
+			}
+
+			revokeUserID, revokeKeyID := userID, created.APIKey.ID.String()
+			if tt.userID != nil {
+				revokeUserID = tt.userID(revokeUserID)
+			}
+			if tt.keyID != nil {
+				revokeKeyID = tt.keyID(revokeKeyID)
+			}
+			err = s.RevokeAPIKey(ctx, revokeUserID, revokeKeyID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevokeAPIKey error = %v, want %v", err, tt.wantErr)
+			}
+
+			revoked := err == nil
+			keys, err := s.ListAPIKeys(ctx, userID)
+			if err != nil {
+				t.Fatalf("ListAPIKeys: %v", err)
+			}
+			if listed := len(keys) == 1; listed == revoked {
+				t.Errorf("key listed = %v after revoked = %v", listed, revoked)
+			}
+			if _, err := s.VerifyAPIKey(ctx, created.Key); (err != nil) != revoked {
+				t.Errorf("VerifyAPIKey error = %v after revoked = %v", err, revoked)
+			}
+			if revoked {
+				if err := s.RevokeAPIKey(ctx, userID, created.APIKey.ID.String()); !errors.Is(err, ErrRecordNotFound) {
+					t.Errorf("second RevokeAPIKey error = %v, want %v", err, ErrRecordNotFound)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultEmailVerificationExpiry is used when EMAIL_VERIFICATION_EXPIRY is not configured.
+	defaultEmailVerificationExpiry = 24 * time.Hour
+	// defaultVerificationResendCooldown is used when VERIFICATION_RESEND_COOLDOWN is not configured.
+	defaultVerificationResendCooldown = time.Minute
+)
+
+// emailVerificationClaims is the signed payload of an email verification token.
+type emailVerificationClaims struct {
+	UserID    string `json:"uid"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// ResendVerificationEmail sends a new verification email to an unverified
+// account. Unknown and already verified emails, and resends within the
+// cooldown, are ignored alike, so the result does not reveal whether an email
+// is registered.
+func (s *service) ResendVerificationEmail(ctx context.Context, email, locale string) error {
+	credential, err := s.repository.FindByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to find user by email: %w", err)
+	}
+	if credential == nil || credential.EmailVerified {
+		return nil
+	}
+
+	if err := s.sendVerificationEmail(ctx, credential, locale); err != nil && !errors.Is(err, ErrVerificationRateLimited) {
+		return err
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of a user as verified using a token sent by
+// sendVerificationEmail. The token is only valid for the address it was sent to.
+func (s *service) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.parseVerificationToken(token)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	if err := s.repository.MarkEmailVerified(ctx, userID, claims.Email); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	return nil
+}
+
+// sendVerificationEmail queues a verification email, unless one was sent to
+// the user within the resend cooldown.
+func (s *service) sendVerificationEmail(ctx context.Context, credential *model.Credential, locale string) error {
+	allowed, err := s.repository.MarkVerificationSent(ctx, credential.ID, time.Now(), s.resendCooldown)
+	if err != nil {
+		return fmt.Errorf("failed to record verification email: %w", err)
+	}
+	if !allowed {
+		return ErrVerificationRateLimited
+	}
+
+	expiresAt := time.Now().Add(s.verificationExpiry)
+	token, err := s.signVerificationToken(&emailVerificationClaims{
+		UserID:    credential.ID.String(),
+		Email:     credential.Email,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sign verification token: %w", err)
+	}
+
+	return s.notifier.Notify(ctx, notifier.Message{
+		To:       credential.Email,
+		Locale:   locale,
+		Template: notifier.TemplateVerifyEmail,
+		Data: notifier.VerifyEmailData{
+			URL:       s.appURL("/verify-email", token),
+			ExpiresAt: expiresAt,
+		},
+	})
+}
+
+// signVerificationToken encodes the claims and appends their HMAC-SHA256 signature.
+func (s *service) signVerificationToken(claims *emailVerificationClaims) (string, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.verificationMAC(payload)), nil
+}
+
+// parseVerificationToken checks the signature and expiry of a verification token.
+func (s *service) parseVerificationToken(token string) (*emailVerificationClaims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.verificationMAC(payload)) {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	var claims emailVerificationClaims
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+func (s *service) verificationMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, s.verificationSecret)
+	mac.Write([]byte("email-verification:" + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+// verificationTokens returns the tokens of the verification emails sent by s, oldest first.
+func verificationTokens(t *testing.T, s *service) []string {
+	t.Helper()
+
+	n := s.notifier.(*testNotifier)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var tokens []string
+	for _, msg := range n.messages {
+		data, ok := msg.Data.(notifier.VerifyEmailData)
+		if !ok {
+			continue
+		}
+		link, err := url.Parse(data.URL)
+		if err != nil {
+			t.Fatalf("invalid verification URL %q: %v", data.URL, err)
+		}
+		tokens = append(tokens, link.Query().Get("token"))
+	}
+	return tokens
+}
+
+func signVerificationToken(t *testing.T, s *service, claims *emailVerificationClaims) string {
+	t.Helper()
+
+	token, err := s.signVerificationToken(claims)
+	if err != nil {
+		t.Fatalf("signVerificationToken: %v", err)
+	}
+	return token
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token to verify, given the one that was emailed.
+		token   func(t *testing.T, s *service, credential *model.Credential, sent string) string
+		wantErr error
+	}{
+		{name: "emailed token", token: func(_ *testing.T, _ *service, _ *model.Credential, sent string) string { return sent }},
+		{
+			name: "expired token",
+			token: func(t *testing.T, s *service, c *model.Credential, _ string) string {
+				return signVerificationToken(t, s, &emailVerificationClaims{UserID: c.ID.String(), Email: c.Email, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "token for another email",
+			token: func(t *testing.T, s *service, c *model.Credential, _ string) string {
+				return signVerificationToken(t, s, &emailVerificationClaims{UserID: c.ID.String(), Email: "old@example.com", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{
+			name: "token for an unknown user",
+			token: func(t *testing.T, s *service, c *model.Credential, _ string) string {
+				return signVerificationToken(t, s, &emailVerificationClaims{UserID: uuid.NewString(), Email: c.Email, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{
+			name: "payload of another token",
+			token: func(t *testing.T, s *service, c *model.Credential, sent string) string {
+				other := signVerificationToken(t, s, &emailVerificationClaims{UserID: c.ID.String(), Email: c.Email, ExpiresAt: time.Now().Add(48 * time.Hour).Unix()})
+				payload, _, _ := strings.Cut(other, ".")
+				_, signature, _ := strings.Cut(sent, ".")
+				return payload + "." + signature
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{
+			name: "signed with another secret",
+			token: func(t *testing.T, _ *service, c *model.Credential, _ string) string {
+				other := &service{verificationSecret: []byte("other-secret")}
+				return signVerificationToken(t, other, &emailVerificationClaims{UserID: c.ID.String(), Email: c.Email, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{name: "malformed token", token: func(*testing.T, *service, *model.Credential, string) string { return "not-a-token" }, wantErr: ErrInvalidVerificationToken},
+		{name: "empty token", token: func(*testing.T, *service, *model.Credential, string) string { return "" }, wantErr: ErrInvalidVerificationToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			s.requireEmailVerification = true
+			credential := registerUser(t, s)
+
+			sent := verificationTokens(t, s)
+			if len(sent) != 1 {
+				t.Fatalf("sent %d verification emails on registration, want 1", len(sent))
+			}
+			if _, err := s.Login(ctx, testEmail, testPassword, ClientInfo{}); !errors.Is(err, ErrEmailNotVerified) {
+				t.Fatalf("Login before verification error = %v, want %v", err, ErrEmailNotVerified)
+			}
+
+			err := s.VerifyEmail(ctx, tt.token(t, s, credential, sent[0]))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyEmail error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, err = s.Login(ctx, testEmail, testPassword, ClientInfo{})
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Login after verification: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, ErrEmailNotVerified) {
+				t.Errorf("Login after a failed verification error = %v, want %v", err, ErrEmailNotVerified)
+			}
+		})
+	}
+}
+
+func TestUnverifiedAccountPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		require bool
+		wantErr error
+	}{
+		{name: "verification required", require: true, wantErr: ErrEmailNotVerified},
+		{name: "verification optional"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			created, err := s.CreateAPIKey(ctx, userID, APIKeySpec{Name: "ci"})
+			if err != nil {
+				t.Fatalf("CreateAPIKey: %v", err)
+			}
+			s.requireEmailVerification = tt.require
+
+			if _, err := s.Login(ctx, testEmail, testPassword, ClientInfo{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := s.VerifyAPIKey(ctx, created.Key); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyAPIKey error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		cooldown time.Duration
+		verified bool
+		wantSent bool
+	}{
+		{name: "after the cooldown", email: testEmail, cooldown: time.Nanosecond, wantSent: true},
+		{name: "within the cooldown", email: testEmail, cooldown: time.Hour},
+		{name: "already verified", email: testEmail, cooldown: time.Nanosecond, verified: true},
+		{name: "unknown email", email: "nobody@example.com", cooldown: time.Nanosecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			s.resendCooldown = tt.cooldown
+			registerUser(t, s)
+			if tt.verified {
+				if err := s.VerifyEmail(ctx, verificationTokens(t, s)[0]); err != nil {
+					t.Fatalf("VerifyEmail: %v", err)
+				}
+			}
+			time.Sleep(time.Millisecond)
+
+			// Every case succeeds alike, so the result does not reveal whether the email is registered.
+			if err := s.ResendVerificationEmail(ctx, tt.email, ""); err != nil {
+				t.Fatalf("ResendVerificationEmail: %v", err)
+			}
+			if sent := len(verificationTokens(t, s)) == 2; sent != tt.wantSent {
+				t.Errorf("verification email resent = %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+}
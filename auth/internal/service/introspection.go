@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// IntrospectionRequest is a request of a resource server to the introspection endpoint.
+type IntrospectionRequest struct {
+	ClientID     string
+	ClientSecret string
+	Token        string
+}
+
+// Introspection describes a token as in RFC 7662. Only Active is set for
+// tokens that are not active.
+type Introspection struct {
+	Active    bool
+	Subject   string
+	ClientID  string
+	Scope     string
+	TokenType string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+}
+
+// IntrospectToken tells a confidential client whether an access token is
+// active and what it was issued for. Refresh tokens are only ever presented
+// to the token endpoint, so they are reported as inactive like any other
+// token that is unknown, expired or revoked.
+func (s *service) IntrospectToken(ctx context.Context, req IntrospectionRequest) (*Introspection, error) {
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential() {
+		return nil, &OAuthError{Code: OAuthInvalidClient, Description: "public clients may not introspect tokens"}
+	}
+
+	inactive := &Introspection{}
+	claims, err := s.parseToken(req.Token)
+	if err != nil {
+		return inactive, nil
+	}
+	// ID tokens are signed with the same keys but are not access tokens.
+	if stringClaim(claims, "jti") == "" {
+		return inactive, nil
+	}
+	if err := s.checkRevocation(ctx, claims); err != nil {
+		if errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrInvalidToken) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+
+	// Tokens of users name them in user_id; client credentials tokens name the client in sub.
+	subject := stringClaim(claims, "user_id")
+	if isClientToken(claims) {
+		subject = stringClaim(claims, "sub")
+	}
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+
+	return &Introspection{
+		Active:    true,
+		Subject:   subject,
+		ClientID:  stringClaim(claims, "client_id"),
+		Scope:     stringClaim(claims, "scope"),
+		TokenType: "Bearer",
+		ExpiresAt: time.Unix(int64(exp), 0),
+		IssuedAt:  time.UnixMilli(int64(math.Round(iat * 1000))),
+	}, nil
+}
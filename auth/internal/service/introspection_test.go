@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+)
+
+// resourceServer registers a confidential client that uses the client
+// credentials grant, as a resource server that introspects tokens would.
+func resourceServer(t *testing.T, s *service) *OAuthClientRegistration {
+	t.Helper()
+
+	client, err := s.CreateOAuthClient(context.Background(), OAuthClientSpec{
+		Name:         "Resource Server",
+		GrantTypes:   []string{model.GrantTypeClientCredentials},
+		Scope:        "reports:read",
+		Confidential: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateOAuthClient: %v", err)
+	}
+	return client
+}
+
+func clientCredentialsToken(t *testing.T, s *service, client *OAuthClientRegistration) string {
+	t.Helper()
+
+	token, err := s.Token(context.Background(), TokenRequest{
+		GrantType:    model.GrantTypeClientCredentials,
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	return token.AccessToken
+}
+
+func TestIntrospectToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token to introspect.
+		token      func(t *testing.T, s *service, userID string, rs *OAuthClientRegistration) string
+		wantActive bool
+		// wantClientSubject expects the resource server as the subject rather than the user.
+		wantClientSubject bool
+		wantScope         string
+		wantClient        bool
+	}{
+		{
+			name: "first-party access token",
+			token: func(t *testing.T, s *service, _ string, _ *OAuthClientRegistration) string {
+				return login(t, s).AccessToken
+			},
+			wantActive: true,
+		},
+		{
+			name: "OAuth access token",
+			token: func(t *testing.T, s *service, userID string, _ *OAuthClientRegistration) string {
+				client := oauthClient(t, s, false)
+				token, err := s.Token(context.Background(), codeRequest(client, authorize(t, s, userID, client.ClientID)))
+				if err != nil {
+					t.Fatalf("Token: %v", err)
+				}
+				return token.AccessToken
+			},
+			wantActive: true, wantScope: "profile", wantClient: true,
+		},
+		{
+			name: "client credentials token",
+			token: func(t *testing.T, s *service, _ string, rs *OAuthClientRegistration) string {
+				return clientCredentialsToken(t, s, rs)
+			},
+			wantActive: true, wantClientSubject: true, wantScope: "reports:read", wantClient: true,
+		},
+		{
+			name: "logged out access token",
+			token: func(t *testing.T, s *service, _ string, _ *OAuthClientRegistration) string {
+				token := login(t, s).AccessToken
+				if err := s.Logout(context.Background(), token, ""); err != nil {
+					t.Fatalf("Logout: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "revoked client credentials token",
+			token: func(t *testing.T, s *service, _ string, rs *OAuthClientRegistration) string {
+				token := clientCredentialsToken(t, s, rs)
+				if err := s.Logout(context.Background(), token, ""); err != nil {
+					t.Fatalf("Logout: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "token issued before every session was revoked",
+			token: func(t *testing.T, s *service, userID string, _ *OAuthClientRegistration) string {
+				token := login(t, s).AccessToken
+				if err := s.RevokeAllSessions(context.Background(), userID); err != nil {
+					t.Fatalf("RevokeAllSessions: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "expired access token",
+			token: func(t *testing.T, s *service, _ string, _ *OAuthClientRegistration) string {
+				expiry := s.tokenExpiry
+				s.tokenExpiry = -time.Minute
+				defer func() { s.tokenExpiry = expiry }()
+				return login(t, s).AccessToken
+			},
+		},
+		{
+			name: "refresh token",
+			token: func(t *testing.T, s *service, _ string, _ *OAuthClientRegistration) string {
+				return login(t, s).RefreshToken
+			},
+		},
+		{
+			name: "ID token",
+			token: func(t *testing.T, s *service, userID string, _ *OAuthClientRegistration) string {
+				return oidcToken(t, s, userID, oidcClient(t, s).ClientID, "openid", "").IDToken
+			},
+		},
+		{name: "malformed token", token: func(*testing.T, *service, string, *OAuthClientRegistration) string { return "not-a-token" }},
+		{name: "empty token", token: func(*testing.T, *service, string, *OAuthClientRegistration) string { return "" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			rs := resourceServer(t, s)
+			token := tt.token(t, s, userID, rs)
+
+			got, err := s.IntrospectToken(ctx, IntrospectionRequest{ClientID: rs.ClientID, ClientSecret: rs.ClientSecret, Token: token})
+			if err != nil {
+				t.Fatalf("IntrospectToken: %v", err)
+			}
+			if !tt.wantActive {
+				if *got != (Introspection{}) {
+					t.Errorf("IntrospectToken = %+v, want only inactive", got)
+				}
+				return
+			}
+
+			wantSubject := userID
+			if tt.wantClientSubject {
+				wantSubject = rs.ClientID
+			}
+			if !got.Active || got.Subject != wantSubject || got.Scope != tt.wantScope || got.TokenType != "Bearer" {
+				t.Errorf("IntrospectToken = %+v, want active for %s with scope %q", got, wantSubject, tt.wantScope)
+			}
+			if (got.ClientID != "") != tt.wantClient {
+				t.Errorf("client_id = %q, want one %v", got.ClientID, tt.wantClient)
+			}
+			if !got.ExpiresAt.After(time.Now()) || got.IssuedAt.After(time.Now()) || got.IssuedAt.IsZero() {
+				t.Errorf("IntrospectToken exp, iat = %v, %v, want a live token", got.ExpiresAt, got.IssuedAt)
+			}
+		})
+	}
+}
+
+func TestIntrospectTokenCaller(t *testing.T) {
+	tests := []struct {
+		name string
+		// caller returns the credentials of the introspecting client.
+		caller   func(t *testing.T, s *service) IntrospectionRequest
+		wantCode string
+	}{
+		{
+			name: "confidential client",
+			caller: func(t *testing.T, s *service) IntrospectionRequest {
+				rs := resourceServer(t, s)
+				return IntrospectionRequest{ClientID: rs.ClientID, ClientSecret: rs.ClientSecret}
+			},
+		},
+		{
+			name: "public client",
+			caller: func(t *testing.T, s *service) IntrospectionRequest {
+				return IntrospectionRequest{ClientID: oauthClient(t, s, false).ClientID}
+			},
+			wantCode: OAuthInvalidClient,
+		},
+		{
+			name: "wrong client secret",
+			caller: func(t *testing.T, s *service) IntrospectionRequest {
+				return IntrospectionRequest{ClientID: resourceServer(t, s).ClientID, ClientSecret: "wrong"}
+			},
+			wantCode: OAuthInvalidClient,
+		},
+		{name: "anonymous", caller: func(*testing.T, *service) IntrospectionRequest { return IntrospectionRequest{} }, wantCode: OAuthInvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			registerUser(t, s)
+
+			req := tt.caller(t, s)
+			req.Token = login(t, s).AccessToken
+			got, err := s.IntrospectToken(context.Background(), req)
+			wantOAuthError(t, err, tt.wantCode)
+			if err == nil && !got.Active {
+				t.Error("IntrospectToken reported an active token as inactive")
+			}
+		})
+	}
+}
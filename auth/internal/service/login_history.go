@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLoginHistoryPageSize = 20
+	maxLoginHistoryPageSize     = 100
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// loginHistoryPageToken is the keyset position encoded in a login history page token.
+type loginHistoryPageToken struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// GetLoginHistory returns a page of the login history of a user, newest
+// first, together with the token of the next page, which is empty on the last page.
+func (s *service) GetLoginHistory(ctx context.Context, userID string, pageSize int, pageToken string) ([]model.LoginEvent, string, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, "", ErrRecordNotFound
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultLoginHistoryPageSize
+	}
+	if pageSize > maxLoginHistoryPageSize {
+		pageSize = maxLoginHistoryPageSize
+	}
+
+	query := repository.LoginHistoryQuery{
+		UserID: uid,
+		Limit:  pageSize + 1,
+	}
+	if pageToken != "" {
+		b, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+		var token loginHistoryPageToken
+		if err := json.Unmarshal(b, &token); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+		query.AfterCreatedAt = &token.CreatedAt
+		query.AfterID = token.ID
+	}
+
+	events, err := s.repository.ListLoginEvents(ctx, query)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list login events: %w", err)
+	}
+	if len(events) <= pageSize {
+		return events, "", nil
+	}
+
+	events = events[:pageSize]
+	last := events[len(events)-1]
+	b, err := json.Marshal(loginHistoryPageToken{CreatedAt: last.CreatedAt, ID: last.ID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode page token: %w", err)
+	}
+
+	return events, base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// auditLoginFailure appends a failed login to the history. user is nil when
+// the email matches no account. Errors are only logged, so that the caller
+// still sees the failed login.
+func (s *service) auditLoginFailure(ctx context.Context, email string, user *model.Credential, client ClientInfo, reason string) {
+	event := s.loginEvent(email, client)
+	event.Reason = reason
+	if user != nil {
+		event.UserID = &user.ID
+		event.Email = user.Email
+	}
+
+	if err := s.repository.CreateLoginEvent(ctx, event); err != nil {
+		log.Printf("failed to record failed login of %s: %v", email, err)
+	}
+}
+
+// auditLoginSuccess appends a successful login to the history and notifies
+// the user when it comes from a device or network that none of its earlier
+// logins came from. The first login of a user is not reported. Errors are
+// only logged, since the login already succeeded.
+func (s *service) auditLoginSuccess(ctx context.Context, user *model.Credential, client ClientInfo) {
+	event := s.loginEvent(user.Email, client)
+	event.UserID = &user.ID
+	event.Success = true
+
+	sightings, err := s.repository.FindLoginSightings(ctx, user.ID, event.DeviceFingerprint, event.Network)
+	if err != nil {
+		log.Printf("failed to check login history of %s: %v", user.ID, err)
+	} else {
+		event.NewDevice = sightings.Logins > 0 &&
+			(!sightings.DeviceSeen || (event.Network != "" && !sightings.NetworkSeen))
+	}
+
+	if err := s.repository.CreateLoginEvent(ctx, event); err != nil {
+		log.Printf("failed to record login of %s: %v", user.ID, err)
+	}
+
+	if !event.NewDevice {
+		return
+	}
+	if err := s.notifier.Notify(ctx, notifier.Message{
+		To:       user.Email,
+		Template: notifier.TemplateNewLogin,
+		Data: notifier.NewLoginData{
+			Time:      event.CreatedAt,
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+		},
+	}); err != nil {
+		log.Printf("failed to send new login alert to %s: %v", user.ID, err)
+	}
+}
+
+// loginEvent returns a login event for an email from the device of the client.
+func (s *service) loginEvent(email string, client ClientInfo) *model.LoginEvent {
+	return &model.LoginEvent{
+		Email:             truncate(email, 320),
+		IPAddress:         truncate(client.IP, 64),
+		UserAgent:         truncate(client.UserAgent, 512),
+		DeviceFingerprint: deviceFingerprint(client.UserAgent),
+		Network:           network(client.IP),
+		CreatedAt:         s.now(),
+	}
+}
+
+// deviceFingerprint returns the hash of a normalised user agent, which
+// identifies the browser or app a login came from.
+func deviceFingerprint(userAgent string) string {
+	return hashToken(strings.ToLower(strings.Join(strings.Fields(userAgent), " ")))
+}
+
+// network returns the network an IP belongs to: its /24 for IPv4 and its /48
+// for IPv6, so that addresses handed out by the same provider count as one
+// network. It returns an empty string if ip is not an IP address.
+func network(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+// newLoginAlerts returns the new login alerts sent by s.
+func newLoginAlerts(s *service) []notifier.NewLoginData {
+	n := s.notifier.(*testNotifier)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var alerts []notifier.NewLoginData
+	for _, msg := range n.messages {
+		if data, ok := msg.Data.(notifier.NewLoginData); ok {
+			alerts = append(alerts, data)
+		}
+	}
+	return alerts
+}
+
+func TestNewLoginAlert(t *testing.T) {
+	const (
+		knownIP = "203.0.113.7"
+		knownUA = "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0"
+	)
+
+	tests := []struct {
+		name string
+		// history are the logins before the one checked; none makes it the first login.
+		history   []ClientInfo
+		client    ClientInfo
+		password  string
+		wantAlert bool
+	}{
+		{name: "first login", client: ClientInfo{IP: "198.51.100.9", UserAgent: "curl/8.0"}, password: testPassword},
+		{name: "known device and network", history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}}, client: ClientInfo{IP: knownIP, UserAgent: knownUA}, password: testPassword},
+		{
+			name:    "known device with extra whitespace and case",
+			history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}},
+			client:  ClientInfo{IP: knownIP, UserAgent: "  mozilla/5.0 (x11;  linux x86_64) firefox/128.0 "}, password: testPassword,
+		},
+		{name: "same network", history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}}, client: ClientInfo{IP: "203.0.113.200", UserAgent: knownUA}, password: testPassword},
+		{
+			name:    "new network",
+			history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}},
+			client:  ClientInfo{IP: "198.51.100.9", UserAgent: knownUA}, password: testPassword, wantAlert: true,
+		},
+		{
+			name:    "new device",
+			history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}},
+			client:  ClientInfo{IP: knownIP, UserAgent: "curl/8.0"}, password: testPassword, wantAlert: true,
+		},
+		{
+			name:    "device seen on another network",
+			history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}, {IP: "198.51.100.9", UserAgent: "curl/8.0"}},
+			client:  ClientInfo{IP: knownIP, UserAgent: "curl/8.0"}, password: testPassword,
+		},
+		{name: "unknown IP", history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}}, client: ClientInfo{UserAgent: knownUA}, password: testPassword},
+		{name: "failed login from a new device", history: []ClientInfo{{IP: knownIP, UserAgent: knownUA}}, client: ClientInfo{IP: "198.51.100.9", UserAgent: "curl/8.0"}, password: "wrong-password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			registerUser(t, s)
+			for _, client := range tt.history {
+				if _, err := s.Login(ctx, testEmail, testPassword, client); err != nil {
+					t.Fatalf("Login: %v", err)
+				}
+			}
+			before := len(newLoginAlerts(s))
+
+			if _, err := s.Login(ctx, testEmail, tt.password, tt.client); (err == nil) != (tt.password == testPassword) {
+				t.Fatalf("Login error = %v", err)
+			}
+
+			alerts := newLoginAlerts(s)[before:]
+			if (len(alerts) == 1) != tt.wantAlert || len(alerts) > 1 {
+				t.Fatalf("sent %d new login alerts, want one %v", len(alerts), tt.wantAlert)
+			}
+			if tt.wantAlert && (alerts[0].IPAddress != tt.client.IP || alerts[0].UserAgent != tt.client.UserAgent || alerts[0].Time.IsZero()) {
+				t.Errorf("new login alert = %+v, want the login from %+v", alerts[0], tt.client)
+			}
+		})
+	}
+}
+
+func TestGetLoginHistory(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+	clock := newTestClock()
+	s.now = clock.now
+	userID := registerUser(t, s).ID.String()
+
+	// Two logins share a timestamp, so paging has to order them by ID too.
+	client := ClientInfo{IP: "203.0.113.7", UserAgent: "test"}
+	for i, password := range []string{testPassword, "wrong-password", testPassword, testPassword, "wrong-password"} {
+		if i != 3 {
+			clock.advance(time.Second)
+		}
+		if _, err := s.Login(ctx, testEmail, password, client); (err == nil) != (password == testPassword) {
+			t.Fatalf("Login %d error = %v", i, err)
+		}
+	}
+	if _, err := s.Login(ctx, "nobody@example.com", testPassword, client); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login of an unknown email error = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	var events []model.LoginEvent
+	pages := 0
+	pageToken := ""
+	for {
+		page, next, err := s.GetLoginHistory(ctx, userID, 2, pageToken)
+		if err != nil {
+			t.Fatalf("GetLoginHistory: %v", err)
+		}
+		if len(page) > 2 {
+			t.Fatalf("page of %d events, want at most 2", len(page))
+		}
+		events = append(events, page...)
+		pages++
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+
+	if len(events) != 5 || pages != 3 {
+		t.Fatalf("got %d events on %d pages, want 5 on 3", len(events), pages)
+	}
+	seen := map[uuid.UUID]bool{}
+	for i, e := range events {
+		if seen[e.ID] {
+			t.Errorf("event %s listed twice", e.ID)
+		}
+		seen[e.ID] = true
+		if i > 0 && e.CreatedAt.After(events[i-1].CreatedAt) {
+			t.Errorf("event %d is newer than event %d", i, i-1)
+		}
+	}
+	newest := events[0]
+	if newest.Success || newest.Reason != model.LoginReasonInvalidCredentials || newest.IPAddress != client.IP || newest.UserAgent != client.UserAgent {
+		t.Errorf("newest event = %+v, want the failed login", newest)
+	}
+	if oldest := events[len(events)-1]; !oldest.Success || oldest.Reason != "" {
+		t.Errorf("oldest event = %+v, want the first successful login", oldest)
+	}
+
+	tests := []struct {
+		name      string
+		userID    string
+		pageToken string
+		wantErr   error
+	}{
+		{name: "malformed page token", userID: userID, pageToken: "not base64!", wantErr: ErrInvalidPageToken},
+		{name: "page token that is not JSON", userID: userID, pageToken: "bm90LWpzb24", wantErr: ErrInvalidPageToken},
+		{name: "malformed user ID", userID: "not-a-uuid", wantErr: ErrRecordNotFound},
+		{name: "user without logins", userID: uuid.NewString()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, next, err := s.GetLoginHistory(ctx, tt.userID, 0, tt.pageToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetLoginHistory error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (len(page) != 0 || next != "") {
+				t.Errorf("GetLoginHistory = %d events, %q, want an empty last page", len(page), next)
+			}
+		})
+	}
+}
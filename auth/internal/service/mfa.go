@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/PakornBank/go-grpc-example/auth/internal/totp"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMFAIssuer      = "go-grpc-example"
+	mfaChallengeExpiry    = 5 * time.Minute
+	mfaChallengeAttempts  = 5
+	recoveryCodeCount     = 10
+	recoveryCodeByteCount = 6
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAEnrollment holds the secret of a new TOTP factor and the otpauth URI
+// that authenticator apps import.
+type MFAEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// EnrollMFA creates a new TOTP secret for a user. MFA is not enabled until the
+// secret is confirmed with ConfirmMFA; enrolling again replaces the secret.
+func (s *service) EnrollMFA(ctx context.Context, userID string) (*MFAEnrollment, error) {
+	credential, err := s.findCredential(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate MFA secret: %w", err)
+	}
+
+	saved, err := s.repository.SaveMFAFactor(ctx, &model.MFAFactor{
+		UserID:    credential.ID,
+		Secret:    secret,
+		CreatedAt: s.now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save MFA factor: %w", err)
+	}
+	if !saved {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return &MFAEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.mfaIssuer, credential.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA enables MFA for a user once it presents a valid code for the
+// enrolled secret, and returns a fresh set of recovery codes. The codes are
+// only stored hashed, so they cannot be shown again.
+func (s *service) ConfirmMFA(ctx context.Context, userID, code string) ([]string, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	factor, err := s.repository.FindMFAFactor(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find MFA factor: %w", err)
+	}
+	if factor == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	now := s.now()
+	step, ok := totp.Validate(factor.Secret, code, now)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashed, err := generateRecoveryCodes(uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	if err := s.repository.Transaction(ctx, func(tx repository.Repository) error {
+		confirmed, err := tx.ConfirmMFAFactor(ctx, uid, now, step)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return ErrMFAAlreadyEnabled
+		}
+		return tx.ReplaceRecoveryCodes(ctx, uid, hashed)
+	}); err != nil {
+		if errors.Is(err, ErrMFAAlreadyEnabled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to confirm MFA factor: %w", err)
+	}
+
+	return codes, nil
+}
+
+// DisableMFA removes the MFA factor and recovery codes of a user after
+// checking its password.
+func (s *service) DisableMFA(ctx context.Context, userID, password string) error {
+	credential, err := s.findCredential(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPassword(credential, password); err != nil {
+		return err
+	}
+
+	if err := s.repository.DeleteMFAFactor(ctx, credential.ID); err != nil {
+		return fmt.Errorf("failed to delete MFA factor: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyMFA completes a login that returned an MFA token. The code is either
+// a TOTP code or an unused recovery code. A challenge is dropped after too
+// many wrong codes, and wrong codes count as failed logins against the email
+// and client IP, so that the lockout of Login applies across challenges. A
+// completed login starts a session on the device of the client and is
+// recorded in the login history, as are wrong codes.
+func (s *service) VerifyMFA(ctx context.Context, mfaToken, code string, client ClientInfo) (*TokenPair, error) {
+	if mfaToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	challenge, err := s.repository.FindMFAChallengeByHash(ctx, hashToken(mfaToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find MFA challenge: %w", err)
+	}
+	if challenge == nil || s.now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	factor, err := s.repository.FindMFAFactor(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find MFA factor: %w", err)
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.repository.FindByID(ctx, challenge.UserID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.checkLoginThrottle(ctx, user.Email, client.IP); err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			s.auditLoginFailure(ctx, user.Email, user, client, model.LoginReasonLockedOut)
+		}
+		return nil, err
+	}
+
+	valid, err := s.checkSecondFactor(ctx, factor, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		if err := s.repository.CountMFAChallengeAttempt(ctx, challenge.ID, mfaChallengeAttempts); err != nil {
+			return nil, fmt.Errorf("failed to count MFA attempt: %w", err)
+		}
+		s.recordLoginFailure(ctx, user.Email, client.IP)
+		s.auditLoginFailure(ctx, user.Email, user, client, model.LoginReasonInvalidMFACode)
+		return nil, ErrInvalidMFACode
+	}
+
+	completed, err := s.repository.DeleteMFAChallenge(ctx, challenge.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete MFA challenge: %w", err)
+	}
+	if !completed {
+		return nil, ErrInvalidToken
+	}
+
+	s.resetLoginThrottle(ctx, user.Email)
+	tokens, err := s.startSession(ctx, user, client, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.auditLoginSuccess(ctx, user, client)
+
+	return tokens, nil
+}
+
+// startMFAChallenge returns the token of a new MFA challenge if the user has
+// MFA enabled, or an empty string if it does not.
+func (s *service) startMFAChallenge(ctx context.Context, user *model.Credential) (string, error) {
+	factor, err := s.repository.FindMFAFactor(ctx, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to find MFA factor: %w", err)
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return "", nil
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate MFA token: %w", err)
+	}
+
+	if err := s.repository.CreateMFAChallenge(ctx, &model.MFAChallenge{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: s.now().Add(mfaChallengeExpiry),
+	}); err != nil {
+		return "", fmt.Errorf("failed to store MFA challenge: %w", err)
+	}
+
+	return token, nil
+}
+
+// checkSecondFactor reports whether code is a TOTP code that was not used
+// before or an unused recovery code, and consumes it.
+func (s *service) checkSecondFactor(ctx context.Context, factor *model.MFAFactor, code string) (bool, error) {
+	now := s.now()
+
+	if step, ok := totp.Validate(factor.Secret, code, now); ok {
+		fresh, err := s.repository.UseMFAStep(ctx, factor.UserID, step)
+		if err != nil {
+			return false, fmt.Errorf("failed to record MFA code: %w", err)
+		}
+		return fresh, nil
+	}
+
+	used, err := s.repository.UseRecoveryCode(ctx, factor.UserID, hashToken(normalizeRecoveryCode(code)), now)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return used, nil
+}
+
+// findCredential retrieves the credential of a user by its ID.
+func (s *service) findCredential(ctx context.Context, userID string) (*model.Credential, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	credential, err := s.repository.FindByID(ctx, uid.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if credential == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return credential, nil
+}
+
+// generateRecoveryCodes returns new recovery codes in the form xxxxx-xxxxx
+// together with the records that store their hashes.
+func generateRecoveryCodes(userID uuid.UUID) ([]string, []model.MFARecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashed := make([]model.MFARecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeByteCount)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashed = append(hashed, model.MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(code),
+		})
+	}
+
+	return codes, hashed, nil
+}
+
+// normalizeRecoveryCode strips the separator and case from a recovery code.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
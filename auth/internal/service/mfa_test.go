@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/PakornBank/go-grpc-example/auth/internal/totp"
+)
+
+// mfaUser is a registered user with a confirmed TOTP factor.
+type mfaUser struct {
+	s             *service
+	clock         *testClock
+	secret        string
+	recoveryCodes []string
+}
+
+// newMFAUser registers testEmail and enables MFA for it at the current time of the clock.
+func newMFAUser(t *testing.T) *mfaUser {
+	t.Helper()
+
+	ctx := context.Background()
+	clock := newTestClock()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+	s.now = clock.now
+	credential := registerUser(t, s)
+
+	enrollment, err := s.EnrollMFA(ctx, credential.ID.String())
+	if err != nil {
+		t.Fatalf("EnrollMFA: %v", err)
+	}
+	recoveryCodes, err := s.ConfirmMFA(ctx, credential.ID.String(), totpCode(t, enrollment.Secret, clock.now()))
+	if err != nil {
+		t.Fatalf("ConfirmMFA: %v", err)
+	}
+
+	return &mfaUser{s: s, clock: clock, secret: enrollment.Secret, recoveryCodes: recoveryCodes}
+}
+
+// login logs in with the password and returns the MFA token.
+func (u *mfaUser) login(t *testing.T) string {
+	t.Helper()
+
+	result, err := u.s.Login(context.Background(), testEmail, testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if result.Tokens != nil || result.MFAToken == "" {
+		t.Fatalf("Login = %+v, want only an MFA token", result)
+	}
+	return result.MFAToken
+}
+
+// code returns the TOTP code of the user for the time step at the given offset from now.
+func (u *mfaUser) code(t *testing.T, steps int) string {
+	t.Helper()
+
+	return totpCode(t, u.secret, u.clock.now().Add(time.Duration(steps)*30*time.Second))
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Step(at))
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+	return code
+}
+
+func TestVerifyMFASkew(t *testing.T) {
+	tests := []struct {
+		steps   int
+		wantErr error
+	}{
+		{steps: -2, wantErr: ErrInvalidMFACode},
+		{steps: -1},
+		{steps: 0},
+		{steps: 1},
+		{steps: 2, wantErr: ErrInvalidMFACode},
+	}
+
+	for _, tt := range tests {
+		u := newMFAUser(t)
+		u.clock.advance(10 * time.Minute)
+
+		tokens, err := u.s.VerifyMFA(context.Background(), u.login(t), u.code(t, tt.steps), ClientInfo{})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("VerifyMFA with a code %d steps away: error = %v, want %v", tt.steps, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && (tokens == nil || tokens.AccessToken == "") {
+			t.Errorf("VerifyMFA with a code %d steps away returned no tokens", tt.steps)
+		}
+	}
+}
+
+func TestVerifyMFARejectsReplayedSteps(t *testing.T) {
+	tests := []struct {
+		name string
+		// first and second are the steps, relative to now, of the codes of two logins.
+		first, second int
+		wantErr       error
+	}{
+		{name: "same code twice", first: 0, second: 0, wantErr: ErrInvalidMFACode},
+		{name: "earlier code after a later one", first: 1, second: 0, wantErr: ErrInvalidMFACode},
+		{name: "later code after an earlier one", first: -1, second: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			u := newMFAUser(t)
+			u.clock.advance(10 * time.Minute)
+
+			if _, err := u.s.VerifyMFA(ctx, u.login(t), u.code(t, tt.first), ClientInfo{}); err != nil {
+				t.Fatalf("first VerifyMFA: %v", err)
+			}
+			if _, err := u.s.VerifyMFA(ctx, u.login(t), u.code(t, tt.second), ClientInfo{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("second VerifyMFA error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyMFARejectsConfirmationCode(t *testing.T) {
+	u := newMFAUser(t)
+
+	// The code that confirmed the factor is still within the skew window.
+	if _, err := u.s.VerifyMFA(context.Background(), u.login(t), u.code(t, 0), ClientInfo{}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA with the confirmation code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
+
+func TestVerifyMFAAttemptLimit(t *testing.T) {
+	tests := []struct {
+		wrongCodes int
+		wantErr    error
+	}{
+		{wrongCodes: 0},
+		{wrongCodes: mfaChallengeAttempts - 1},
+		{wrongCodes: mfaChallengeAttempts, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		u := newMFAUser(t)
+		u.clock.advance(10 * time.Minute)
+		mfaToken := u.login(t)
+
+		for i := 0; i < tt.wrongCodes; i++ {
+			if _, err := u.s.VerifyMFA(ctx, mfaToken, "000000", ClientInfo{}); !errors.Is(err, ErrInvalidMFACode) {
+				t.Fatalf("VerifyMFA with a wrong code error = %v, want %v", err, ErrInvalidMFACode)
+			}
+		}
+
+		if _, err := u.s.VerifyMFA(ctx, mfaToken, u.code(t, 0), ClientInfo{}); !errors.Is(err, tt.wantErr) {
+			t.Errorf("VerifyMFA after %d wrong codes error = %v, want %v", tt.wrongCodes, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyMFALockout(t *testing.T) {
+	tests := []struct {
+		wrongCodes int
+		wantErr    error
+	}{
+		{wrongCodes: defaultLoginLockoutThreshold - 1},
+		{wrongCodes: defaultLoginLockoutThreshold, wantErr: ErrTooManyAttempts},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		u := newMFAUser(t)
+		u.clock.advance(10 * time.Minute)
+		pending := u.login(t)
+
+		// Spread the wrong codes over challenges that each stay below the
+		// attempt limit, with a correct password in between.
+		var mfaToken string
+		for i := 0; i < tt.wrongCodes; i++ {
+			if i%2 == 0 {
+				mfaToken = u.login(t)
+			}
+			if _, err := u.s.VerifyMFA(ctx, mfaToken, "000000", ClientInfo{}); !errors.Is(err, ErrInvalidMFACode) {
+				t.Fatalf("VerifyMFA with a wrong code error = %v, want %v", err, ErrInvalidMFACode)
+			}
+		}
+
+		if _, err := u.s.VerifyMFA(ctx, pending, u.code(t, 0), ClientInfo{}); !errors.Is(err, tt.wantErr) {
+			t.Errorf("VerifyMFA after %d wrong codes error = %v, want %v", tt.wrongCodes, err, tt.wantErr)
+		}
+		if _, err := u.s.Login(ctx, testEmail, testPassword, ClientInfo{}); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Login after %d wrong codes error = %v, want %v", tt.wrongCodes, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyMFAExpiredChallenge(t *testing.T) {
+	u := newMFAUser(t)
+	mfaToken := u.login(t)
+	u.clock.advance(mfaChallengeExpiry + time.Second)
+
+	if _, err := u.s.VerifyMFA(context.Background(), mfaToken, u.code(t, 0), ClientInfo{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyMFA with an expired challenge error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestVerifyMFARecoveryCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		format func(code string) string
+	}{
+		{name: "as issued", format: func(c string) string { return c }},
+		{name: "upper case", format: strings.ToUpper},
+		{name: "without separator", format: func(c string) string { return strings.ReplaceAll(c, "-", "") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			u := newMFAUser(t)
+			if len(u.recoveryCodes) != recoveryCodeCount {
+				t.Fatalf("got %d recovery codes, want %d", len(u.recoveryCodes), recoveryCodeCount)
+			}
+			code := tt.format(u.recoveryCodes[0])
+
+			if _, err := u.s.VerifyMFA(ctx, u.login(t), code, ClientInfo{}); err != nil {
+				t.Fatalf("VerifyMFA with a recovery code: %v", err)
+			}
+			if _, err := u.s.VerifyMFA(ctx, u.login(t), code, ClientInfo{}); !errors.Is(err, ErrInvalidMFACode) {
+				t.Errorf("VerifyMFA with a used recovery code error = %v, want %v", err, ErrInvalidMFACode)
+			}
+			if _, err := u.s.VerifyMFA(ctx, u.login(t), u.recoveryCodes[1], ClientInfo{}); err != nil {
+				t.Errorf("VerifyMFA with another recovery code: %v", err)
+			}
+		})
+	}
+}
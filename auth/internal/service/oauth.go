@@ -0,0 +1,607 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	// authorizationCodeExpiry is how long an authorization code can be exchanged for tokens.
+	authorizationCodeExpiry = time.Minute
+	// codeChallengeMethodS256 is the only PKCE method accepted; plain is not.
+	codeChallengeMethodS256 = "S256"
+)
+
+// OAuth2 error codes from RFC 6749.
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+)
+
+// ErrInvalidClientMetadata is returned when an OAuth client cannot be registered as described.
+var ErrInvalidClientMetadata = errors.New("invalid client metadata")
+
+// OAuthError is an OAuth2 error response. Redirect is set once the client and
+// redirect URI of an authorization request were validated, which means the
+// error may be sent to the client through the redirect URI.
+type OAuthError struct {
+	Code        string
+	Description string
+	Redirect    bool
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// Consent is the decision of a user on an authorization request.
+type Consent int
+
+const (
+	// ConsentUnspecified uses the consent the user gave before, if it covers the requested scope.
+	ConsentUnspecified Consent = iota
+	ConsentGranted
+	ConsentDenied
+)
+
+// OAuthClientSpec describes an OAuth client to register.
+type OAuthClientSpec struct {
+	Name         string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scope        string
+	Confidential bool
+}
+
+// OAuthClientRegistration is a registered client. The secret of a
+// confidential client is only available here.
+type OAuthClientRegistration struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// AuthorizeRequest is an authorization request made by the signed in user.
+type AuthorizeRequest struct {
+	UserID              string
+	ClientID            string
+	ResponseType        string
+	RedirectURI         string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+	Prompt              string
+	Consent             Consent
+}
+
+// AuthorizeResult is either an authorization code for the redirect URI, or a
+// request to ask the user for consent to the given scope.
+type AuthorizeResult struct {
+	Code            string
+	Scope           string
+	ConsentRequired bool
+	ClientName      string
+}
+
+// TokenRequest is a request to the token endpoint.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// OAuthToken is a successful token endpoint response.
+type OAuthToken struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Scope        string
+	ExpiresIn    time.Duration
+}
+
+// oauthGrant is what an OAuth client was granted. It is carried in the tokens
+// issued to the client.
+type oauthGrant struct {
+	clientID string
+	scope    string
+	// accessScope narrows the scope of the access token when the client asks
+	// for less than it was granted. The refresh token keeps the full scope.
+	accessScope string
+}
+
+// tokenScope returns the scope carried in the access token of the grant.
+func (g *oauthGrant) tokenScope() string {
+	if g.accessScope != "" {
+		return g.accessScope
+	}
+	return g.scope
+}
+
+// grantOf returns the grant a refresh token was issued with, if any.
+func grantOf(token *model.RefreshToken) *oauthGrant {
+	if token.ClientID == "" {
+		return nil
+	}
+	return &oauthGrant{clientID: token.ClientID, scope: token.Scope}
+}
+
+// CreateOAuthClient registers a new OAuth client. Confidential clients get a
+// secret, which is returned once and only stored hashed.
+func (s *service) CreateOAuthClient(ctx context.Context, spec OAuthClientSpec) (*OAuthClientRegistration, error) {
+	if err := validateClientSpec(&spec); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate client ID: %w", err)
+	}
+
+	client := &model.OAuthClient{
+		ID:           base64.RawURLEncoding.EncodeToString(id),
+		Name:         spec.Name,
+		RedirectURIs: spec.RedirectURIs,
+		GrantTypes:   spec.GrantTypes,
+		Scope:        spec.Scope,
+	}
+
+	var secret string
+	if spec.Confidential {
+		var err error
+		if secret, err = generateOpaqueToken(); err != nil {
+			return nil, fmt.Errorf("failed to generate client secret: %w", err)
+		}
+		client.SecretHash = hashToken(secret)
+	}
+
+	if err := s.repository.CreateOAuthClient(ctx, client); err != nil {
+		return nil, fmt.Errorf("failed to create OAuth client: %w", err)
+	}
+
+	return &OAuthClientRegistration{
+		ClientID:     client.ID,
+		ClientSecret: secret,
+	}, nil
+}
+
+// Authorize handles an authorization request of the authorization code flow
+// with PKCE. The user is asked for consent unless a previous consent covers
+// the requested scope; a granted consent is stored for later requests.
+func (s *service) Authorize(ctx context.Context, req AuthorizeRequest) (*AuthorizeResult, error) {
+	client, err := s.repository.FindOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find OAuth client: %w", err)
+	}
+	if client == nil {
+		return nil, &OAuthError{Code: OAuthInvalidClient, Description: "unknown client"}
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, &OAuthError{Code: OAuthInvalidRequest, Description: "redirect_uri is not registered for the client"}
+	}
+
+	// From here on errors are reported to the client through its redirect URI.
+	if req.ResponseType != "code" {
+		return nil, &OAuthError{Code: OAuthUnsupportedResponseType, Description: "only the code response type is supported", Redirect: true}
+	}
+	if !slices.Contains(client.GrantTypes, model.GrantTypeAuthorizationCode) {
+		return nil, &OAuthError{Code: OAuthUnauthorizedClient, Description: "client may not use the authorization code flow", Redirect: true}
+	}
+	if req.CodeChallengeMethod != codeChallengeMethodS256 || !validPKCEValue(req.CodeChallenge) {
+		return nil, &OAuthError{Code: OAuthInvalidRequest, Description: "an S256 code_challenge is required", Redirect: true}
+	}
+	scope, ok := resolveScope(req.Scope, client.Scope)
+	if !ok {
+		return nil, &OAuthError{Code: OAuthInvalidScope, Description: "scope is not allowed for the client", Redirect: true}
+	}
+	if slices.Contains(strings.Fields(scope), ScopeOpenID) && s.oidcIssuer == "" {
+		return nil, &OAuthError{Code: OAuthInvalidScope, Description: "OpenID Connect is not enabled", Redirect: true}
+	}
+	if req.Consent == ConsentDenied {
+		return nil, &OAuthError{Code: OAuthAccessDenied, Description: "the user denied the request", Redirect: true}
+	}
+
+	user, err := s.findCredential(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	consent, err := s.repository.FindOAuthConsent(ctx, user.ID, client.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find OAuth consent: %w", err)
+	}
+	granted := ""
+	if consent != nil {
+		granted = consent.Scope
+	}
+
+	if !scopeCovers(granted, scope) {
+		if req.Consent != ConsentGranted {
+			if slices.Contains(strings.Fields(req.Prompt), "none") {
+				return nil, &OAuthError{Code: OIDCConsentRequired, Description: "the user has to consent to the request", Redirect: true}
+			}
+			return &AuthorizeResult{
+				Scope:           scope,
+				ConsentRequired: true,
+				ClientName:      client.Name,
+			}, nil
+		}
+		if err := s.repository.SaveOAuthConsent(ctx, &model.OAuthConsent{
+			UserID:   user.ID,
+			ClientID: client.ID,
+			Scope:    mergeScopes(granted, scope),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to save OAuth consent: %w", err)
+		}
+	}
+
+	code, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+
+	if err := s.repository.CreateAuthorizationCode(ctx, &model.OAuthAuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		FamilyID:      uuid.New(),
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		ExpiresAt:     time.Now().Add(authorizationCodeExpiry),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store authorization code: %w", err)
+	}
+
+	return &AuthorizeResult{Code: code, Scope: scope}, nil
+}
+
+// Token handles a request to the token endpoint for the authorization code,
+// refresh token and client credentials grants.
+func (s *service) Token(ctx context.Context, req TokenRequest) (*OAuthToken, error) {
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case model.GrantTypeAuthorizationCode, model.GrantTypeRefreshToken, model.GrantTypeClientCredentials:
+	default:
+		return nil, &OAuthError{Code: OAuthUnsupportedGrantType, Description: "unsupported grant_type"}
+	}
+	if !slices.Contains(client.GrantTypes, req.GrantType) {
+		return nil, &OAuthError{Code: OAuthUnauthorizedClient, Description: "client may not use this grant type"}
+	}
+
+	switch req.GrantType {
+	case model.GrantTypeAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, client, req)
+	case model.GrantTypeRefreshToken:
+		return s.exchangeRefreshToken(ctx, client, req)
+	default:
+		return s.clientCredentials(client, req)
+	}
+}
+
+// exchangeAuthorizationCode redeems an authorization code issued to the client
+// for the redirect URI after checking the PKCE code verifier. Codes issued for
+// the openid scope also get an ID token. Presenting a code again revokes the
+// refresh tokens issued for it.
+func (s *service) exchangeAuthorizationCode(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*OAuthToken, error) {
+	invalidGrant := &OAuthError{Code: OAuthInvalidGrant, Description: "invalid or expired authorization code"}
+	if req.Code == "" {
+		return nil, invalidGrant
+	}
+
+	// The code is checked against the client and redirect URI before it is
+	// consumed, so that presenting it elsewhere does not burn it.
+	codeHash := hashToken(req.Code)
+	issued, err := s.repository.FindAuthorizationCodeByHash(ctx, codeHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find authorization code: %w", err)
+	}
+	if issued == nil || issued.ClientID != client.ID || issued.RedirectURI != req.RedirectURI {
+		return nil, invalidGrant
+	}
+
+	code, err := s.repository.ConsumeAuthorizationCode(ctx, codeHash, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+	if code == nil {
+		s.revokeReusedCode(ctx, codeHash)
+		return nil, invalidGrant
+	}
+
+	if !validPKCEValue(req.CodeVerifier) || !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "code_verifier does not match the code_challenge"}
+	}
+
+	user, err := s.repository.FindByID(ctx, code.UserID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if user == nil {
+		return nil, invalidGrant
+	}
+
+	token := &OAuthToken{Scope: code.Scope, ExpiresIn: s.tokenExpiry}
+	grant := &oauthGrant{clientID: client.ID, scope: code.Scope}
+	if slices.Contains(client.GrantTypes, model.GrantTypeRefreshToken) {
+		tokens, err := s.issueTokens(ctx, user, code.FamilyID, grant, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		token.AccessToken = tokens.AccessToken
+		token.RefreshToken = tokens.RefreshToken
+	} else {
+		if token.AccessToken, err = s.accessToken(ctx, user, "", grant, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(strings.Fields(code.Scope), ScopeOpenID) {
+		if token.IDToken, err = s.idToken(ctx, user, client.ID, code.Scope, code.Nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+// exchangeRefreshToken rotates a refresh token that was issued to the client.
+// The scope of the refresh token cannot be widened. A narrower scope limits the
+// new access token, while the new refresh token keeps the granted scope.
+func (s *service) exchangeRefreshToken(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*OAuthToken, error) {
+	invalidGrant := &OAuthError{Code: OAuthInvalidGrant, Description: "invalid or expired refresh token"}
+	if req.RefreshToken == "" {
+		return nil, invalidGrant
+	}
+
+	stored, err := s.repository.FindRefreshTokenByHash(ctx, hashToken(req.RefreshToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+	if stored == nil || stored.ClientID != client.ID {
+		return nil, invalidGrant
+	}
+	scope, ok := resolveScope(req.Scope, stored.Scope)
+	if !ok {
+		return nil, &OAuthError{Code: OAuthInvalidScope, Description: "scope exceeds the scope of the refresh token"}
+	}
+
+	tokens, err := s.rotateRefreshToken(ctx, req.RefreshToken, client.ID, scope)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenReused) {
+			return nil, invalidGrant
+		}
+		return nil, err
+	}
+
+	return &OAuthToken{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		Scope:        scope,
+		ExpiresIn:    s.tokenExpiry,
+	}, nil
+}
+
+// clientCredentials issues an access token to a confidential client acting on
+// its own behalf. No refresh token is issued, since the client can always
+// request a new token.
+func (s *service) clientCredentials(client *model.OAuthClient, req TokenRequest) (*OAuthToken, error) {
+	if !client.Confidential() {
+		return nil, &OAuthError{Code: OAuthUnauthorizedClient, Description: "public clients may not use the client credentials grant"}
+	}
+
+	scope, ok := resolveScope(req.Scope, client.Scope)
+	if !ok {
+		return nil, &OAuthError{Code: OAuthInvalidScope, Description: "scope is not allowed for the client"}
+	}
+
+	now := time.Now()
+	accessToken, err := s.keys.Sign(jwt.MapClaims{
+		"jti":       uuid.New().String(),
+		"sub":       client.ID,
+		"client_id": client.ID,
+		"scope":     scope,
+		"iat":       issuedAt(now),
+		"exp":       now.Add(s.tokenExpiry).Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return &OAuthToken{AccessToken: accessToken, Scope: scope, ExpiresIn: s.tokenExpiry}, nil
+}
+
+// authenticateClient finds a client and checks its secret. Public clients must not send a secret.
+func (s *service) authenticateClient(ctx context.Context, clientID, clientSecret string) (*model.OAuthClient, error) {
+	invalidClient := &OAuthError{Code: OAuthInvalidClient, Description: "client authentication failed"}
+	if clientID == "" {
+		return nil, invalidClient
+	}
+
+	client, err := s.repository.FindOAuthClient(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find OAuth client: %w", err)
+	}
+	if client == nil {
+		return nil, invalidClient
+	}
+
+	if !client.Confidential() {
+		if clientSecret != "" {
+			return nil, invalidClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, invalidClient
+	}
+
+	return client, nil
+}
+
+// revokeReusedCode revokes the refresh tokens issued for an authorization code
+// that was already exchanged, since the code may have been stolen.
+func (s *service) revokeReusedCode(ctx context.Context, codeHash string) {
+	code, err := s.repository.FindAuthorizationCodeByHash(ctx, codeHash)
+	if err != nil {
+		log.Printf("failed to find authorization code: %v", err)
+		return
+	}
+	if code == nil || code.UsedAt == nil {
+		return
+	}
+
+	if err := s.repository.RevokeRefreshTokenFamily(ctx, code.FamilyID); err != nil {
+		log.Printf("failed to revoke tokens of reused authorization code %s: %v", code.ID, err)
+	}
+}
+
+// validateClientSpec checks and normalizes the description of a client to register.
+func validateClientSpec(spec *OAuthClientSpec) error {
+	spec.Name = strings.TrimSpace(spec.Name)
+	if spec.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidClientMetadata)
+	}
+
+	if len(spec.GrantTypes) == 0 {
+		spec.GrantTypes = []string{model.GrantTypeAuthorizationCode, model.GrantTypeRefreshToken}
+	}
+	for _, grantType := range spec.GrantTypes {
+		switch grantType {
+		case model.GrantTypeAuthorizationCode, model.GrantTypeRefreshToken:
+		case model.GrantTypeClientCredentials:
+			if !spec.Confidential {
+				return fmt.Errorf("%w: public clients may not use the client credentials grant", ErrInvalidClientMetadata)
+			}
+		default:
+			return fmt.Errorf("%w: unsupported grant type %q", ErrInvalidClientMetadata, grantType)
+		}
+	}
+
+	if slices.Contains(spec.GrantTypes, model.GrantTypeAuthorizationCode) && len(spec.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: the authorization code grant requires a redirect URI", ErrInvalidClientMetadata)
+	}
+	for _, redirectURI := range spec.RedirectURIs {
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return fmt.Errorf("%w: redirect URI %q must be absolute and have no fragment", ErrInvalidClientMetadata, redirectURI)
+		}
+	}
+
+	scopes := strings.Fields(spec.Scope)
+	for _, scope := range scopes {
+		if !validScopeToken(scope) {
+			return fmt.Errorf("%w: invalid scope %q", ErrInvalidClientMetadata, scope)
+		}
+	}
+	spec.Scope = strings.Join(scopes, " ")
+
+	return nil
+}
+
+// resolveScope returns the scope to grant for a request, or false if the
+// client may not use some of the requested scopes. An empty request gets every
+// scope the client may use.
+func resolveScope(requested, allowed string) (string, bool) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, true
+	}
+
+	allowedScopes := strings.Fields(allowed)
+	for _, scope := range scopes {
+		if !slices.Contains(allowedScopes, scope) {
+			return "", false
+		}
+	}
+
+	return strings.Join(scopes, " "), true
+}
+
+// scopeCovers reports whether every scope of requested is in granted.
+func scopeCovers(granted, requested string) bool {
+	grantedScopes := strings.Fields(granted)
+	for _, scope := range strings.Fields(requested) {
+		if !slices.Contains(grantedScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeScopes returns the union of two scopes.
+func mergeScopes(a, b string) string {
+	scopes := strings.Fields(a)
+	for _, scope := range strings.Fields(b) {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+// validScopeToken reports whether s is a scope token as defined by RFC 6749 section 3.3.
+func validScopeToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < 0x21 || c > 0x7e || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// validPKCEValue reports whether s is a well-formed code verifier or S256
+// code challenge as defined by RFC 7636.
+func validPKCEValue(s string) bool {
+	if len(s) < 43 || len(s) > 128 {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// verifyCodeChallenge checks a code verifier against an S256 code challenge.
+func verifyCodeChallenge(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,461 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+)
+
+const (
+	testRedirectURI = "https://app.example.com/callback"
+	// testCodeVerifier and testCodeChallenge are the S256 example of RFC 7636 appendix B.
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+// expiredCodes consumes authorization codes as if their expiry had passed.
+type expiredCodes struct {
+	*repositorytest.Repository
+}
+
+func (r expiredCodes) ConsumeAuthorizationCode(ctx context.Context, codeHash string, now time.Time) (*model.OAuthAuthorizationCode, error) {
+	return r.Repository.ConsumeAuthorizationCode(ctx, codeHash, now.Add(authorizationCodeExpiry+time.Second))
+}
+
+// wantOAuthError fails the test unless err is an OAuthError with the given code.
+// An empty code expects no error.
+func wantOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	if code == "" {
+		if err != nil {
+			t.Fatalf("error = %v, want none", err)
+		}
+		return
+	}
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Fatalf("error = %v, want OAuth error %s", err, code)
+	}
+}
+
+// oauthClient registers a client that may use the authorization code and
+// refresh token grants for the profile scope.
+func oauthClient(t *testing.T, s *service, confidential bool) *OAuthClientRegistration {
+	t.Helper()
+
+	client, err := s.CreateOAuthClient(context.Background(), OAuthClientSpec{
+		Name:         "Test App",
+		RedirectURIs: []string{testRedirectURI},
+		Scope:        "profile email",
+		Confidential: confidential,
+	})
+	if err != nil {
+		t.Fatalf("CreateOAuthClient: %v", err)
+	}
+	return client
+}
+
+// authorizeRequest is a valid request of the user for the profile scope that the user consents to.
+func authorizeRequest(userID, clientID string) AuthorizeRequest {
+	return AuthorizeRequest{
+		UserID:              userID,
+		ClientID:            clientID,
+		ResponseType:        "code",
+		RedirectURI:         testRedirectURI,
+		Scope:               "profile",
+		CodeChallenge:       testCodeChallenge,
+		CodeChallengeMethod: "S256",
+		Consent:             ConsentGranted,
+	}
+}
+
+func authorize(t *testing.T, s *service, userID, clientID string) string {
+	t.Helper()
+
+	result, err := s.Authorize(context.Background(), authorizeRequest(userID, clientID))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if result.Code == "" {
+		t.Fatalf("Authorize = %+v, want a code", result)
+	}
+	return result.Code
+}
+
+// codeRequest exchanges code for tokens as the client would.
+func codeRequest(client *OAuthClientRegistration, code string) TokenRequest {
+	return TokenRequest{
+		GrantType:    model.GrantTypeAuthorizationCode,
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+	}
+}
+
+func TestCreateOAuthClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       OAuthClientSpec
+		wantSecret bool
+		wantErr    error
+	}{
+		{name: "public client", spec: OAuthClientSpec{Name: "SPA", RedirectURIs: []string{testRedirectURI}}},
+		{name: "confidential client", spec: OAuthClientSpec{Name: "Backend", RedirectURIs: []string{testRedirectURI}, Confidential: true}, wantSecret: true},
+		{
+			name:       "client credentials only",
+			spec:       OAuthClientSpec{Name: "Worker", GrantTypes: []string{model.GrantTypeClientCredentials}, Confidential: true},
+			wantSecret: true,
+		},
+		{name: "missing name", spec: OAuthClientSpec{Name: " ", RedirectURIs: []string{testRedirectURI}}, wantErr: ErrInvalidClientMetadata},
+		{name: "missing redirect URI", spec: OAuthClientSpec{Name: "SPA"}, wantErr: ErrInvalidClientMetadata},
+		{name: "relative redirect URI", spec: OAuthClientSpec{Name: "SPA", RedirectURIs: []string{"/callback"}}, wantErr: ErrInvalidClientMetadata},
+		{name: "redirect URI with a fragment", spec: OAuthClientSpec{Name: "SPA", RedirectURIs: []string{testRedirectURI + "#x"}}, wantErr: ErrInvalidClientMetadata},
+		{name: "unsupported grant type", spec: OAuthClientSpec{Name: "SPA", RedirectURIs: []string{testRedirectURI}, GrantTypes: []string{"implicit"}}, wantErr: ErrInvalidClientMetadata},
+		{
+			name:    "public client with client credentials",
+			spec:    OAuthClientSpec{Name: "SPA", GrantTypes: []string{model.GrantTypeClientCredentials}},
+			wantErr: ErrInvalidClientMetadata,
+		},
+		{name: "invalid scope", spec: OAuthClientSpec{Name: "SPA", RedirectURIs: []string{testRedirectURI}, Scope: `profile "email"`}, wantErr: ErrInvalidClientMetadata},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := repositorytest.New()
+			s := newTestService(t, r, newFakeUserClient())
+
+			registration, err := s.CreateOAuthClient(ctx, tt.spec)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateOAuthClient error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (registration.ClientSecret != "") != tt.wantSecret {
+				t.Errorf("client secret %q, want one %v", registration.ClientSecret, tt.wantSecret)
+			}
+
+			stored, err := r.FindOAuthClient(ctx, registration.ClientID)
+			if err != nil || stored == nil {
+				t.Fatalf("FindOAuthClient = %v, %v", stored, err)
+			}
+			if tt.wantSecret && stored.SecretHash == registration.ClientSecret {
+				t.Error("client secret is stored in plain text")
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(r *AuthorizeRequest)
+		// priorConsent makes the user consent to the profile scope before the request.
+		priorConsent bool
+		wantCode     string
+		wantRedirect bool
+		wantConsent  bool
+	}{
+		{name: "consent granted"},
+		{name: "consent required", request: func(r *AuthorizeRequest) { r.Consent = ConsentUnspecified }, wantConsent: true},
+		{name: "consent remembered", request: func(r *AuthorizeRequest) { r.Consent = ConsentUnspecified }, priorConsent: true},
+		{
+			name:         "consent does not cover a wider scope",
+			request:      func(r *AuthorizeRequest) { r.Consent = ConsentUnspecified; r.Scope = "profile email" },
+			priorConsent: true, wantConsent: true,
+		},
+		{
+			name:     "consent required without prompting",
+			request:  func(r *AuthorizeRequest) { r.Consent = ConsentUnspecified; r.Prompt = "none" },
+			wantCode: OIDCConsentRequired, wantRedirect: true,
+		},
+		{name: "consent denied", request: func(r *AuthorizeRequest) { r.Consent = ConsentDenied }, wantCode: OAuthAccessDenied, wantRedirect: true},
+		{name: "unknown client", request: func(r *AuthorizeRequest) { r.ClientID = "unknown" }, wantCode: OAuthInvalidClient},
+		{name: "unregistered redirect URI", request: func(r *AuthorizeRequest) { r.RedirectURI = "https://evil.example.com/callback" }, wantCode: OAuthInvalidRequest},
+		{name: "token response type", request: func(r *AuthorizeRequest) { r.ResponseType = "token" }, wantCode: OAuthUnsupportedResponseType, wantRedirect: true},
+		{name: "missing code challenge", request: func(r *AuthorizeRequest) { r.CodeChallenge = "" }, wantCode: OAuthInvalidRequest, wantRedirect: true},
+		{name: "plain code challenge", request: func(r *AuthorizeRequest) { r.CodeChallengeMethod = "plain" }, wantCode: OAuthInvalidRequest, wantRedirect: true},
+		{name: "short code challenge", request: func(r *AuthorizeRequest) { r.CodeChallenge = "abc" }, wantCode: OAuthInvalidRequest, wantRedirect: true},
+		{name: "scope not allowed", request: func(r *AuthorizeRequest) { r.Scope = "admin" }, wantCode: OAuthInvalidScope, wantRedirect: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			client := oauthClient(t, s, false)
+			if tt.priorConsent {
+				authorize(t, s, userID, client.ClientID)
+			}
+
+			req := authorizeRequest(userID, client.ClientID)
+			if tt.request != nil {
+				tt.request(&req)
+			}
+			result, err := s.Authorize(context.Background(), req)
+			wantOAuthError(t, err, tt.wantCode)
+			if err != nil {
+				if redirect := err.(*OAuthError).Redirect; redirect != tt.wantRedirect {
+					t.Errorf("error sent to the redirect URI = %v, want %v", redirect, tt.wantRedirect)
+				}
+				return
+			}
+			if result.ConsentRequired != tt.wantConsent || (result.Code == "") != tt.wantConsent {
+				t.Errorf("Authorize = %+v, want consent required %v", result, tt.wantConsent)
+			}
+		})
+	}
+}
+
+func TestTokenAuthorizationCode(t *testing.T) {
+	tests := []struct {
+		name         string
+		confidential bool
+		// expired exchanges the code after it expired.
+		expired  bool
+		request  func(r *TokenRequest)
+		wantCode string
+	}{
+		{name: "public client"},
+		{name: "confidential client", confidential: true},
+		{name: "wrong code verifier", request: func(r *TokenRequest) { r.CodeVerifier = strings.Repeat("a", 43) }, wantCode: OAuthInvalidGrant},
+		{name: "missing code verifier", request: func(r *TokenRequest) { r.CodeVerifier = "" }, wantCode: OAuthInvalidGrant},
+		{name: "code challenge as plain verifier", request: func(r *TokenRequest) { r.CodeVerifier = testCodeChallenge }, wantCode: OAuthInvalidGrant},
+		{name: "redirect URI mismatch", request: func(r *TokenRequest) { r.RedirectURI = "https://app.example.com/other" }, wantCode: OAuthInvalidGrant},
+		{name: "unknown code", request: func(r *TokenRequest) { r.Code = "unknown" }, wantCode: OAuthInvalidGrant},
+		{name: "expired code", expired: true, wantCode: OAuthInvalidGrant},
+		{name: "unsupported grant type", request: func(r *TokenRequest) { r.GrantType = "password" }, wantCode: OAuthUnsupportedGrantType},
+		{name: "grant type not allowed", request: func(r *TokenRequest) { r.GrantType = model.GrantTypeClientCredentials }, wantCode: OAuthUnauthorizedClient},
+		{name: "unknown client", request: func(r *TokenRequest) { r.ClientID = "unknown" }, wantCode: OAuthInvalidClient},
+		{name: "public client with a secret", request: func(r *TokenRequest) { r.ClientSecret = "secret" }, wantCode: OAuthInvalidClient},
+		{name: "wrong client secret", confidential: true, request: func(r *TokenRequest) { r.ClientSecret = "wrong" }, wantCode: OAuthInvalidClient},
+		{name: "missing client secret", confidential: true, request: func(r *TokenRequest) { r.ClientSecret = "" }, wantCode: OAuthInvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := repositorytest.New()
+			s := newTestService(t, r, newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			client := oauthClient(t, s, tt.confidential)
+			code := authorize(t, s, userID, client.ClientID)
+			if tt.expired {
+				s.repository = expiredCodes{r}
+			}
+
+			req := codeRequest(client, code)
+			if tt.request != nil {
+				tt.request(&req)
+			}
+			token, err := s.Token(ctx, req)
+			wantOAuthError(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+
+			if token.AccessToken == "" || token.RefreshToken == "" || token.Scope != "profile" {
+				t.Errorf("Token = %+v, want access and refresh tokens for profile", token)
+			}
+			claims := tokenClaims(t, s, token.AccessToken)
+			if claims["client_id"] != client.ClientID || claims["scope"] != "profile" || claims["user_id"] != userID {
+				t.Errorf("access token claims = %v, want client %s, scope profile and user %s", claims, client.ClientID, userID)
+			}
+			if _, _, _, err := s.VerifyToken(ctx, token.AccessToken); !errors.Is(err, ErrClientToken) {
+				t.Errorf("VerifyToken of a client token error = %v, want %v", err, ErrClientToken)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCodeReuse(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+	userID := registerUser(t, s).ID.String()
+	client := oauthClient(t, s, false)
+	code := authorize(t, s, userID, client.ClientID)
+
+	token, err := s.Token(ctx, codeRequest(client, code))
+	if err != nil {
+		t.Fatalf("first Token: %v", err)
+	}
+
+	_, err = s.Token(ctx, codeRequest(client, code))
+	wantOAuthError(t, err, OAuthInvalidGrant)
+
+	// The refresh token issued for the code was revoked when it was presented again.
+	_, err = s.Token(ctx, TokenRequest{
+		GrantType:    model.GrantTypeRefreshToken,
+		ClientID:     client.ClientID,
+		RefreshToken: token.RefreshToken,
+	})
+	wantOAuthError(t, err, OAuthInvalidGrant)
+}
+
+func TestAuthorizationCodeMismatchKeepsCode(t *testing.T) {
+	tests := []struct {
+		name string
+		// otherClient presents the code as another client.
+		otherClient bool
+		redirectURI string
+	}{
+		{name: "redirect URI mismatch", redirectURI: "https://app.example.com/other"},
+		{name: "other client", otherClient: true, redirectURI: testRedirectURI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			client := oauthClient(t, s, false)
+			code := authorize(t, s, userID, client.ClientID)
+
+			presenter := client
+			if tt.otherClient {
+				presenter = oauthClient(t, s, false)
+			}
+			req := codeRequest(presenter, code)
+			req.RedirectURI = tt.redirectURI
+			_, err := s.Token(ctx, req)
+			wantOAuthError(t, err, OAuthInvalidGrant)
+
+			// The code can still be exchanged by the client it was issued to.
+			if _, err := s.Token(ctx, codeRequest(client, code)); err != nil {
+				t.Errorf("Token after a mismatched request: %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenRefreshGrant(t *testing.T) {
+	tests := []struct {
+		name string
+		// otherClient presents the refresh token as another client.
+		otherClient bool
+		scope       string
+		wantScope   string
+		wantCode    string
+	}{
+		{name: "same scope", wantScope: "profile email"},
+		{name: "granted scope", scope: "email profile", wantScope: "email profile"},
+		{name: "narrower scope", scope: "email", wantScope: "email"},
+		{name: "wider scope", scope: "profile admin", wantCode: OAuthInvalidScope},
+		{name: "other client", otherClient: true, wantCode: OAuthInvalidGrant},
+		{name: "other client with a wider scope", otherClient: true, scope: "admin", wantCode: OAuthInvalidGrant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			client := oauthClient(t, s, false)
+			authorizeReq := authorizeRequest(userID, client.ClientID)
+			authorizeReq.Scope = "profile email"
+			authorized, err := s.Authorize(ctx, authorizeReq)
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+			token, err := s.Token(ctx, codeRequest(client, authorized.Code))
+			if err != nil {
+				t.Fatalf("Token: %v", err)
+			}
+
+			presenter := client
+			if tt.otherClient {
+				presenter = oauthClient(t, s, false)
+			}
+			refreshed, err := s.Token(ctx, TokenRequest{
+				GrantType:    model.GrantTypeRefreshToken,
+				ClientID:     presenter.ClientID,
+				RefreshToken: token.RefreshToken,
+				Scope:        tt.scope,
+			})
+			wantOAuthError(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if refreshed.Scope != tt.wantScope || refreshed.RefreshToken == token.RefreshToken {
+				t.Errorf("Token = %+v, want a rotated refresh token for %q", refreshed, tt.wantScope)
+			}
+			if claims := tokenClaims(t, s, refreshed.AccessToken); claims["scope"] != tt.wantScope {
+				t.Errorf("access token scope = %v, want %q", claims["scope"], tt.wantScope)
+			}
+
+			// The rotated refresh token keeps the granted scope.
+			again, err := s.Token(ctx, TokenRequest{
+				GrantType:    model.GrantTypeRefreshToken,
+				ClientID:     client.ClientID,
+				RefreshToken: refreshed.RefreshToken,
+			})
+			if err != nil {
+				t.Fatalf("second refresh: %v", err)
+			}
+			if again.Scope != "profile email" {
+				t.Errorf("second refresh scope = %q, want %q", again.Scope, "profile email")
+			}
+		})
+	}
+}
+
+func TestTokenClientCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   func(r *TokenRequest)
+		wantScope string
+		wantCode  string
+	}{
+		{name: "every allowed scope", wantScope: "reports:read reports:write"},
+		{name: "narrower scope", request: func(r *TokenRequest) { r.Scope = "reports:read" }, wantScope: "reports:read"},
+		{name: "scope not allowed", request: func(r *TokenRequest) { r.Scope = "admin" }, wantCode: OAuthInvalidScope},
+		{name: "wrong client secret", request: func(r *TokenRequest) { r.ClientSecret = "wrong" }, wantCode: OAuthInvalidClient},
+		{name: "missing client secret", request: func(r *TokenRequest) { r.ClientSecret = "" }, wantCode: OAuthInvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			client, err := s.CreateOAuthClient(ctx, OAuthClientSpec{
+				Name:         "Worker",
+				GrantTypes:   []string{model.GrantTypeClientCredentials},
+				Scope:        "reports:read reports:write",
+				Confidential: true,
+			})
+			if err != nil {
+				t.Fatalf("CreateOAuthClient: %v", err)
+			}
+
+			req := TokenRequest{GrantType: model.GrantTypeClientCredentials, ClientID: client.ClientID, ClientSecret: client.ClientSecret}
+			if tt.request != nil {
+				tt.request(&req)
+			}
+			token, err := s.Token(ctx, req)
+			wantOAuthError(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+
+			if token.RefreshToken != "" || token.Scope != tt.wantScope {
+				t.Errorf("Token = %+v, want scope %q and no refresh token", token, tt.wantScope)
+			}
+			claims := tokenClaims(t, s, token.AccessToken)
+			if claims["sub"] != client.ClientID || claims["client_id"] != client.ClientID || claims["user_id"] != nil {
+				t.Errorf("access token claims = %v, want sub and client_id %s and no user", claims, client.ClientID)
+			}
+			if _, _, _, err := s.VerifyToken(ctx, token.AccessToken); !errors.Is(err, ErrClientToken) {
+				t.Errorf("VerifyToken of a client credentials token error = %v, want %v", err, ErrClientToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OpenID Connect scopes.
+const (
+	ScopeOpenID  = "openid"
+	ScopeEmail   = "email"
+	ScopeProfile = "profile"
+)
+
+// OIDCConsentRequired is the OpenID Connect error returned when prompt=none
+// is requested but the user has to consent first.
+const OIDCConsentRequired = "consent_required"
+
+var (
+	ErrOIDCNotConfigured = errors.New("OpenID Connect issuer is not configured")
+	ErrInsufficientScope = errors.New("token was not issued for the openid scope")
+)
+
+// OpenIDConfiguration holds the provider metadata that the auth service knows
+// about. The gateway adds the endpoints it serves.
+type OpenIDConfiguration struct {
+	Issuer            string
+	SigningAlgorithms []string
+}
+
+// UserInfo holds the claims about a user that an access token may read. Email
+// claims are only set for tokens with the email scope.
+type UserInfo struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Scope         string
+}
+
+// OpenIDConfiguration returns the provider metadata.
+func (s *service) OpenIDConfiguration() (*OpenIDConfiguration, error) {
+	if s.oidcIssuer == "" {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	return &OpenIDConfiguration{
+		Issuer:            s.oidcIssuer,
+		SigningAlgorithms: []string{s.keys.SigningAlgorithm()},
+	}, nil
+}
+
+// UserInfo returns the claims about the user of an access token that was
+// issued for the openid scope.
+func (s *service) UserInfo(ctx context.Context, accessToken string) (*UserInfo, error) {
+	claims, err := s.parseToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRevocation(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	userID := stringClaim(claims, "user_id")
+	if userID == "" {
+		// Client credentials tokens have no user to describe.
+		return nil, ErrInvalidToken
+	}
+
+	scope := stringClaim(claims, "scope")
+	if !slices.Contains(strings.Fields(scope), ScopeOpenID) {
+		return nil, ErrInsufficientScope
+	}
+
+	user, err := s.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	info := &UserInfo{
+		Subject: user.ID.String(),
+		Scope:   scope,
+	}
+	if slices.Contains(strings.Fields(scope), ScopeEmail) {
+		info.Email = user.Email
+		info.EmailVerified = user.EmailVerified
+	}
+
+	return info, nil
+}
+
+// idToken generates an ID token for a user authorized by an OAuth client.
+// Email claims require the email scope and the name claim the profile scope.
+func (s *service) idToken(ctx context.Context, user *model.Credential, clientID, scope, nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.oidcIssuer,
+		"sub": user.ID.String(),
+		"aud": clientID,
+		"iat": now.Unix(),
+		"exp": now.Add(s.tokenExpiry).Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	scopes := strings.Fields(scope)
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		if name := s.fullName(ctx, user); name != "" {
+			claims["name"] = name
+		}
+	}
+
+	idToken, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign ID token: %w", err)
+	}
+
+	return idToken, nil
+}
+
+// fullName returns the full name of a user from the user service. A failed
+// lookup leaves the name out rather than failing the token request.
+func (s *service) fullName(ctx context.Context, user *model.Credential) string {
+	res, err := s.userClient.GetUser(ctx, &userPB.GetUserRequest{UserId: user.ID.String()})
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			log.Printf("failed to get profile of %s: %v", user.ID, err)
+		}
+		return ""
+	}
+	return res.GetUser().GetFullName()
+}
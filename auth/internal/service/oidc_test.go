@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+)
+
+// oidcClient registers a public client that may request every OpenID Connect scope.
+func oidcClient(t *testing.T, s *service) *OAuthClientRegistration {
+	t.Helper()
+
+	client, err := s.CreateOAuthClient(context.Background(), OAuthClientSpec{
+		Name:         "Relying Party",
+		RedirectURIs: []string{testRedirectURI},
+		Scope:        "openid email profile",
+	})
+	if err != nil {
+		t.Fatalf("CreateOAuthClient: %v", err)
+	}
+	return client
+}
+
+// oidcToken runs the authorization code flow of the user for scope.
+func oidcToken(t *testing.T, s *service, userID, clientID, scope, nonce string) *OAuthToken {
+	t.Helper()
+
+	ctx := context.Background()
+	req := authorizeRequest(userID, clientID)
+	req.Scope = scope
+	req.Nonce = nonce
+	result, err := s.Authorize(ctx, req)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	token, err := s.Token(ctx, TokenRequest{
+		GrantType:    model.GrantTypeAuthorizationCode,
+		ClientID:     clientID,
+		Code:         result.Code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	return token
+}
+
+func TestIDToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope string
+		nonce string
+		// want are the claims of the ID token besides iss, sub and aud; nil expects no ID token.
+		want map[string]interface{}
+	}{
+		{name: "openid", scope: "openid", want: map[string]interface{}{}},
+		{name: "with a nonce", scope: "openid", nonce: "n-0S6_WzA2Mj", want: map[string]interface{}{"nonce": "n-0S6_WzA2Mj"}},
+		{name: "email scope", scope: "openid email", want: map[string]interface{}{"email": testEmail, "email_verified": false}},
+		{name: "profile scope", scope: "openid profile", want: map[string]interface{}{"name": "Alice"}},
+		{name: "without openid", scope: "email profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			client := oidcClient(t, s)
+
+			token := oidcToken(t, s, userID, client.ClientID, tt.scope, tt.nonce)
+			if tt.want == nil {
+				if token.IDToken != "" {
+					t.Errorf("got an ID token for scope %q", tt.scope)
+				}
+				return
+			}
+			if token.IDToken == "" {
+				t.Fatalf("got no ID token for scope %q", tt.scope)
+			}
+
+			claims := tokenClaims(t, s, token.IDToken)
+			if claims["iss"] != "https://auth.example.com" || claims["sub"] != userID || claims["aud"] != client.ClientID {
+				t.Errorf("ID token iss, sub, aud = %v, %v, %v, want the issuer, %s and %s", claims["iss"], claims["sub"], claims["aud"], userID, client.ClientID)
+			}
+			for _, claim := range []string{"nonce", "email", "email_verified", "name"} {
+				if got, want := claims[claim], tt.want[claim]; got != want {
+					t.Errorf("ID token claim %s = %v, want %v", claim, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the access token to present.
+		token     func(t *testing.T, s *service, userID, clientID string) string
+		wantEmail string
+		wantErr   error
+	}{
+		{
+			name: "email scope",
+			token: func(t *testing.T, s *service, userID, clientID string) string {
+				return oidcToken(t, s, userID, clientID, "openid email", "").AccessToken
+			},
+			wantEmail: testEmail,
+		},
+		{
+			name: "openid scope only",
+			token: func(t *testing.T, s *service, userID, clientID string) string {
+				return oidcToken(t, s, userID, clientID, "openid", "").AccessToken
+			},
+		},
+		{
+			name: "without openid",
+			token: func(t *testing.T, s *service, userID, clientID string) string {
+				return oidcToken(t, s, userID, clientID, "email", "").AccessToken
+			},
+			wantErr: ErrInsufficientScope,
+		},
+		{
+			name:    "first-party token",
+			token:   func(t *testing.T, s *service, _, _ string) string { return login(t, s).AccessToken },
+			wantErr: ErrInsufficientScope,
+		},
+		{
+			name: "revoked token",
+			token: func(t *testing.T, s *service, userID, clientID string) string {
+				token := oidcToken(t, s, userID, clientID, "openid email", "").AccessToken
+				if err := s.RevokeAllSessions(context.Background(), userID); err != nil {
+					t.Fatalf("RevokeAllSessions: %v", err)
+				}
+				return token
+			},
+			wantErr: ErrTokenRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			client := oidcClient(t, s)
+
+			info, err := s.UserInfo(context.Background(), tt.token(t, s, userID, client.ClientID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UserInfo error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (info.Subject != userID || info.Email != tt.wantEmail) {
+				t.Errorf("UserInfo = %+v, want subject %s and email %q", info, userID, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+
+	config, err := s.OpenIDConfiguration()
+	if err != nil {
+		t.Fatalf("OpenIDConfiguration: %v", err)
+	}
+	if config.Issuer != "https://auth.example.com" || len(config.SigningAlgorithms) != 1 || config.SigningAlgorithms[0] != "EdDSA" {
+		t.Errorf("OpenIDConfiguration = %+v, want the issuer and EdDSA", config)
+	}
+
+	// Without an issuer OpenID Connect is disabled, including the openid scope.
+	s.oidcIssuer = ""
+	if _, err := s.OpenIDConfiguration(); !errors.Is(err, ErrOIDCNotConfigured) {
+		t.Errorf("OpenIDConfiguration without an issuer error = %v, want %v", err, ErrOIDCNotConfigured)
+	}
+	req := authorizeRequest(registerUser(t, s).ID.String(), oidcClient(t, s).ClientID)
+	req.Scope = "openid"
+	_, err = s.Authorize(ctx, req)
+	wantOAuthError(t, err, OAuthInvalidScope)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/password"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// PolicyError lists the rules of the password policy that a password breaks.
+type PolicyError struct {
+	Violations []password.Violation
+}
+
+func (e *PolicyError) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		descriptions = append(descriptions, v.Field+" "+v.Description)
+	}
+	return ErrWeakPassword.Error() + ": " + strings.Join(descriptions, "; ")
+}
+
+// Is makes errors.Is(err, ErrWeakPassword) match a PolicyError.
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// ChangePassword replaces the password of a user after checking the current
+// one. When revokeOtherSessions is set, every token issued so far is revoked
+// and a new token pair is returned for a new session of the client that made
+// the change; otherwise the returned pair is nil.
+func (s *service) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string, revokeOtherSessions bool, client ClientInfo) (*TokenPair, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	credential, err := s.repository.FindByID(ctx, uid.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if credential == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	if err := s.checkPassword(credential, oldPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.validatePassword(newPassword, credential.Email); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	cutoff := time.Now()
+	if err := s.repository.Transaction(ctx, func(tx repository.Repository) error {
+		if err := tx.UpdatePassword(ctx, uid, hashedPassword); err != nil {
+			return err
+		}
+		if revokeOtherSessions {
+			return revokeAllSessions(ctx, tx, uid, cutoff)
+		}
+		return nil
+	}); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to change password: %w", err)
+	}
+
+	if !revokeOtherSessions {
+		return nil, nil
+	}
+
+	// The iat claim has millisecond precision, so issue the new tokens in the
+	// first millisecond after the cutoff for them to outlive it.
+	return s.startSession(ctx, credential, client, cutoff.Truncate(time.Millisecond).Add(time.Millisecond))
+}
+
+// checkPassword returns ErrInvalidCredentials unless password matches the hash of a credential.
+func (s *service) checkPassword(credential *model.Credential, password string) error {
+	ok, err := s.hasher.Verify(password, credential.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// rehashPassword replaces the hash of a credential whose algorithm or
+// parameters are outdated, using the password that was just verified. A
+// password changed in the meantime is left alone. Errors are only logged,
+// since the login itself succeeded.
+func (s *service) rehashPassword(ctx context.Context, credential *model.Credential, password string) {
+	if !s.hasher.NeedsRehash(credential.PasswordHash) {
+		return
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("failed to rehash password of %s: %v", credential.ID, err)
+		return
+	}
+
+	if err := s.repository.ReplacePasswordHash(ctx, credential.ID, credential.PasswordHash, hashedPassword); err != nil {
+		log.Printf("failed to store rehashed password of %s: %v", credential.ID, err)
+	}
+}
+
+// validatePassword applies the password policy to a new password for the
+// account with the given email.
+func (s *service) validatePassword(password, email string) error {
+	if violations := s.policy.Validate(password, email); len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
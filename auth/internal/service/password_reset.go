@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+)
+
+// defaultPasswordResetExpiry is used when PASSWORD_RESET_EXPIRY is not configured.
+const defaultPasswordResetExpiry = time.Hour
+
+// RequestPasswordReset emails a password reset link to the user with the given
+// email in the given locale. It returns nil whether or not the email is
+// registered, so the caller cannot learn which accounts exist.
+func (s *service) RequestPasswordReset(ctx context.Context, email, locale string) error {
+	credential, err := s.repository.FindByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to find user by email: %w", err)
+	}
+	if credential == nil {
+		return nil
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate password reset token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.resetExpiry)
+	if err := s.repository.CreatePasswordResetToken(ctx, &model.PasswordResetToken{
+		UserID:    credential.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to store password reset token: %w", err)
+	}
+
+	// A delivery failure is not reported to the caller, since that would
+	// reveal that the account exists.
+	if err := s.notifier.Notify(ctx, notifier.Message{
+		To:       credential.Email,
+		Locale:   locale,
+		Template: notifier.TemplatePasswordReset,
+		Data: notifier.PasswordResetData{
+			URL:       s.appURL("/reset-password", token),
+			ExpiresAt: expiresAt,
+		},
+	}); err != nil {
+		log.Printf("failed to send password reset to %s: %v", credential.ID, err)
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset consumes a password reset token, sets the new password
+// of its user and revokes every session of that user.
+func (s *service) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+
+	// A password rejected by the policy rolls the transaction back, so the
+	// token can be used again with a better password.
+	return s.repository.Transaction(ctx, func(tx repository.Repository) error {
+		reset, err := tx.ConsumePasswordResetToken(ctx, hashToken(token), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to consume password reset token: %w", err)
+		}
+		if reset == nil {
+			return ErrInvalidResetToken
+		}
+
+		credential, err := tx.FindByID(ctx, reset.UserID.String())
+		if err != nil {
+			return fmt.Errorf("failed to find user by id: %w", err)
+		}
+		if credential == nil {
+			return ErrInvalidResetToken
+		}
+
+		if err := s.validatePassword(newPassword, credential.Email); err != nil {
+			return err
+		}
+
+		hashedPassword, err := s.hasher.Hash(newPassword)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+
+		if err := tx.UpdatePassword(ctx, reset.UserID, hashedPassword); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		return revokeAllSessions(ctx, tx, reset.UserID, time.Now())
+	})
+}
+
+// appURL returns the link to a page of the web app that receives a token.
+func (s *service) appURL(page, token string) string {
+	return strings.TrimSuffix(s.appBaseURL, "/") + page + "?token=" + url.QueryEscape(token)
+}
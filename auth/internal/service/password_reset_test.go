@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+)
+
+const newTestPassword = "another-horse-battery"
+
+// resetTokens returns the tokens of the password reset emails sent by s, oldest first.
+func resetTokens(t *testing.T, s *service) []string {
+	t.Helper()
+
+	n := s.notifier.(*testNotifier)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var tokens []string
+	for _, msg := range n.messages {
+		data, ok := msg.Data.(notifier.PasswordResetData)
+		if !ok {
+			continue
+		}
+		link, err := url.Parse(data.URL)
+		if err != nil {
+			t.Fatalf("invalid password reset URL %q: %v", data.URL, err)
+		}
+		tokens = append(tokens, link.Query().Get("token"))
+	}
+	return tokens
+}
+
+func TestRequestPasswordResetUnknownEmail(t *testing.T) {
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+
+	if err := s.RequestPasswordReset(context.Background(), "nobody@example.com", ""); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	if tokens := resetTokens(t, s); len(tokens) != 0 {
+		t.Errorf("sent %d password reset emails for an unknown email", len(tokens))
+	}
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	tests := []struct {
+		name        string
+		resetExpiry time.Duration
+		// requests is how many resets are requested before token picks the one to confirm.
+		requests    int
+		token       func(tokens []string) string
+		newPassword string
+		wantErr     error
+	}{
+		{
+			name:     "latest token",
+			requests: 1, token: func(tokens []string) string { return tokens[0] },
+			newPassword: newTestPassword,
+		},
+		{
+			name:     "superseded token",
+			requests: 2, token: func(tokens []string) string { return tokens[0] },
+			newPassword: newTestPassword, wantErr: ErrInvalidResetToken,
+		},
+		{
+			name:     "expired token",
+			requests: 1, token: func(tokens []string) string { return tokens[0] }, resetExpiry: -time.Minute,
+			newPassword: newTestPassword, wantErr: ErrInvalidResetToken,
+		},
+		{
+			name:     "unknown token",
+			requests: 1, token: func([]string) string { return "not-a-reset-token" },
+			newPassword: newTestPassword, wantErr: ErrInvalidResetToken,
+		},
+		{
+			name:     "empty token",
+			requests: 1, token: func([]string) string { return "" },
+			newPassword: newTestPassword, wantErr: ErrInvalidResetToken,
+		},
+		{
+			name:     "weak password",
+			requests: 1, token: func(tokens []string) string { return tokens[0] },
+			newPassword: "short", wantErr: ErrWeakPassword,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			session := login(t, s)
+			apiKey := createAPIKey(t, s, userID)
+			if tt.resetExpiry != 0 {
+				s.resetExpiry = tt.resetExpiry
+			}
+
+			for i := 0; i < tt.requests; i++ {
+				if err := s.RequestPasswordReset(ctx, testEmail, ""); err != nil {
+					t.Fatalf("RequestPasswordReset: %v", err)
+				}
+			}
+			tokens := resetTokens(t, s)
+			if len(tokens) != tt.requests {
+				t.Fatalf("sent %d password reset emails, want %d", len(tokens), tt.requests)
+			}
+			token := tt.token(tokens)
+
+			err := s.ConfirmPasswordReset(ctx, token, tt.newPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConfirmPasswordReset error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				// Nothing changed: the old password and session still work.
+				login(t, s)
+				if _, _, valid, err := s.VerifyToken(ctx, session.AccessToken); !valid || err != nil {
+					t.Errorf("VerifyToken after a failed reset = %v, %v", valid, err)
+				}
+				if _, err := s.VerifyAPIKey(ctx, apiKey); err != nil {
+					t.Errorf("VerifyAPIKey after a failed reset: %v", err)
+				}
+				if errors.Is(tt.wantErr, ErrWeakPassword) {
+					// The token was not used up by the rejected password.
+					if err := s.ConfirmPasswordReset(ctx, token, newTestPassword); err != nil {
+						t.Errorf("ConfirmPasswordReset with a better password: %v", err)
+					}
+				}
+				return
+			}
+
+			if _, err := s.Login(ctx, testEmail, testPassword, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Login with the old password error = %v, want %v", err, ErrInvalidCredentials)
+			}
+			if _, err := s.Login(ctx, testEmail, tt.newPassword, ClientInfo{}); err != nil {
+				t.Errorf("Login with the new password: %v", err)
+			}
+			if _, _, _, err := s.VerifyToken(ctx, session.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("VerifyToken of a session from before the reset error = %v, want %v", err, ErrTokenRevoked)
+			}
+			if _, err := s.VerifyAPIKey(ctx, apiKey); !errors.Is(err, ErrInvalidAPIKey) {
+				t.Errorf("VerifyAPIKey of a key from before the reset error = %v, want %v", err, ErrInvalidAPIKey)
+			}
+			if err := s.ConfirmPasswordReset(ctx, token, "yet-another-horse"); !errors.Is(err, ErrInvalidResetToken) {
+				t.Errorf("second ConfirmPasswordReset error = %v, want %v", err, ErrInvalidResetToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/password"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginRehashesPassword(t *testing.T) {
+	tests := []struct {
+		name string
+		// config is the hasher configured after registration with a bcrypt cost of bcrypt.MinCost.
+		config       password.Config
+		password     string
+		wantRehashed bool
+		wantPrefix   string
+	}{
+		{
+			name:     "current hash",
+			config:   password.Config{Algorithm: password.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost},
+			password: testPassword, wantPrefix: "$2a$",
+		},
+		{
+			name:     "higher bcrypt cost",
+			config:   password.Config{Algorithm: password.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1},
+			password: testPassword, wantRehashed: true, wantPrefix: "$2a$05$",
+		},
+		{
+			name:     "migration to argon2id",
+			config:   password.Config{Argon2: password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}},
+			password: testPassword, wantRehashed: true, wantPrefix: "$argon2id$",
+		},
+		{
+			name:     "wrong password",
+			config:   password.Config{Argon2: password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}},
+			password: "wrong-password", wantPrefix: "$2a$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			credential := registerUser(t, s)
+
+			hasher, err := password.NewHasher(tt.config)
+			if err != nil {
+				t.Fatalf("NewHasher: %v", err)
+			}
+			s.hasher = hasher
+
+			if _, err := s.Login(ctx, testEmail, tt.password, ClientInfo{}); (err == nil) != (tt.password == testPassword) {
+				t.Fatalf("Login error = %v", err)
+			}
+
+			stored, err := s.repository.FindByID(ctx, credential.ID.String())
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if rehashed := stored.PasswordHash != credential.PasswordHash; rehashed != tt.wantRehashed {
+				t.Errorf("password rehashed = %v, want %v", rehashed, tt.wantRehashed)
+			}
+			if !strings.HasPrefix(stored.PasswordHash, tt.wantPrefix) {
+				t.Errorf("stored hash %q, want prefix %q", stored.PasswordHash, tt.wantPrefix)
+			}
+			// The rehashed password still logs in.
+			login(t, s)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name string
+		// userID returns the ID of the user changing their password, given the registered one.
+		userID       func(registered string) string
+		oldPassword  string
+		newPassword  string
+		revokeOthers bool
+		wantErr      error
+	}{
+		{name: "keep other sessions", oldPassword: testPassword, newPassword: newTestPassword},
+		{name: "revoke other sessions", oldPassword: testPassword, newPassword: newTestPassword, revokeOthers: true},
+		{name: "wrong old password", oldPassword: "wrong-password", newPassword: newTestPassword, revokeOthers: true, wantErr: ErrInvalidCredentials},
+		{name: "weak new password", oldPassword: testPassword, newPassword: "short", revokeOthers: true, wantErr: ErrWeakPassword},
+		{
+			name:   "unknown user",
+			userID: func(string) string { return uuid.NewString() }, oldPassword: testPassword, newPassword: newTestPassword,
+			wantErr: ErrRecordNotFound,
+		},
+		{
+			name:   "malformed user ID",
+			userID: func(string) string { return "not-a-uuid" }, oldPassword: testPassword, newPassword: newTestPassword,
+			wantErr: ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			registeredID := registerUser(t, s).ID.String()
+			userID := registeredID
+			if tt.userID != nil {
+				userID = tt.userID(userID)
+			}
+			session := login(t, s)
+			apiKey := createAPIKey(t, s, registeredID)
+
+			tokens, err := s.ChangePassword(ctx, userID, tt.oldPassword, tt.newPassword, tt.revokeOthers, ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				// Nothing changed: the old password and session still work.
+				login(t, s)
+				if _, _, valid, err := s.VerifyToken(ctx, session.AccessToken); !valid || err != nil {
+					t.Errorf("VerifyToken after a failed change = %v, %v", valid, err)
+				}
+				if _, err := s.VerifyAPIKey(ctx, apiKey); err != nil {
+					t.Errorf("VerifyAPIKey after a failed change: %v", err)
+				}
+				return
+			}
+
+			if _, err := s.Login(ctx, testEmail, testPassword, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Login with the old password error = %v, want %v", err, ErrInvalidCredentials)
+			}
+			if _, err := s.Login(ctx, testEmail, tt.newPassword, ClientInfo{}); err != nil {
+				t.Errorf("Login with the new password: %v", err)
+			}
+
+			if _, err := s.VerifyAPIKey(ctx, apiKey); (err != nil) != tt.revokeOthers {
+				t.Errorf("VerifyAPIKey error = %v, want it revoked %v", err, tt.revokeOthers)
+			}
+
+			_, _, _, err = s.VerifyToken(ctx, session.AccessToken)
+			if !tt.revokeOthers {
+				if tokens != nil {
+					t.Errorf("ChangePassword = %+v, want no new tokens when other sessions are kept", tokens)
+				}
+				if err != nil {
+					t.Errorf("VerifyToken of the other session: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("VerifyToken of the other session error = %v, want %v", err, ErrTokenRevoked)
+			}
+			if tokens == nil {
+				t.Fatal("ChangePassword returned no tokens for the current session")
+			}
+			if _, _, valid, err := s.VerifyToken(ctx, tokens.AccessToken); !valid || err != nil {
+				t.Errorf("VerifyToken of the new session = %v, %v", valid, err)
+			}
+			if _, err := s.RefreshToken(ctx, tokens.RefreshToken); err != nil {
+				t.Errorf("RefreshToken of the new session: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/google/uuid"
+)
+
+// AssignRole assigns a role to a user. The role is added to the tokens issued
+// from now on; CheckPermission sees it right away.
+func (s *service) AssignRole(ctx context.Context, userID, role string) error {
+	uid, err := s.roleHolder(ctx, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.repository.AssignRole(ctx, uid, role); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	return nil
+}
+
+// RevokeRole removes a role from a user. Tokens that were already issued keep
+// the role in their roles claim until they expire; CheckPermission stops
+// granting its permissions right away.
+func (s *service) RevokeRole(ctx context.Context, userID, role string) error {
+	uid, err := s.roleHolder(ctx, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.repository.RevokeRole(ctx, uid, role); err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+	return nil
+}
+
+// ListUserRoles returns the roles of a user.
+func (s *service) ListUserRoles(ctx context.Context, userID string) ([]string, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	roles, err := s.repository.ListUserRoles(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	return roles, nil
+}
+
+// CheckPermission reports whether any role of a user grants the permission.
+func (s *service) CheckPermission(ctx context.Context, userID, permission string) (bool, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return false, ErrRecordNotFound
+	}
+
+	allowed, err := s.repository.HasPermission(ctx, uid, permission)
+	if err != nil {
+		return false, fmt.Errorf("failed to check permission: %w", err)
+	}
+	return allowed, nil
+}
+
+// BootstrapAdmin assigns the admin role to the user with the given email, so
+// that a fresh deployment has someone who can assign roles. It does nothing
+// if no such user exists yet.
+func (s *service) BootstrapAdmin(ctx context.Context, email string) error {
+	credential, err := s.repository.FindByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to find user by email: %w", err)
+	}
+	if credential == nil {
+		return ErrRecordNotFound
+	}
+
+	if _, err := s.repository.AssignRole(ctx, credential.ID, model.RoleAdmin); err != nil {
+		return fmt.Errorf("failed to assign admin role: %w", err)
+	}
+	return nil
+}
+
+// roleHolder checks that both the user and the role exist and returns the parsed user ID.
+func (s *service) roleHolder(ctx context.Context, userID, role string) (uuid.UUID, error) {
+	credential, err := s.findCredential(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	found, err := s.repository.FindRole(ctx, role)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to find role: %w", err)
+	}
+	if found == nil {
+		return uuid.Nil, ErrRoleNotFound
+	}
+
+	return credential.ID, nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+// claimedRoles returns the roles claim of an access token issued by s.
+func claimedRoles(t *testing.T, s *service, token string) []string {
+	t.Helper()
+
+	roles := []string{}
+	claimed, _ := tokenClaims(t, s, token)["roles"].([]interface{})
+	for _, role := range claimed {
+		roles = append(roles, role.(string))
+	}
+	return roles
+}
+
+func TestRoleHolderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  func(credential *model.Credential) string
+		role    string
+		wantErr error
+	}{
+		{name: "malformed user ID", userID: func(*model.Credential) string { return "not-a-uuid" }, role: model.RoleAdmin, wantErr: ErrRecordNotFound},
+		{name: "unknown user", userID: func(*model.Credential) string { return uuid.NewString() }, role: model.RoleAdmin, wantErr: ErrRecordNotFound},
+		{name: "unknown role", userID: func(c *model.Credential) string { return c.ID.String() }, role: "superuser", wantErr: ErrRoleNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := tt.userID(registerUser(t, s))
+
+			if err := s.AssignRole(ctx, userID, tt.role); !errors.Is(err, tt.wantErr) {
+				t.Errorf("AssignRole error = %v, want %v", err, tt.wantErr)
+			}
+			if err := s.RevokeRole(ctx, userID, tt.role); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RevokeRole error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoles(t *testing.T) {
+	tests := []struct {
+		name           string
+		assign         []string
+		revoke         []string
+		wantRoles      []string
+		wantPermission bool
+	}{
+		{name: "no roles", wantRoles: []string{}},
+		{name: "assigned", assign: []string{model.RoleAdmin}, wantRoles: []string{model.RoleAdmin}, wantPermission: true},
+		{name: "assigned twice", assign: []string{model.RoleAdmin, model.RoleAdmin}, wantRoles: []string{model.RoleAdmin}, wantPermission: true},
+		{name: "revoked", assign: []string{model.RoleAdmin}, revoke: []string{model.RoleAdmin}, wantRoles: []string{}},
+		{name: "revoked without being assigned", revoke: []string{model.RoleAdmin}, wantRoles: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+
+			for _, role := range tt.assign {
+				if err := s.AssignRole(ctx, userID, role); err != nil {
+					t.Fatalf("AssignRole(%s): %v", role, err)
+				}
+			}
+			for _, role := range tt.revoke {
+				if err := s.RevokeRole(ctx, userID, role); err != nil {
+					t.Fatalf("RevokeRole(%s): %v", role, err)
+				}
+			}
+
+			roles, err := s.ListUserRoles(ctx, userID)
+			if err != nil {
+				t.Fatalf("ListUserRoles: %v", err)
+			}
+			if !reflect.DeepEqual(roles, tt.wantRoles) {
+				t.Errorf("ListUserRoles = %q, want %q", roles, tt.wantRoles)
+			}
+			if claimed := claimedRoles(t, s, login(t, s).AccessToken); !reflect.DeepEqual(claimed, tt.wantRoles) {
+				t.Errorf("roles claim = %q, want %q", claimed, tt.wantRoles)
+			}
+
+			allowed, err := s.CheckPermission(ctx, userID, model.PermissionRolesManage)
+			if err != nil {
+				t.Fatalf("CheckPermission: %v", err)
+			}
+			if allowed != tt.wantPermission {
+				t.Errorf("CheckPermission(%s) = %v, want %v", model.PermissionRolesManage, allowed, tt.wantPermission)
+			}
+			if allowed, err := s.CheckPermission(ctx, userID, "unknown:permission"); allowed || err != nil {
+				t.Errorf("CheckPermission of an unknown permission = %v, %v, want false", allowed, err)
+			}
+		})
+	}
+}
+
+func TestRevokeRoleKeepsIssuedTokens(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+	userID := registerUser(t, s).ID.String()
+
+	if err := s.AssignRole(ctx, userID, model.RoleAdmin); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	tokens := login(t, s)
+	if err := s.RevokeRole(ctx, userID, model.RoleAdmin); err != nil {
+		t.Fatalf("RevokeRole: %v", err)
+	}
+
+	// The issued token keeps its claim until it expires, but the permission
+	// check and refreshed tokens drop the role right away.
+	if claimed := claimedRoles(t, s, tokens.AccessToken); !reflect.DeepEqual(claimed, []string{model.RoleAdmin}) {
+		t.Errorf("roles claim of the issued token = %q, want %q", claimed, model.RoleAdmin)
+	}
+	if allowed, err := s.CheckPermission(ctx, userID, model.PermissionRolesManage); allowed || err != nil {
+		t.Errorf("CheckPermission after RevokeRole = %v, %v, want false", allowed, err)
+	}
+	refreshed, err := s.RefreshToken(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if claimed := claimedRoles(t, s, refreshed.AccessToken); len(claimed) != 0 {
+		t.Errorf("roles claim of the refreshed token = %q, want none", claimed)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+
+	if err := s.BootstrapAdmin(ctx, testEmail); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("BootstrapAdmin before registration error = %v, want %v", err, ErrRecordNotFound)
+	}
+
+	userID := registerUser(t, s).ID.String()
+	if err := s.BootstrapAdmin(ctx, testEmail); err != nil {
+		t.Fatalf("BootstrapAdmin: %v", err)
+	}
+	if allowed, err := s.CheckPermission(ctx, userID, model.PermissionRolesManage); !allowed || err != nil {
+		t.Errorf("CheckPermission after BootstrapAdmin = %v, %v, want true", allowed, err)
+	}
+}
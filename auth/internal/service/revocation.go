@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// revocationFeedOverlap is how far ListRevocations reaches back before the
+// requested time, so that revocations committed while the previous request
+// ran are not missed. Verifiers ignore the duplicates.
+const revocationFeedOverlap = 10 * time.Second
+
+// Revocations lists what was revoked since a point in time. AsOf is the time
+// to request the next revocations from.
+type Revocations struct {
+	Tokens   []model.RevokedToken
+	Users    []model.UserRevocation
+	Sessions []uuid.UUID
+	AsOf     time.Time
+}
+
+// Logout revokes the given access token with its session and, when provided,
+// the refresh token family issued with it. Client credentials tokens have
+// neither, so only the token itself is revoked.
+func (s *service) Logout(ctx context.Context, token, refreshToken string) error {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		if errors.Is(err, ErrTokenExpired) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	jti := stringClaim(claims, "jti")
+	if jti == "" {
+		return ErrInvalidToken
+	}
+	exp, _ := claims["exp"].(float64)
+	revoked := &model.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}
+
+	if isClientToken(claims) {
+		if err := s.repository.RevokeToken(ctx, revoked); err != nil {
+			return fmt.Errorf("failed to revoke token: %w", err)
+		}
+		return nil
+	}
+
+	userID, err := uuid.Parse(stringClaim(claims, "user_id"))
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	revoked.UserID = &userID
+	if err := s.repository.RevokeToken(ctx, revoked); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	if sessionID, err := uuid.Parse(stringClaim(claims, "sid")); err == nil {
+		if err := s.revokeSession(ctx, userID, sessionID); err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.repository.FindRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return fmt.Errorf("failed to find refresh token: %w", err)
+	}
+	if stored == nil || stored.UserID != userID {
+		return nil
+	}
+
+	if err := s.repository.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+// ListRevocations returns the revocations since the given time, for verifiers
+// that check access tokens without asking the auth service. Revocations older
+// than the lifetime of access tokens no longer affect any token and are left
+// out, so a zero since lists everything a new verifier needs to know.
+func (s *service) ListRevocations(ctx context.Context, since time.Time) (*Revocations, error) {
+	now := s.now()
+	since = since.Add(-revocationFeedOverlap)
+	if floor := now.Add(-s.tokenExpiry); since.Before(floor) {
+		since = floor
+	}
+
+	tokens, err := s.repository.ListRevokedTokens(ctx, since, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revoked tokens: %w", err)
+	}
+	users, err := s.repository.ListUserRevocations(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user revocations: %w", err)
+	}
+	sessions, err := s.repository.ListRevokedSessions(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revoked sessions: %w", err)
+	}
+
+	return &Revocations{Tokens: tokens, Users: users, Sessions: sessions, AsOf: now}, nil
+}
+
+// RevokeAllSessions revokes every access and refresh token, session and API key
+// issued to a user so far.
+func (s *service) RevokeAllSessions(ctx context.Context, userID string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrRecordNotFound
+	}
+
+	return s.repository.Transaction(ctx, func(tx repository.Repository) error {
+		return revokeAllSessions(ctx, tx, uid, time.Now())
+	})
+}
+
+// PruneExpired removes revocation entries, refresh tokens, password reset
+// tokens, login throttles, MFA challenges, authorization codes and sessions
+// that have expired.
+func (s *service) PruneExpired(ctx context.Context) error {
+	now := time.Now()
+
+	if err := s.repository.DeleteExpiredRevocations(ctx, now, s.tokenExpiry); err != nil {
+		return err
+	}
+
+	if err := s.repository.DeleteExpiredPasswordResetTokens(ctx, now); err != nil {
+		return err
+	}
+
+	if err := s.repository.DeleteStaleLoginThrottles(ctx, now, now.Add(-s.throttle.window)); err != nil {
+		return err
+	}
+
+	if err := s.repository.DeleteExpiredMFAChallenges(ctx, now); err != nil {
+		return err
+	}
+
+	if err := s.repository.DeleteExpiredAuthorizationCodes(ctx, now); err != nil {
+		return err
+	}
+
+	// Revoked sessions are kept while their access tokens may be valid, so
+	// that ListRevocations reports them.
+	return s.repository.DeleteExpiredSessions(ctx, now, now.Add(-s.tokenExpiry))
+}
+
+// checkRevocation rejects tokens that were revoked individually, with their
+// session, or by a per-user cutoff.
+func (s *service) checkRevocation(ctx context.Context, claims jwt.MapClaims) error {
+	jti := stringClaim(claims, "jti")
+	if jti == "" {
+		return errors.New("missing or invalid jti in token")
+	}
+
+	revoked, err := s.repository.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	if err := s.checkSession(ctx, claims); err != nil {
+		return err
+	}
+
+	// Client credentials tokens have no user and so no per-user cutoff.
+	if isClientToken(claims) {
+		return nil
+	}
+
+	userID, err := uuid.Parse(stringClaim(claims, "user_id"))
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	revocation, err := s.repository.FindUserRevocation(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check user revocation: %w", err)
+	}
+	if revocation == nil {
+		return nil
+	}
+
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return errors.New("missing or invalid iat in token")
+	}
+	if !time.UnixMilli(int64(math.Round(iat * 1000))).After(revocation.RevokedBefore) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// revokeAllSessions revokes every token, session and API key of a user issued
+// up to the cutoff using the given repository, which may be bound to a
+// transaction.
+func revokeAllSessions(ctx context.Context, r repository.Repository, userID uuid.UUID, cutoff time.Time) error {
+	if err := r.RevokeUserTokens(ctx, userID, cutoff); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	if err := r.RevokeRefreshTokensByUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := r.RevokeSessionsByUser(ctx, userID, cutoff); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := r.RevokeAPIKeysByUser(ctx, userID, cutoff); err != nil {
+		return fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+
+	return nil
+}
+
+// issuedAt returns the iat claim with millisecond precision, so that tokens
+// issued right after a revocation are not caught by its cutoff.
+func issuedAt(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// isClientToken reports whether a token was issued to an OAuth client acting
+// on its own behalf, rather than to a user.
+func isClientToken(claims jwt.MapClaims) bool {
+	_, hasUser := claims["user_id"]
+	return !hasUser && stringClaim(claims, "client_id") != ""
+}
+
+// stringClaim returns a string claim, or an empty string if it is missing.
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
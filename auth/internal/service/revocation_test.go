@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// tokenClaims returns the claims of an access token issued by s.
+func tokenClaims(t *testing.T, s *service, token string) jwt.MapClaims {
+	t.Helper()
+
+	claims, err := s.parseToken(token)
+	if err != nil {
+		t.Fatalf("parseToken: %v", err)
+	}
+	return claims
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name string
+		// withRefreshToken passes the refresh token of the session to Logout.
+		withRefreshToken bool
+	}{
+		{name: "access token only"},
+		{name: "with refresh token", withRefreshToken: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			registerUser(t, s)
+			tokens := login(t, s)
+			other := login(t, s)
+
+			var refreshToken string
+			if tt.withRefreshToken {
+				refreshToken = tokens.RefreshToken
+			}
+			if err := s.Logout(ctx, tokens.AccessToken, refreshToken); err != nil {
+				t.Fatalf("Logout: %v", err)
+			}
+
+			if _, _, _, err := s.VerifyToken(ctx, tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("VerifyToken after logout error = %v, want %v", err, ErrTokenRevoked)
+			}
+			if _, err := s.RefreshToken(ctx, tokens.RefreshToken); !errors.Is(err, ErrTokenReused) {
+				t.Errorf("RefreshToken after logout error = %v, want %v", err, ErrTokenReused)
+			}
+
+			// Other sessions of the user are not affected.
+			if _, _, valid, err := s.VerifyToken(ctx, other.AccessToken); !valid || err != nil {
+				t.Errorf("VerifyToken of another session = %v, %v", valid, err)
+			}
+			if _, err := s.RefreshToken(ctx, other.RefreshToken); err != nil {
+				t.Errorf("RefreshToken of another session: %v", err)
+			}
+
+			// Logging out twice is harmless.
+			if err := s.Logout(ctx, tokens.AccessToken, refreshToken); err != nil {
+				t.Errorf("second Logout: %v", err)
+			}
+		})
+	}
+}
+
+func TestLogoutInvalidToken(t *testing.T) {
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+
+	for _, token := range []string{"", "not-a-jwt"} {
+		if err := s.Logout(context.Background(), token, ""); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Logout(%q) error = %v, want %v", token, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+	user := registerUser(t, s)
+	sessions := []*TokenPair{login(t, s), login(t, s)}
+	apiKey := createAPIKey(t, s, user.ID.String())
+
+	if err := s.RevokeAllSessions(ctx, user.ID.String()); err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+
+	if _, err := s.VerifyAPIKey(ctx, apiKey); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("VerifyAPIKey error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+
+	for i, tokens := range sessions {
+		if _, _, _, err := s.VerifyToken(ctx, tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("session %d: VerifyToken error = %v, want %v", i, err, ErrTokenRevoked)
+		}
+		if _, err := s.RefreshToken(ctx, tokens.RefreshToken); !errors.Is(err, ErrTokenReused) {
+			t.Errorf("session %d: RefreshToken error = %v, want %v", i, err, ErrTokenReused)
+		}
+	}
+
+	// The cutoff has millisecond precision; tokens issued after it are valid.
+	time.Sleep(2 * time.Millisecond)
+	tokens := login(t, s)
+	if _, _, valid, err := s.VerifyToken(ctx, tokens.AccessToken); !valid || err != nil {
+		t.Errorf("VerifyToken of a new login = %v, %v", valid, err)
+	}
+
+	if err := s.RevokeAllSessions(ctx, "not-a-uuid"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("RevokeAllSessions with an invalid ID error = %v, want %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestListRevocations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+	user := registerUser(t, s)
+
+	loggedOut := login(t, s)
+	claims := tokenClaims(t, s, loggedOut.AccessToken)
+	if err := s.Logout(ctx, loggedOut.AccessToken, ""); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	revocations, err := s.ListRevocations(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("ListRevocations: %v", err)
+	}
+	if !slices.ContainsFunc(revocations.Tokens, func(r model.RevokedToken) bool { return r.JTI == claims["jti"] }) {
+		t.Errorf("revoked tokens %+v do not contain %s", revocations.Tokens, claims["jti"])
+	}
+	if !slices.Contains(revocations.Sessions, uuid.MustParse(stringClaim(claims, "sid"))) {
+		t.Errorf("revoked sessions %v do not contain %s", revocations.Sessions, claims["sid"])
+	}
+	if len(revocations.Users) != 0 {
+		t.Errorf("user revocations = %+v, want none", revocations.Users)
+	}
+
+	if err := s.RevokeAllSessions(ctx, user.ID.String()); err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+
+	next, err := s.ListRevocations(ctx, revocations.AsOf)
+	if err != nil {
+		t.Fatalf("ListRevocations: %v", err)
+	}
+	if len(next.Users) != 1 || next.Users[0].UserID != user.ID {
+		t.Errorf("user revocations = %+v, want one for %s", next.Users, user.ID)
+	}
+	if next.AsOf.Before(revocations.AsOf) {
+		t.Errorf("AsOf went back from %v to %v", revocations.AsOf, next.AsOf)
+	}
+}
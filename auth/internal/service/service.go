@@ -2,15 +2,28 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/PakornBank/go-grpc-example/auth/internal/config"
+	"github.com/PakornBank/go-grpc-example/auth/internal/keys"
 	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/outbox"
+	"github.com/PakornBank/go-grpc-example/auth/internal/password"
 	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -18,36 +31,170 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrTokenExpired       = errors.New("token expired")
-	ErrUnexpectedSigning  = errors.New("unexpected signing method")
-	ErrRecordNotFound     = errors.New("record not found")
+	ErrRecordNotFound     = repository.ErrRecordNotFound
+	ErrTokenReused        = errors.New("refresh token reused")
+	ErrTokenRevoked       = errors.New("token revoked")
+	ErrAccountPending     = errors.New("account is being created")
+	ErrInvalidResetToken  = errors.New("invalid or expired password reset token")
+	ErrWeakPassword       = errors.New("password does not meet the policy")
+	ErrTooManyAttempts    = errors.New("too many failed login attempts")
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrClientToken        = errors.New("token was issued to an OAuth client")
+
+	ErrMFAAlreadyEnabled = errors.New("MFA is already enabled")
+	ErrMFANotEnrolled    = errors.New("MFA is not enrolled")
+	ErrInvalidMFACode    = errors.New("invalid MFA code")
+
+	ErrEmailNotVerified         = errors.New("email not verified")
+	ErrInvalidVerificationToken = errors.New("invalid email verification token")
+	ErrVerificationRateLimited  = errors.New("verification email sent too recently")
 )
 
+// defaultRefreshTokenExpiry is used when REFRESH_TOKEN_EXPIRY is not configured.
+const defaultRefreshTokenExpiry = 7 * 24 * time.Hour
+
+// TokenPair holds an access token together with the refresh token that renews it.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// LoginResult is the outcome of a password login: either a token pair, or an
+// MFA token to pass to VerifyMFA together with a second factor.
+type LoginResult struct {
+	Tokens   *TokenPair
+	MFAToken string
+}
+
 // Service defines the methods that a service must implement.
 type Service interface {
-	Register(ctx context.Context, email, password string) (string, error)
-	Login(ctx context.Context, email, password string) (string, error)
-	VerifyToken(token string) (string, string, bool, error)
+	Register(ctx context.Context, email, password, fullName, locale string) (string, error)
+	Login(ctx context.Context, email, password string, client ClientInfo) (*LoginResult, error)
+	VerifyToken(ctx context.Context, token string) (string, string, bool, error)
 	DeleteUser(ctx context.Context, id string) error
+	RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, token, refreshToken string) error
+	RevokeAllSessions(ctx context.Context, userID string) error
+	PruneExpired(ctx context.Context) error
+	PublicKeys() []keys.JWK
+	ListRevocations(ctx context.Context, since time.Time) (*Revocations, error)
+	DeleteAccount(ctx context.Context, userID, password string) error
+	RequestPasswordReset(ctx context.Context, email, locale string) error
+	ConfirmPasswordReset(ctx context.Context, token, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, email, locale string) error
+	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string, revokeOtherSessions bool, client ClientInfo) (*TokenPair, error)
+	UnlockAccount(ctx context.Context, email string) error
+	EnrollMFA(ctx context.Context, userID string) (*MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, userID, code string) ([]string, error)
+	DisableMFA(ctx context.Context, userID, password string) error
+	VerifyMFA(ctx context.Context, mfaToken, code string, client ClientInfo) (*TokenPair, error)
+	AssignRole(ctx context.Context, userID, role string) error
+	RevokeRole(ctx context.Context, userID, role string) error
+	ListUserRoles(ctx context.Context, userID string) ([]string, error)
+	CheckPermission(ctx context.Context, userID, permission string) (bool, error)
+	BootstrapAdmin(ctx context.Context, email string) error
+	CreateOAuthClient(ctx context.Context, spec OAuthClientSpec) (*OAuthClientRegistration, error)
+	Authorize(ctx context.Context, req AuthorizeRequest) (*AuthorizeResult, error)
+	Token(ctx context.Context, req TokenRequest) (*OAuthToken, error)
+	OpenIDConfiguration() (*OpenIDConfiguration, error)
+	UserInfo(ctx context.Context, accessToken string) (*UserInfo, error)
+	IntrospectToken(ctx context.Context, req IntrospectionRequest) (*Introspection, error)
+	CreateAPIKey(ctx context.Context, userID string, spec APIKeySpec) (*CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID string) error
+	VerifyAPIKey(ctx context.Context, key string) (*APIKeyIdentity, error)
+	ListSessions(ctx context.Context, userID string) ([]model.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	TouchSessions(ctx context.Context, sessionIDs []string) error
+	GetLoginHistory(ctx context.Context, userID string, pageSize int, pageToken string) ([]model.LoginEvent, string, error)
 }
 
 // service is a struct that provides methods to interact with the authentication service.
 type service struct {
-	repository  repository.Repository
-	jwtSecret   []byte
-	tokenExpiry time.Duration
+	repository    repository.Repository
+	keys          *keys.Manager
+	outbox        *outbox.Dispatcher
+	notifier      notifier.Notifier
+	hasher        password.Hasher
+	policy        *password.Policy
+	tokenExpiry   time.Duration
+	refreshExpiry time.Duration
+	resetExpiry   time.Duration
+	appBaseURL    string
+
+	verificationSecret       []byte
+	verificationExpiry       time.Duration
+	resendCooldown           time.Duration
+	requireEmailVerification bool
+
+	throttle  loginThrottle
+	mfaIssuer string
+
+	userClient userPB.UserServiceClient
+	oidcIssuer string
+
+	// now returns the current time. MFA uses it so that codes can be checked against a fake clock.
+	now func() time.Time
 }
 
-// NewService creates a new instance of service with the provided repository and configuration.
-func NewService(repository repository.Repository, config *config.Config) Service {
+// NewService creates a new instance of service with the provided repository, signing keys, outbox, notifier, password hasher and policy, user service client, and configuration.
+func NewService(repository repository.Repository, keys *keys.Manager, outbox *outbox.Dispatcher, notifier notifier.Notifier, hasher password.Hasher, policy *password.Policy, userClient userPB.UserServiceClient, config *config.Config) Service {
+	refreshExpiry := config.RefreshTokenExpiry
+	if refreshExpiry <= 0 {
+		refreshExpiry = defaultRefreshTokenExpiry
+	}
+	resetExpiry := config.PasswordResetExpiry
+	if resetExpiry <= 0 {
+		resetExpiry = defaultPasswordResetExpiry
+	}
+	verificationExpiry := config.EmailVerificationExpiry
+	if verificationExpiry <= 0 {
+		verificationExpiry = defaultEmailVerificationExpiry
+	}
+	resendCooldown := config.VerificationResendCooldown
+	if resendCooldown <= 0 {
+		resendCooldown = defaultVerificationResendCooldown
+	}
+
+	mfaIssuer := config.MFAIssuer
+	if mfaIssuer == "" {
+		mfaIssuer = defaultMFAIssuer
+	}
+
 	return &service{
-		repository:  repository,
-		jwtSecret:   []byte(config.JWTSecret),
-		tokenExpiry: config.TokenExpiry,
+		repository:    repository,
+		keys:          keys,
+		outbox:        outbox,
+		notifier:      notifier,
+		hasher:        hasher,
+		policy:        policy,
+		tokenExpiry:   config.TokenExpiry,
+		refreshExpiry: refreshExpiry,
+		resetExpiry:   resetExpiry,
+		appBaseURL:    config.AppBaseURL,
+
+		verificationSecret:       []byte(config.JWTSecret),
+		verificationExpiry:       verificationExpiry,
+		resendCooldown:           resendCooldown,
+		requireEmailVerification: config.RequireEmailVerification,
+
+		throttle:  newLoginThrottle(config),
+		mfaIssuer: mfaIssuer,
+
+		userClient: userClient,
+		oidcIssuer: strings.TrimSuffix(config.OIDCIssuer, "/"),
+
+		now: time.Now,
 	}
 }
 
-// Register handles the user registration process.
-func (s *service) Register(ctx context.Context, email, password string) (string, error) {
+// Register handles the user registration process. The credential is stored
+// as pending together with an outbox event that creates the profile in the
+// user service; the credential becomes active once that step succeeds, or is
+// deleted again if the user service rejects the profile. A verification email
+// is sent in the given locale once the account has been created.
+func (s *service) Register(ctx context.Context, email, password, fullName, locale string) (string, error) {
 	existingCredential, err := s.repository.FindByEmail(ctx, email)
 	if err != nil {
 		return "", fmt.Errorf("failed to find user by email: %w", err)
@@ -56,109 +203,368 @@ func (s *service) Register(ctx context.Context, email, password string) (string,
 		return "", ErrEmailAlreadyExists
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := s.validatePassword(password, email); err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	credential := &model.Credential{
+		ID:           uuid.New(),
 		Email:        email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
+		Status:       model.CredentialStatusPending,
 	}
 
-	if err := s.repository.CreateUser(ctx, credential); err != nil {
+	event, err := outbox.NewEvent(outbox.EventCreateUserProfile, outbox.CreateUserProfilePayload{
+		UserID:   credential.ID.String(),
+		Email:    email,
+		FullName: fullName,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.repository.Transaction(ctx, func(tx repository.Repository) error {
+		if err := tx.CreateUser(ctx, credential); err != nil {
+			return err
+		}
+		return tx.CreateOutboxEvent(ctx, event)
+	}); err != nil {
 		return "", fmt.Errorf("failed to create user: %w", err)
 	}
 
+	// Run the next saga step right away so the account is usable as soon as
+	// Register returns. If the user service is unavailable the outbox retries.
+	if err := s.outbox.DispatchEvent(ctx, event.ID); err != nil {
+		if outbox.IsPermanent(err) {
+			if status.Code(err) == codes.AlreadyExists {
+				return "", ErrEmailAlreadyExists
+			}
+			return "", fmt.Errorf("failed to create user profile: %w", err)
+		}
+		log.Printf("profile creation for %s deferred: %v", credential.ID, err)
+	}
+
+	if err := s.sendVerificationEmail(ctx, credential, locale); err != nil {
+		log.Printf("failed to send verification email to %s: %v", credential.ID, err)
+	}
+
 	return credential.ID.String(), nil
 }
 
-// Login handles the user login process.
-func (s *service) Login(ctx context.Context, email, password string) (string, error) {
+// Login handles the user login process. Failed attempts are counted per email
+// and per client IP, and either is locked out after too many of them. Users
+// with MFA enabled get an MFA token instead of a token pair, and their failed
+// attempts are only cleared once the second factor is verified. A successful
+// login starts a session on the device of the client. Logins are recorded in
+// the login history, except for those that still need a second factor.
+func (s *service) Login(ctx context.Context, email, password string, client ClientInfo) (*LoginResult, error) {
+	if err := s.checkLoginThrottle(ctx, email, client.IP); err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			user, _ := s.repository.FindByEmail(ctx, email)
+			s.auditLoginFailure(ctx, email, user, client, model.LoginReasonLockedOut)
+		}
+		return nil, err
+	}
+
 	user, err := s.repository.FindByEmail(ctx, email)
 	if err != nil {
-		return "", fmt.Errorf("failed to find user by email: %w", err)
+		return nil, fmt.Errorf("failed to find user by email: %w", err)
 	}
 	if user == nil {
-		return "", ErrInvalidCredentials
+		s.recordLoginFailure(ctx, email, client.IP)
+		s.auditLoginFailure(ctx, email, nil, client, model.LoginReasonInvalidCredentials)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.checkPassword(user, password); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			s.recordLoginFailure(ctx, email, client.IP)
+			s.auditLoginFailure(ctx, email, user, client, model.LoginReasonInvalidCredentials)
+		}
+		return nil, err
+	}
+
+	s.rehashPassword(ctx, user, password)
+
+	if user.Status == model.CredentialStatusPending {
+		s.auditLoginFailure(ctx, email, user, client, model.LoginReasonAccountPending)
+		return nil, ErrAccountPending
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return "", ErrInvalidCredentials
+	if s.requireEmailVerification && !user.EmailVerified {
+		s.auditLoginFailure(ctx, email, user, client, model.LoginReasonEmailNotVerified)
+		return nil, ErrEmailNotVerified
 	}
 
-	token, err := s.generateToken(user)
+	mfaToken, err := s.startMFAChallenge(ctx, user)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token : %w", err)
+		return nil, err
+	}
+	if mfaToken != "" {
+		return &LoginResult{MFAToken: mfaToken}, nil
+	}
+
+	s.resetLoginThrottle(ctx, email)
+	tokens, err := s.startSession(ctx, user, client, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.auditLoginSuccess(ctx, user, client)
+
+	return &LoginResult{Tokens: tokens}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The presented
+// token is rotated; presenting it again revokes every token of its family.
+// Tokens issued to OAuth clients are refreshed through the token endpoint.
+func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	return s.rotateRefreshToken(ctx, refreshToken, "", "")
+}
+
+// rotateRefreshToken exchanges a refresh token issued to clientID for a new
+// token pair. An empty clientID stands for first-party logins. The access
+// token issued to a client carries scope, which may be narrower than the
+// scope of the refresh token.
+func (s *service) rotateRefreshToken(ctx context.Context, refreshToken, clientID, scope string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	stored, err := s.repository.FindRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+	if stored == nil || stored.ClientID != clientID {
+		return nil, ErrInvalidToken
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, s.revokeFamily(ctx, stored)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	rotated, err := s.repository.RevokeRefreshToken(ctx, stored.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if !rotated {
+		// Another request rotated this token first.
+		return nil, s.revokeFamily(ctx, stored)
+	}
+
+	user, err := s.repository.FindByID(ctx, stored.UserID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
 	}
 
-	return token, nil
+	grant := grantOf(stored)
+	if grant != nil && scope != stored.Scope {
+		grant.accessScope = scope
+	}
+	tokens, err := s.issueTokens(ctx, user, stored.FamilyID, grant, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if clientID == "" {
+		s.refreshSession(ctx, stored)
+	}
+
+	return tokens, nil
 }
 
-// DeleteUser deletes a user record.
+// DeleteUser deletes a user record and revokes every token issued to it.
 func (s *service) DeleteUser(ctx context.Context, id string) error {
-	return s.repository.DeleteByID(ctx, id)
+	if err := s.repository.DeleteByID(ctx, id); err != nil {
+		return err
+	}
+
+	return s.RevokeAllSessions(ctx, id)
 }
 
-func (s *service) VerifyToken(token string) (string, string, bool, error) {
+// VerifyToken validates a first-party access token and returns the user ID
+// and email it was issued for. Tokens issued to OAuth clients are rejected:
+// they only grant their scope, and no first-party API checks it.
+func (s *service) VerifyToken(ctx context.Context, token string) (string, string, bool, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return "", "", false, err
+	}
+	if _, ok := claims["client_id"]; ok {
+		return "", "", false, ErrClientToken
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return "", "", false, errors.New("missing or invalid user_id in token")
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return "", "", false, errors.New("missing or invalid email in token")
+	}
+
+	if err := s.checkRevocation(ctx, claims); err != nil {
+		return "", "", false, err
+	}
+
+	return userID, email, true, nil
+}
+
+// parseToken checks the signature and expiry of an access token and returns its claims.
+func (s *service) parseToken(token string) (jwt.MapClaims, error) {
 	if token == "" {
-		return "", "", false, errors.New("empty token provided")
+		return nil, errors.New("empty token provided")
 	}
 
-	parsedToken, err := jwt.Parse(token, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrUnexpectedSigning
-		}
-		return s.jwtSecret, nil
-	})
+	parser := jwt.NewParser(jwt.WithValidMethods(s.keys.Algorithms()))
+	parsedToken, err := parser.Parse(token, s.keys.Keyfunc)
 
 	if err != nil {
-		return "", "", false, fmt.Errorf("token parse error: %w", err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("token parse error: %w", err)
 	}
 
 	if !parsedToken.Valid {
-		return "", "", false, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", "", false, errors.New("failed to parse claims")
+		return nil, errors.New("failed to parse claims")
 	}
 
 	exp, ok := claims["exp"].(float64)
 	if !ok {
-		return "", "", false, errors.New("missing or invalid exp claim in token")
+		return nil, errors.New("missing or invalid exp claim in token")
 	}
 	if time.Now().Unix() > int64(exp) {
-		return "", "", false, ErrTokenExpired
+		return nil, ErrTokenExpired
 	}
 
-	userID, ok := claims["user_id"].(string)
-	if !ok || userID == "" {
-		return "", "", false, errors.New("missing or invalid user_id in token")
+	return claims, nil
+}
+
+// PublicKeys returns the public keys that verify access tokens.
+func (s *service) PublicKeys() []keys.JWK {
+	return s.keys.PublicKeys()
+}
+
+// revokeFamily revokes every refresh token issued alongside a reused token.
+func (s *service) revokeFamily(ctx context.Context, token *model.RefreshToken) error {
+	if err := s.repository.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
 	}
+	return ErrTokenReused
+}
 
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		return "", "", false, errors.New("missing or invalid email in token")
+// issueTokens generates an access token issued at iat and stores a new refresh
+// token in the given family. Tokens issued to an OAuth client carry its grant.
+func (s *service) issueTokens(ctx context.Context, user *model.Credential, familyID uuid.UUID, grant *oauthGrant, iat time.Time) (*TokenPair, error) {
+	// First-party token families are sessions; their access tokens carry the session ID.
+	var sessionID string
+	if grant == nil {
+		sessionID = familyID.String()
 	}
 
-	return userID, email, true, nil
+	accessToken, err := s.accessToken(ctx, user, sessionID, grant, iat)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	stored := &model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshExpiry),
+	}
+	if grant != nil {
+		stored.ClientID = grant.clientID
+		stored.Scope = grant.scope
+	}
+	if err := s.repository.CreateRefreshToken(ctx, stored); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
 }
 
-// generateToken generates a JWT token for the given user.
-func (s *service) generateToken(user *model.Credential) (string, error) {
+// accessToken generates an access token issued at iat for the given user with
+// its current roles.
+func (s *service) accessToken(ctx context.Context, user *model.Credential, sessionID string, grant *oauthGrant, iat time.Time) (string, error) {
+	roles, err := s.repository.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list user roles: %w", err)
+	}
+
+	accessToken, err := s.generateToken(user, roles, sessionID, grant, iat)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token : %w", err)
+	}
+
+	return accessToken, nil
+}
+
+// generateToken generates a JWT token for the given user and roles. Tokens of
+// a session carry its ID in the sid claim, and tokens issued to an OAuth
+// client carry its client_id and granted scope.
+func (s *service) generateToken(user *model.Credential, roles []string, sessionID string, grant *oauthGrant, now time.Time) (string, error) {
 	claims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"email":   user.Email,
-		"exp":     time.Now().Add(s.tokenExpiry).Unix(),
+		"jti":            uuid.New().String(),
+		"user_id":        user.ID.String(),
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"roles":          roles,
+		"iat":            issuedAt(now),
+		"exp":            now.Add(s.tokenExpiry).Unix(),
+	}
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
+	if grant != nil {
+		claims["client_id"] = grant.clientID
+		claims["scope"] = grant.tokenScope()
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(s.jwtSecret)
+	signedToken, err := s.keys.Sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
 	return signedToken, nil
 }
+
+// generateOpaqueToken returns a random URL-safe token.
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex encoded SHA-256 digest of an opaque token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
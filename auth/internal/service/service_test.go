@@ -0,0 +1,457 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/config"
+	"github.com/PakornBank/go-grpc-example/auth/internal/keys"
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/notifier"
+	"github.com/PakornBank/go-grpc-example/auth/internal/outbox"
+	"github.com/PakornBank/go-grpc-example/auth/internal/password"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	testEmail    = "alice@example.com"
+	testPassword = "correct-horse-battery"
+)
+
+// fakeUserClient stands in for the user service. Like the real one,
+// CreateUser is idempotent for a repeated user ID with the same email.
+type fakeUserClient struct {
+	userPB.UserServiceClient
+
+	mu      sync.Mutex
+	users   map[string]string
+	names   map[string]string
+	creates int
+	// createErrs are returned by the next calls to CreateUser, in order.
+	createErrs []error
+}
+
+func newFakeUserClient(createErrs ...error) *fakeUserClient {
+	return &fakeUserClient{users: map[string]string{}, names: map[string]string{}, createErrs: createErrs}
+}
+
+func (c *fakeUserClient) CreateUser(_ context.Context, req *userPB.CreateUserRequest, _ ...grpc.CallOption) (*userPB.CreateUserResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.creates++
+	if len(c.createErrs) > 0 {
+		err := c.createErrs[0]
+		c.createErrs = c.createErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if email, ok := c.users[req.UserId]; ok && email != req.Email {
+		return nil, status.Error(codes.AlreadyExists, "user ID already exists")
+	}
+	c.users[req.UserId] = req.Email
+	c.names[req.UserId] = req.FullName
+	return &userPB.CreateUserResponse{User: &userPB.User{Id: req.UserId, Email: req.Email, FullName: req.FullName}}, nil
+}
+
+func (c *fakeUserClient) GetUser(_ context.Context, req *userPB.GetUserRequest, _ ...grpc.CallOption) (*userPB.GetUserResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	email, ok := c.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return &userPB.GetUserResponse{User: &userPB.User{Id: req.UserId, Email: email, FullName: c.names[req.UserId]}}, nil
+}
+
+func (c *fakeUserClient) DeleteUser(_ context.Context, req *userPB.DeleteUserRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.users[req.UserId]; !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	delete(c.users, req.UserId)
+	delete(c.names, req.UserId)
+	return &emptypb.Empty{}, nil
+}
+
+func (c *fakeUserClient) profile(userID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	email, ok := c.users[userID]
+	return email, ok
+}
+
+// testNotifier records messages instead of sending them.
+type testNotifier struct {
+	mu       sync.Mutex
+	messages []notifier.Message
+}
+
+func (n *testNotifier) Notify(_ context.Context, msg notifier.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// testClock is a fake clock for service.now.
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{t: time.Now().Truncate(time.Second)}
+}
+
+func (c *testClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// newDispatcher creates an outbox dispatcher with the handlers the auth service registers.
+func newDispatcher(r repository.Repository, userClient userPB.UserServiceClient) *outbox.Dispatcher {
+	dispatcher := outbox.NewDispatcher(r)
+	dispatcher.Handle(outbox.EventCreateUserProfile, outbox.CreateUserProfileHandler(userClient, r))
+	dispatcher.Handle(outbox.EventDeleteUserProfile, outbox.DeleteUserProfileHandler(userClient))
+	return dispatcher
+}
+
+// newTestService creates a service backed by r, with a fast password hasher
+// and freshly generated signing keys.
+func newTestService(t *testing.T, r repository.Repository, userClient userPB.UserServiceClient) *service {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWTSecret:   "test-secret",
+		TokenExpiry: 15 * time.Minute,
+		OIDCIssuer:  "https://auth.example.com",
+	}
+
+	keyManager, err := keys.NewManager(r, keys.AlgorithmEdDSA, 0, 0, cfg.TokenExpiry)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := keyManager.Rotate(context.Background()); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	hasher, err := password.NewHasher(password.Config{Algorithm: password.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+	policy, err := password.NewPolicy(password.PolicyConfig{}, password.AlgorithmBcrypt)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	return NewService(r, keyManager, newDispatcher(r, userClient), &testNotifier{}, hasher, policy, userClient, cfg).(*service)
+}
+
+// registerUser registers testEmail and fails the test unless it becomes active.
+func registerUser(t *testing.T, s *service) *model.Credential {
+	t.Helper()
+
+	ctx := context.Background()
+	userID, err := s.Register(ctx, testEmail, testPassword, "Alice", "")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	credential, err := s.repository.FindByID(ctx, userID)
+	if err != nil || credential == nil {
+		t.Fatalf("FindByID(%s) = %v, %v", userID, credential, err)
+	}
+	if credential.Status != model.CredentialStatusActive {
+		t.Fatalf("credential status = %q, want %q", credential.Status, model.CredentialStatusActive)
+	}
+	return credential
+}
+
+var errCrash = errors.New("process crashed")
+
+// crashBeforeDispatch fails every single event claim, as if the process died
+// between committing the registration and dispatching its event.
+type crashBeforeDispatch struct {
+	*repositorytest.Repository
+}
+
+func (crashBeforeDispatch) ClaimOutboxEvent(context.Context, uuid.UUID, time.Time, time.Duration) (*model.OutboxEvent, error) {
+	return nil, errCrash
+}
+
+// failActivation fails the first UpdateStatus, as if the process died after
+// the profile was created but before the credential was activated.
+type failActivation struct {
+	*repositorytest.Repository
+	failed bool
+}
+
+func (r *failActivation) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	if !r.failed {
+		r.failed = true
+		return errCrash
+	}
+	return r.Repository.UpdateStatus(ctx, id, status)
+}
+
+func TestRegisterSaga(t *testing.T) {
+	tests := []struct {
+		name string
+		// repository wraps the store that Register runs against.
+		repository func(r *repositorytest.Repository) repository.Repository
+		createErrs []error
+		// wantRegisterErr is whether Register itself fails.
+		wantRegisterErr bool
+		// wantPending is the credential status before the outbox is redelivered.
+		wantPending bool
+		// wantActive is whether the credential ends up active; otherwise it is deleted.
+		wantActive  bool
+		wantCreates int
+	}{
+		{
+			name:       "delivered right away",
+			repository: func(r *repositorytest.Repository) repository.Repository { return r },
+			wantActive: true, wantCreates: 1,
+		},
+		{
+			name:        "crash before dispatch",
+			repository:  func(r *repositorytest.Repository) repository.Repository { return crashBeforeDispatch{r} },
+			wantPending: true, wantActive: true, wantCreates: 1,
+		},
+		{
+			name:        "user service unavailable",
+			repository:  func(r *repositorytest.Repository) repository.Repository { return r },
+			createErrs:  []error{status.Error(codes.Unavailable, "connection refused")},
+			wantPending: true, wantActive: true, wantCreates: 2,
+		},
+		{
+			name:        "profile created but credential not activated",
+			repository:  func(r *repositorytest.Repository) repository.Repository { return &failActivation{Repository: r} },
+			wantPending: true, wantActive: true, wantCreates: 2,
+		},
+		{
+			name:            "profile rejected",
+			repository:      func(r *repositorytest.Repository) repository.Repository { return r },
+			createErrs:      []error{status.Error(codes.InvalidArgument, "invalid full name")},
+			wantRegisterErr: true, wantCreates: 1,
+		},
+		{
+			name:        "profile rejected on redelivery",
+			repository:  func(r *repositorytest.Repository) repository.Repository { return crashBeforeDispatch{r} },
+			createErrs:  []error{status.Error(codes.InvalidArgument, "invalid full name")},
+			wantPending: true, wantCreates: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := repositorytest.New()
+			userClient := newFakeUserClient(tt.createErrs...)
+			s := newTestService(t, tt.repository(store), userClient)
+
+			userID, err := s.Register(ctx, testEmail, testPassword, "Alice", "")
+			if (err != nil) != tt.wantRegisterErr {
+				t.Fatalf("Register error = %v, want error %v", err, tt.wantRegisterErr)
+			}
+
+			events := store.OutboxEvents()
+			if len(events) != 1 {
+				t.Fatalf("got %d outbox events, want 1", len(events))
+			}
+			eventID := events[0].ID
+
+			if !tt.wantRegisterErr {
+				credential, _ := store.FindByID(ctx, userID)
+				wantStatus := model.CredentialStatusActive
+				if tt.wantPending {
+					wantStatus = model.CredentialStatusPending
+				}
+				if credential == nil || credential.Status != wantStatus {
+					t.Fatalf("credential after Register = %+v, want status %q", credential, wantStatus)
+				}
+
+				if _, err := s.Login(ctx, testEmail, testPassword, ClientInfo{}); tt.wantPending && !errors.Is(err, ErrAccountPending) {
+					t.Errorf("Login of pending account error = %v, want %v", err, ErrAccountPending)
+				}
+
+				// A new process picks up whatever is left in the outbox.
+				store.RescheduleOutboxEvents(time.Now())
+				if err := newDispatcher(store, userClient).DispatchDue(ctx); err != nil {
+					t.Fatalf("DispatchDue: %v", err)
+				}
+			}
+
+			credential, _ := store.FindByEmail(ctx, testEmail)
+			event, _ := store.OutboxEvent(eventID)
+			if tt.wantActive {
+				if credential == nil || credential.Status != model.CredentialStatusActive {
+					t.Fatalf("credential after delivery = %+v, want active", credential)
+				}
+				if _, ok := userClient.profile(credential.ID.String()); !ok {
+					t.Errorf("profile of %s was not created", credential.ID)
+				}
+				if event.ProcessedAt == nil {
+					t.Errorf("outbox event was not marked as delivered: %+v", event)
+				}
+			} else {
+				if credential != nil {
+					t.Fatalf("credential after rejection = %+v, want it deleted", credential)
+				}
+				if event.FailedAt == nil {
+					t.Errorf("outbox event was not marked as failed: %+v", event)
+				}
+			}
+
+			if userClient.creates != tt.wantCreates {
+				t.Errorf("CreateUser called %d times, want %d", userClient.creates, tt.wantCreates)
+			}
+
+			// Nothing is left to deliver, so a later poll changes nothing.
+			if err := newDispatcher(store, userClient).DispatchDue(ctx); err != nil {
+				t.Fatalf("DispatchDue: %v", err)
+			}
+			if userClient.creates != tt.wantCreates {
+				t.Errorf("CreateUser called %d times after a later poll, want %d", userClient.creates, tt.wantCreates)
+			}
+		})
+	}
+}
+
+// login logs in as testEmail and fails the test unless it returns a token pair.
+func login(t *testing.T, s *service) *TokenPair {
+	t.Helper()
+
+	result, err := s.Login(context.Background(), testEmail, testPassword, ClientInfo{IP: "203.0.113.7", UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if result.Tokens == nil {
+		t.Fatalf("Login = %+v, want a token pair", result)
+	}
+	return result.Tokens
+}
+
+// issuedRefreshToken picks the refresh token issued by the login (0) or by
+// the n-th successful refresh (n).
+func issuedRefreshToken(n int) func(issued []*TokenPair) string {
+	return func(issued []*TokenPair) string { return issued[n].RefreshToken }
+}
+
+// rawRefreshToken presents a refresh token that was never issued.
+func rawRefreshToken(token string) func(issued []*TokenPair) string {
+	return func([]*TokenPair) string { return token }
+}
+
+func TestRefreshToken(t *testing.T) {
+	type step struct {
+		token   func(issued []*TokenPair) string
+		wantErr error
+	}
+
+	tests := []struct {
+		name          string
+		refreshExpiry time.Duration
+		steps         []step
+	}{
+		{
+			name:  "rotates on every use",
+			steps: []step{{token: issuedRefreshToken(0)}, {token: issuedRefreshToken(1)}, {token: issuedRefreshToken(2)}},
+		},
+		{
+			name: "reusing a rotated token revokes its family",
+			steps: []step{
+				{token: issuedRefreshToken(0)},
+				{token: issuedRefreshToken(0), wantErr: ErrTokenReused},
+				{token: issuedRefreshToken(1), wantErr: ErrTokenReused},
+			},
+		},
+		{
+			name: "reusing an older token revokes its family",
+			steps: []step{
+				{token: issuedRefreshToken(0)},
+				{token: issuedRefreshToken(1)},
+				{token: issuedRefreshToken(0), wantErr: ErrTokenReused},
+				{token: issuedRefreshToken(2), wantErr: ErrTokenReused},
+			},
+		},
+		{
+			name:          "expired",
+			refreshExpiry: -time.Minute,
+			steps:         []step{{token: issuedRefreshToken(0), wantErr: ErrTokenExpired}},
+		},
+		{
+			name:  "unknown",
+			steps: []step{{token: rawRefreshToken("not-a-refresh-token"), wantErr: ErrInvalidToken}, {token: issuedRefreshToken(0)}},
+		},
+		{
+			name:  "empty",
+			steps: []step{{token: rawRefreshToken(""), wantErr: ErrInvalidToken}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			registerUser(t, s)
+			if tt.refreshExpiry != 0 {
+				s.refreshExpiry = tt.refreshExpiry
+			}
+			issued := []*TokenPair{login(t, s)}
+
+			for i, st := range tt.steps {
+				refreshToken := st.token(issued)
+				tokens, err := s.RefreshToken(ctx, refreshToken)
+				if !errors.Is(err, st.wantErr) {
+					t.Fatalf("step %d: RefreshToken error = %v, want %v", i, err, st.wantErr)
+				}
+				if err != nil {
+					continue
+				}
+
+				if tokens.RefreshToken == refreshToken {
+					t.Errorf("step %d: refresh token was not rotated", i)
+				}
+				if _, _, valid, err := s.VerifyToken(ctx, tokens.AccessToken); !valid || err != nil {
+					t.Errorf("step %d: VerifyToken of the new access token = %v, %v", i, valid, err)
+				}
+				issued = append(issued, tokens)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenDefaultExpiry(t *testing.T) {
+	// The test configuration leaves RefreshTokenExpiry unset.
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+
+	if s.refreshExpiry != defaultRefreshTokenExpiry {
+		t.Errorf("refreshExpiry = %v, want the default %v", s.refreshExpiry, defaultRefreshTokenExpiry)
+	}
+}
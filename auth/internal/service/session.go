@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/model"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// sessionTouchResolution is how stale the last use of a session may get
+// before a verified token writes it again.
+const sessionTouchResolution = time.Minute
+
+// ClientInfo describes the device a login comes from, as forwarded by the gateway.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// ListSessions returns the sessions of a user that are neither revoked nor expired.
+func (s *service) ListSessions(ctx context.Context, userID string) ([]model.Session, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	sessions, err := s.repository.ListSessions(ctx, uid, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// TouchSessions records that the given sessions were used, for tokens that
+// were verified without calling VerifyToken. Unknown IDs are ignored.
+func (s *service) TouchSessions(ctx context.Context, sessionIDs []string) error {
+	now := s.now()
+	for _, sessionID := range sessionIDs {
+		id, err := uuid.Parse(sessionID)
+		if err != nil {
+			continue
+		}
+		if err := s.repository.TouchSession(ctx, id, now, sessionTouchResolution); err != nil {
+			return fmt.Errorf("failed to record use of session: %w", err)
+		}
+	}
+	return nil
+}
+
+// RevokeSession signs a user out of one session. Its refresh tokens stop
+// working right away, and VerifyToken rejects its access tokens.
+func (s *service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrRecordNotFound
+	}
+	sid, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrRecordNotFound
+	}
+
+	if err := s.revokeSession(ctx, uid, sid); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// startSession records a new session for a user on the device of the client
+// and issues its first token pair at the given time.
+func (s *service) startSession(ctx context.Context, user *model.Credential, client ClientInfo, iat time.Time) (*TokenPair, error) {
+	now := s.now()
+	session := &model.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		UserAgent:  truncate(client.UserAgent, 512),
+		IPAddress:  truncate(client.IP, 64),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.refreshExpiry),
+	}
+	if err := s.repository.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueTokens(ctx, user, session.ID, nil, iat)
+}
+
+// refreshSession extends the session of a rotated first-party refresh token.
+// Token families issued before sessions were recorded get a session on their
+// first refresh. Failures are logged, as the new tokens were already issued.
+func (s *service) refreshSession(ctx context.Context, stored *model.RefreshToken) {
+	now := s.now()
+	expiresAt := now.Add(s.refreshExpiry)
+
+	session, err := s.repository.FindSession(ctx, stored.FamilyID)
+	if err == nil && session == nil {
+		err = s.repository.CreateSession(ctx, &model.Session{
+			ID:         stored.FamilyID,
+			UserID:     stored.UserID,
+			LastUsedAt: now,
+			ExpiresAt:  expiresAt,
+		})
+	} else if err == nil {
+		err = s.repository.ExtendSession(ctx, session.ID, now, expiresAt)
+	}
+	if err != nil {
+		log.Printf("failed to refresh session %s: %v", stored.FamilyID, err)
+	}
+}
+
+// revokeSession revokes a session of a user together with its refresh tokens.
+func (s *service) revokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return s.repository.Transaction(ctx, func(tx repository.Repository) error {
+		if err := tx.RevokeSession(ctx, userID, sessionID, s.now()); err != nil {
+			return err
+		}
+		if err := tx.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+			return fmt.Errorf("failed to revoke refresh token family: %w", err)
+		}
+		return nil
+	})
+}
+
+// checkSession rejects tokens whose session was revoked or removed, and
+// records the use of the session. Tokens without a sid claim are not tied
+// to a session.
+func (s *service) checkSession(ctx context.Context, claims jwt.MapClaims) error {
+	sid := stringClaim(claims, "sid")
+	if sid == "" {
+		return nil
+	}
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	session, err := s.repository.FindSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to find session: %w", err)
+	}
+	if session == nil || session.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+
+	if err := s.repository.TouchSession(ctx, session.ID, s.now(), sessionTouchResolution); err != nil {
+		log.Printf("failed to record use of session %s: %v", session.ID, err)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes without splitting a character, so
+// that client supplied values fit their column.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
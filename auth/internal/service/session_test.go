@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+// sessionOf returns the session ID of an access token issued by s.
+func sessionOf(t *testing.T, s *service, token string) string {
+	t.Helper()
+
+	sid, _ := tokenClaims(t, s, token)["sid"].(string)
+	if sid == "" {
+		t.Fatal("access token has no sid claim")
+	}
+	return sid
+}
+
+// lastUsed returns when the session of the user was last used.
+func lastUsed(t *testing.T, s *service, userID, sessionID string) time.Time {
+	t.Helper()
+
+	sessions, err := s.ListSessions(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	for _, session := range sessions {
+		if session.ID.String() == sessionID {
+			return session.LastUsedAt
+		}
+	}
+	t.Fatalf("session %s is not listed", sessionID)
+	return time.Time{}
+}
+
+func TestListSessions(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	s := newTestService(t, repositorytest.New(), newFakeUserClient())
+	s.now = clock.now
+	userID := registerUser(t, s).ID.String()
+
+	first := login(t, s)
+	clock.advance(time.Minute)
+	second := login(t, s)
+
+	sessions, err := s.ListSessions(ctx, userID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("ListSessions returned %d sessions, want 2", len(sessions))
+	}
+	// The most recently used session comes first.
+	if sessions[0].ID.String() != sessionOf(t, s, second.AccessToken) || sessions[1].ID.String() != sessionOf(t, s, first.AccessToken) {
+		t.Errorf("ListSessions = %v, %v, want the second session first", sessions[0].ID, sessions[1].ID)
+	}
+	if sessions[0].IPAddress != "203.0.113.7" || sessions[0].UserAgent != "test" {
+		t.Errorf("session device = %q, %q, want the IP and user agent of the login", sessions[0].IPAddress, sessions[0].UserAgent)
+	}
+
+	if err := s.RevokeSession(ctx, userID, sessionOf(t, s, first.AccessToken)); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if sessions, _ := s.ListSessions(ctx, userID); len(sessions) != 1 {
+		t.Errorf("ListSessions after RevokeSession returned %d sessions, want 1", len(sessions))
+	}
+
+	clock.advance(s.refreshExpiry)
+	if sessions, _ := s.ListSessions(ctx, userID); len(sessions) != 0 {
+		t.Errorf("ListSessions after expiry returned %d sessions, want none", len(sessions))
+	}
+	if _, err := s.ListSessions(ctx, "not-a-uuid"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("ListSessions of a malformed user ID error = %v, want %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// userID and sessionID return the IDs to revoke, given those of the first session.
+		userID    func(userID string) string
+		sessionID func(sessionID string) string
+		wantErr   error
+	}{
+		{name: "own session"},
+		{name: "session of another user", userID: func(string) string { return uuid.NewString() }, wantErr: ErrRecordNotFound},
+		{name: "unknown session", sessionID: func(string) string { return uuid.NewString() }, wantErr: ErrRecordNotFound},
+		{name: "malformed session ID", sessionID: func(string) string { return "not-a-uuid" }, wantErr: ErrRecordNotFound},
+		{name: "malformed user ID", userID: func(string) string { return "not-a-uuid" }, wantErr: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			userID := registerUser(t, s).ID.String()
+			first := login(t, s)
+			other := login(t, s)
+
+			revokeUserID, revokeSessionID := userID, sessionOf(t, s, first.AccessToken)
+			if tt.userID != nil {
+				revokeUserID = tt.userID(revokeUserID)
+			}
+			if tt.sessionID != nil {
+				revokeSessionID = tt.sessionID(revokeSessionID)
+			}
+			err := s.RevokeSession(ctx, revokeUserID, revokeSessionID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevokeSession error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				if _, _, _, err := s.VerifyToken(ctx, first.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+					t.Errorf("VerifyToken of the revoked session error = %v, want %v", err, ErrTokenRevoked)
+				}
+				if _, err := s.RefreshToken(ctx, first.RefreshToken); err == nil {
+					t.Error("RefreshToken of the revoked session succeeded")
+				}
+				if err := s.RevokeSession(ctx, userID, sessionOf(t, s, first.AccessToken)); !errors.Is(err, ErrRecordNotFound) {
+					t.Errorf("second RevokeSession error = %v, want %v", err, ErrRecordNotFound)
+				}
+			} else if _, _, valid, err := s.VerifyToken(ctx, first.AccessToken); !valid || err != nil {
+				t.Errorf("VerifyToken after a failed RevokeSession = %v, %v", valid, err)
+			}
+
+			// The other session is not affected either way.
+			if _, _, valid, err := s.VerifyToken(ctx, other.AccessToken); !valid || err != nil {
+				t.Errorf("VerifyToken of the other session = %v, %v", valid, err)
+			}
+			if _, err := s.RefreshToken(ctx, other.RefreshToken); err != nil {
+				t.Errorf("RefreshToken of the other session: %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionUse(t *testing.T) {
+	tests := []struct {
+		name    string
+		advance time.Duration
+		// use records a use of the session of token.
+		use         func(t *testing.T, s *service, token string)
+		wantTouched bool
+	}{
+		{
+			name:    "VerifyToken",
+			advance: 2 * sessionTouchResolution,
+			use: func(t *testing.T, s *service, token string) {
+				if _, _, valid, err := s.VerifyToken(context.Background(), token); !valid || err != nil {
+					t.Fatalf("VerifyToken = %v, %v", valid, err)
+				}
+			},
+			wantTouched: true,
+		},
+		{
+			name:    "VerifyToken within the touch resolution",
+			advance: sessionTouchResolution / 2,
+			use: func(t *testing.T, s *service, token string) {
+				if _, _, valid, err := s.VerifyToken(context.Background(), token); !valid || err != nil {
+					t.Fatalf("VerifyToken = %v, %v", valid, err)
+				}
+			},
+		},
+		{
+			name:    "TouchSessions",
+			advance: 2 * sessionTouchResolution,
+			use: func(t *testing.T, s *service, token string) {
+				ids := []string{"not-a-uuid", uuid.NewString(), sessionOf(t, s, token)}
+				if err := s.TouchSessions(context.Background(), ids); err != nil {
+					t.Fatalf("TouchSessions: %v", err)
+				}
+			},
+			wantTouched: true,
+		},
+		{
+			name:    "TouchSessions within the touch resolution",
+			advance: sessionTouchResolution / 2,
+			use: func(t *testing.T, s *service, token string) {
+				if err := s.TouchSessions(context.Background(), []string{sessionOf(t, s, token)}); err != nil {
+					t.Fatalf("TouchSessions: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			s.now = clock.now
+			userID := registerUser(t, s).ID.String()
+			token := login(t, s).AccessToken
+			sessionID := sessionOf(t, s, token)
+			loggedInAt := lastUsed(t, s, userID, sessionID)
+
+			clock.advance(tt.advance)
+			tt.use(t, s, token)
+
+			want := loggedInAt
+			if tt.wantTouched {
+				want = clock.now()
+			}
+			if got := lastUsed(t, s, userID, sessionID); !got.Equal(want) {
+				t.Errorf("LastUsedAt = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/config"
+)
+
+const (
+	defaultLoginLockoutThreshold   = 5
+	defaultLoginIPLockoutThreshold = 20
+	defaultLoginLockout            = time.Minute
+	defaultLoginMaxLockout         = time.Hour
+	defaultLoginFailureWindow      = 15 * time.Minute
+)
+
+// LockedError is returned by Login and VerifyMFA while the email or client IP
+// is locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry after %s", e.RetryAfter)
+}
+
+// Is makes errors.Is(err, ErrTooManyAttempts) match a LockedError.
+func (e *LockedError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// loginThrottle is the lockout policy for failed logins. Once a key reaches
+// its threshold within the failure window, every further failure locks it for
+// twice as long as the previous one, up to maxLockout.
+type loginThrottle struct {
+	emailThreshold int
+	ipThreshold    int
+	lockout        time.Duration
+	maxLockout     time.Duration
+	window         time.Duration
+}
+
+// newLoginThrottle creates the lockout policy from the configuration.
+func newLoginThrottle(config *config.Config) loginThrottle {
+	t := loginThrottle{
+		emailThreshold: config.LoginLockoutThreshold,
+		ipThreshold:    config.LoginIPLockoutThreshold,
+		lockout:        config.LoginLockout,
+		maxLockout:     config.LoginMaxLockout,
+		window:         config.LoginFailureWindow,
+	}
+	if t.emailThreshold <= 0 {
+		t.emailThreshold = defaultLoginLockoutThreshold
+	}
+	if t.ipThreshold <= 0 {
+		t.ipThreshold = defaultLoginIPLockoutThreshold
+	}
+	if t.lockout <= 0 {
+		t.lockout = defaultLoginLockout
+	}
+	if t.maxLockout < t.lockout {
+		t.maxLockout = defaultLoginMaxLockout
+	}
+	if t.window <= 0 {
+		t.window = defaultLoginFailureWindow
+	}
+	return t
+}
+
+// lockoutFor returns how long a key is locked after the given number of failures.
+func (t loginThrottle) lockoutFor(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	lockout := t.lockout
+	for i := threshold; i < failures && lockout < t.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > t.maxLockout {
+		lockout = t.maxLockout
+	}
+	return lockout
+}
+
+// UnlockAccount clears the failed logins and lockout of an email.
+func (s *service) UnlockAccount(ctx context.Context, email string) error {
+	if err := s.repository.ResetLoginThrottle(ctx, emailThrottleKey(email)); err != nil {
+		return fmt.Errorf("failed to unlock account: %w", err)
+	}
+	return nil
+}
+
+// checkLoginThrottle returns a LockedError if the email or client IP is locked out.
+func (s *service) checkLoginThrottle(ctx context.Context, email, clientIP string) error {
+	keys := []string{emailThrottleKey(email)}
+	if clientIP != "" {
+		keys = append(keys, ipThrottleKey(clientIP))
+	}
+
+	throttles, err := s.repository.FindLoginThrottles(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("failed to check login throttle: %w", err)
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			if wait := throttle.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed login against the email and client IP and
+// locks those that reached their threshold. Errors are only logged, so that
+// the caller still sees the failed login.
+func (s *service) recordLoginFailure(ctx context.Context, email, clientIP string) {
+	s.recordThrottleFailure(ctx, emailThrottleKey(email), s.throttle.emailThreshold)
+	if clientIP != "" {
+		s.recordThrottleFailure(ctx, ipThrottleKey(clientIP), s.throttle.ipThreshold)
+	}
+}
+
+func (s *service) recordThrottleFailure(ctx context.Context, key string, threshold int) {
+	now := time.Now()
+
+	throttle, err := s.repository.RecordLoginFailure(ctx, key, now, s.throttle.window)
+	if err != nil {
+		log.Printf("failed to record login failure for %s: %v", key, err)
+		return
+	}
+
+	if lockout := s.throttle.lockoutFor(throttle.Failures, threshold); lockout > 0 {
+		if err := s.repository.LockLogin(ctx, key, now.Add(lockout)); err != nil {
+			log.Printf("failed to lock %s: %v", key, err)
+		}
+	}
+}
+
+// resetLoginThrottle clears the failed logins counted against an email after a
+// completed login. Errors are only logged, as the login already succeeded.
+func (s *service) resetLoginThrottle(ctx context.Context, email string) {
+	if err := s.repository.ResetLoginThrottle(ctx, emailThrottleKey(email)); err != nil {
+		log.Printf("failed to reset login throttle: %v", err)
+	}
+}
+
+func emailThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/auth/internal/config"
+	"github.com/PakornBank/go-grpc-example/auth/internal/repository/repositorytest"
+)
+
+func TestLockoutFor(t *testing.T) {
+	throttle := newLoginThrottle(&config.Config{
+		LoginLockout:    time.Minute,
+		LoginMaxLockout: 10 * time.Minute,
+	})
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 4, want: 0},
+		{failures: 5, want: time.Minute},
+		{failures: 6, want: 2 * time.Minute},
+		{failures: 7, want: 4 * time.Minute},
+		{failures: 8, want: 8 * time.Minute},
+		{failures: 9, want: 10 * time.Minute},
+		{failures: 100, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := throttle.lockoutFor(tt.failures, 5); got != tt.want {
+			t.Errorf("lockoutFor(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []failedLogin
+		unlock   bool
+		// email and ip are those of the final login with the right password.
+		email   string
+		ip      string
+		wantErr error
+	}{
+		{
+			name:     "below the email threshold",
+			failures: failedLogins(testEmail, "198.51.100.1", defaultLoginLockoutThreshold-1),
+			email:    testEmail, ip: "198.51.100.1",
+		},
+		{
+			name:     "email locked out",
+			failures: failedLogins(testEmail, "198.51.100.1", defaultLoginLockoutThreshold),
+			email:    testEmail, ip: "198.51.100.2", wantErr: ErrTooManyAttempts,
+		},
+		{
+			name:     "email locked out regardless of case",
+			failures: failedLogins("ALICE@example.com", "198.51.100.1", defaultLoginLockoutThreshold),
+			email:    testEmail, ip: "198.51.100.2", wantErr: ErrTooManyAttempts,
+		},
+		{
+			name:     "unlocked by an admin",
+			failures: failedLogins(testEmail, "198.51.100.1", defaultLoginLockoutThreshold),
+			unlock:   true,
+			email:    testEmail, ip: "198.51.100.2",
+		},
+		{
+			name:     "client IP locked out",
+			failures: sprayedLogins("198.51.100.1", defaultLoginIPLockoutThreshold),
+			email:    testEmail, ip: "198.51.100.1", wantErr: ErrTooManyAttempts,
+		},
+		{
+			name:     "other client IP not affected",
+			failures: sprayedLogins("198.51.100.1", defaultLoginIPLockoutThreshold),
+			email:    testEmail, ip: "198.51.100.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, repositorytest.New(), newFakeUserClient())
+			registerUser(t, s)
+
+			for i, f := range tt.failures {
+				_, err := s.Login(ctx, f.email, "wrong-password", ClientInfo{IP: f.ip})
+				if !errors.Is(err, ErrInvalidCredentials) && !errors.Is(err, ErrTooManyAttempts) {
+					t.Fatalf("failed login %d error = %v", i, err)
+				}
+			}
+			if tt.unlock {
+				if err := s.UnlockAccount(ctx, testEmail); err != nil {
+					t.Fatalf("UnlockAccount: %v", err)
+				}
+			}
+
+			_, err := s.Login(ctx, tt.email, testPassword, ClientInfo{IP: tt.ip})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			var locked *LockedError
+			if errors.As(err, &locked) && (locked.RetryAfter <= 0 || locked.RetryAfter > defaultLoginLockout) {
+				t.Errorf("RetryAfter = %s, want up to %s", locked.RetryAfter, defaultLoginLockout)
+			}
+		})
+	}
+}
+
+// failedLogin is a login with a wrong password.
+type failedLogin struct {
+	email string
+	ip    string
+}
+
+func failedLogins(email, ip string, n int) []failedLogin {
+	logins := make([]failedLogin, n)
+	for i := range logins {
+		logins[i] = failedLogin{email: email, ip: ip}
+	}
+	return logins
+}
+
+// sprayedLogins are failed logins from one client IP, each for another email,
+// so that none of the emails gets locked out.
+func sprayedLogins(ip string, n int) []failedLogin {
+	logins := make([]failedLogin, n)
+	for i := range logins {
+		logins[i] = failedLogin{email: fmt.Sprintf("user%d@example.com", i), ip: ip}
+	}
+	return logins
+}
@@ -0,0 +1,95 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors, "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// The SHA-1 vectors of RFC 6238, Appendix B, truncated to 6 digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfc6238Secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code with an invalid secret succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	tests := []struct {
+		name   string
+		offset int64
+		code   func(code string) string
+		want   bool
+	}{
+		{name: "current step", offset: 0, want: true},
+		{name: "previous step", offset: -1, want: true},
+		{name: "next step", offset: 1, want: true},
+		{name: "two steps behind", offset: -2, want: false},
+		{name: "two steps ahead", offset: 2, want: false},
+		{name: "surrounding spaces", offset: 0, code: func(c string) string { return " " + c + "\n" }, want: true},
+		{name: "too short", offset: 0, code: func(c string) string { return c[:5] }, want: false},
+		{name: "too long", offset: 0, code: func(c string) string { return c + "0" }, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Code(rfc6238Secret, current+tt.offset)
+			if err != nil {
+				t.Fatalf("Code: %v", err)
+			}
+			if tt.code != nil {
+				code = tt.code(code)
+			}
+
+			step, ok := Validate(rfc6238Secret, code, now)
+			if ok != tt.want {
+				t.Fatalf("Validate(%q) ok = %v, want %v", code, ok, tt.want)
+			}
+			if ok && step != current+tt.offset {
+				t.Errorf("Validate(%q) step = %d, want %d", code, step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if _, err := Code(secret, 0); err != nil {
+		t.Errorf("Code with a generated secret: %v", err)
+	}
+	other, _ := GenerateSecret()
+	if secret == other {
+		t.Error("GenerateSecret returned the same secret twice")
+	}
+}
@@ -0,0 +1,53 @@
+// Command verify-audit-chain checks the hash chain of audit log files written
+// by the services. It prints the last sequence number and hash of each intact
+// file, which can be kept elsewhere to detect truncation later, and exits
+// with status 1 if any chain is broken.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: verify-audit-chain FILE...")
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range os.Args[1:] {
+		if err := verify(path); err != nil {
+			var chainErr *audit.ChainError
+			if !errors.As(err, &chainErr) {
+				log.Fatalf("%s: %v", path, err)
+			}
+			fmt.Printf("%s: FAILED: %v\n", path, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func verify(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := audit.Verify(file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: OK, %d records, last seq %d, last hash %s\n", path, result.Records, result.LastSeq, result.LastHash)
+	return nil
+}
@@ -25,6 +25,11 @@ func main() {
 	defer container.Close()
 
 	r := gin.Default()
+	// Client IPs feed the login throttle, sessions and login history, so
+	// forwarded headers are only believed when they come from a known proxy.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("invalid TRUSTED_PROXIES: ", err)
+	}
 
 	router.SetupRoutes(r, container)
 
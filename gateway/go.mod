@@ -6,11 +6,18 @@ require github.com/PakornBank/go-grpc-example/auth v0.0.0
 
 replace github.com/PakornBank/go-grpc-example/auth => ../auth
 
+require github.com/PakornBank/go-grpc-example v0.0.0
+
+replace github.com/PakornBank/go-grpc-example => ../
+
 require (
 	github.com/PakornBank/go-grpc-example/user v0.0.0
 	github.com/gin-gonic/gin v1.10.0
+	github.com/golang-jwt/jwt/v4 v4.5.1
 	github.com/spf13/viper v1.19.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241202173237-19429a94021a
 	google.golang.org/grpc v1.70.0
+	google.golang.org/protobuf v1.36.4
 )
 
 require (
@@ -51,8 +58,6 @@ require (
 	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20241202173237-19429a94021a // indirect
-	google.golang.org/protobuf v1.36.4 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
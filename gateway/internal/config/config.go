@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -11,6 +13,19 @@ type Config struct {
 	CACertPath      string `mapstructure:"CA_CERT_PATH"`
 	ClientCertPath  string `mapstructure:"CLIENT_CERT_PATH"`
 	ClientKeyPath   string `mapstructure:"CLIENT_KEY_PATH"`
+
+	// TrustedProxies lists the addresses or CIDRs of the proxies whose
+	// X-Forwarded-For header is trusted. None are trusted by default.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	LocalTokenVerification bool          `mapstructure:"LOCAL_TOKEN_VERIFICATION"`
+	JWKSRefreshInterval    time.Duration `mapstructure:"JWKS_REFRESH_INTERVAL"`
+	RevocationSyncInterval time.Duration `mapstructure:"REVOCATION_SYNC_INTERVAL"`
+
+	IdempotencyTTL        time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+	IdempotencyMaxEntries int           `mapstructure:"IDEMPOTENCY_MAX_ENTRIES"`
+
+	AuditLogPath string `mapstructure:"AUDIT_LOG_PATH"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -8,16 +8,46 @@ import (
 	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
 	"github.com/PakornBank/go-grpc-example/gateway/internal/config"
 	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/idempotency"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/jwks"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
 	"github.com/PakornBank/go-grpc-example/gateway/internal/security"
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
 	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"github.com/gin-gonic/gin"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
+const (
+	// defaultJWKSRefreshInterval is used when JWKS_REFRESH_INTERVAL is not configured.
+	defaultJWKSRefreshInterval = 5 * time.Minute
+	// defaultRevocationSyncInterval is used when REVOCATION_SYNC_INTERVAL is not configured.
+	defaultRevocationSyncInterval = 5 * time.Second
+	// defaultIdempotencyTTL is used when IDEMPOTENCY_TTL is not configured.
+	defaultIdempotencyTTL = 24 * time.Hour
+	// defaultIdempotencyMaxEntries is used when IDEMPOTENCY_MAX_ENTRIES is not configured.
+	defaultIdempotencyMaxEntries = 10000
+	// idempotencyPruneInterval is how often expired idempotent responses are removed.
+	idempotencyPruneInterval = time.Minute
+	// defaultAuditLogPath is used when AUDIT_LOG_PATH is not configured.
+	defaultAuditLogPath = "audit.jsonl"
+)
+
 type Container struct {
-	AuthHandler *handler.AuthHandler
-	AuthConn    *grpc.ClientConn
-	UserConn    *grpc.ClientConn
+	AuthHandler       *handler.AuthHandler
+	UserHandler       *handler.UserHandler
+	AdminHandler      *handler.AdminHandler
+	OAuthHandler      *handler.OAuthHandler
+	APIKeyHandler     *handler.APIKeyHandler
+	SessionHandler    *handler.SessionHandler
+	Authenticate      gin.HandlerFunc
+	RequirePermission func(permissions ...string) gin.HandlerFunc
+	Idempotency       gin.HandlerFunc
+	AuditLog          *audit.Log
+	AuthConn          *grpc.ClientConn
+	UserConn          *grpc.ClientConn
+	cancel            context.CancelFunc
 }
 
 // NewGRPCConnection establishes a gRPC connection with a timeout
@@ -48,21 +78,89 @@ func NewContainer(cfg *config.Config) *Container {
 	authClient := authPB.NewAuthServiceClient(authConn)
 	userClient := userPB.NewUserServiceClient(userConn)
 
-	authHandler := handler.NewAuthHandler(authClient, userClient)
+	authHandler := handler.NewAuthHandler(authClient)
+	userHandler := handler.NewUserHandler(authClient, userClient)
+	adminHandler := handler.NewAdminHandler(authClient)
+	oauthHandler := handler.NewOAuthHandler(authClient, userClient)
+	apiKeyHandler := handler.NewAPIKeyHandler(authClient)
+	sessionHandler := handler.NewSessionHandler(authClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var keySet *jwks.KeySet
+	if cfg.LocalTokenVerification {
+		syncInterval := cfg.RevocationSyncInterval
+		if syncInterval <= 0 {
+			syncInterval = defaultRevocationSyncInterval
+		}
+		revocations := jwks.NewRevocations(authClient, syncInterval)
+		if err := revocations.Sync(ctx); err != nil {
+			log.Printf("initial revocation sync failed, verifying tokens remotely: %v", err)
+		}
+		go revocations.Run(ctx)
+
+		keySet = jwks.NewKeySet(authClient, revocations)
+		if err := keySet.Refresh(ctx); err != nil {
+			log.Printf("initial JWKS refresh failed, verifying tokens remotely: %v", err)
+		}
+
+		interval := cfg.JWKSRefreshInterval
+		if interval <= 0 {
+			interval = defaultJWKSRefreshInterval
+		}
+		go keySet.Run(ctx, interval)
+	}
+
+	idempotencyTTL := cfg.IdempotencyTTL
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = defaultIdempotencyTTL
+	}
+	idempotencyMaxEntries := cfg.IdempotencyMaxEntries
+	if idempotencyMaxEntries <= 0 {
+		idempotencyMaxEntries = defaultIdempotencyMaxEntries
+	}
+	idempotencyStore := idempotency.NewStore(idempotencyTTL, idempotencyMaxEntries)
+	go idempotencyStore.Run(ctx, idempotencyPruneInterval)
+
+	auditLogPath := cfg.AuditLogPath
+	if auditLogPath == "" {
+		auditLogPath = defaultAuditLogPath
+	}
+	auditLog, err := audit.Open(auditLogPath, "gateway")
+	if err != nil {
+		log.Fatalf("failed to open audit log: %v", err)
+	}
 
 	return &Container{
-		AuthHandler: authHandler,
-		AuthConn:    authConn,
-		UserConn:    userConn,
+		AuthHandler:       authHandler,
+		UserHandler:       userHandler,
+		AdminHandler:      adminHandler,
+		OAuthHandler:      oauthHandler,
+		APIKeyHandler:     apiKeyHandler,
+		SessionHandler:    sessionHandler,
+		Authenticate:      middleware.Authenticate(authClient, keySet),
+		RequirePermission: middleware.RequirePermission(authClient),
+		Idempotency:       middleware.Idempotency(idempotencyStore),
+		AuditLog:          auditLog,
+		AuthConn:          authConn,
+		UserConn:          userConn,
+		cancel:            cancel,
 	}
 }
 
 // Close ensures that both gRPC connections are properly closed
 func (c *Container) Close() {
+	c.cancel()
+
 	if c.AuthConn != nil {
 		c.AuthConn.Close()
 	}
 	if c.UserConn != nil {
 		c.UserConn.Close()
 	}
+	if c.AuditLog != nil {
+		if err := c.AuditLog.Close(); err != nil {
+			log.Printf("failed to close audit log: %v", err)
+		}
+	}
 }
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AssignRoleInput is a struct that contains the input fields for the AssignRole method.
+type AssignRoleInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// UnlockAccountInput is a struct that contains the input fields for the UnlockAccount method.
+type UnlockAccountInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// CreateOAuthClientInput is a struct that contains the input fields for the CreateOAuthClient method.
+type CreateOAuthClientInput struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scope        string   `json:"scope"`
+	Confidential bool     `json:"confidential"`
+}
+
+type AdminHandler struct {
+	authClient authPB.AuthServiceClient
+}
+
+func NewAdminHandler(authClient authPB.AuthServiceClient) *AdminHandler {
+	return &AdminHandler{
+		authClient: authClient,
+	}
+}
+
+// ListUserRoles returns the roles of a user.
+func (h *AdminHandler) ListUserRoles(c *gin.Context) {
+	res, err := h.authClient.ListUserRoles(c.Request.Context(), &authPB.ListUserRolesRequest{
+		UserId: c.Param("id"),
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service list user roles error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": res.Roles})
+}
+
+// AssignRole assigns a role to a user.
+func (h *AdminHandler) AssignRole(c *gin.Context) {
+	var input AssignRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.AssignRole(c.Request.Context(), &authPB.AssignRoleRequest{
+		UserId: c.Param("id"),
+		Role:   input.Role,
+	}); err != nil {
+		roleError(c, "assign role", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeRole removes a role from a user.
+func (h *AdminHandler) RevokeRole(c *gin.Context) {
+	if _, err := h.authClient.RevokeRole(c.Request.Context(), &authPB.RevokeRoleRequest{
+		UserId: c.Param("id"),
+		Role:   c.Param("role"),
+	}); err != nil {
+		roleError(c, "revoke role", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockAccount clears the failed logins and lockout of an email.
+func (h *AdminHandler) UnlockAccount(c *gin.Context) {
+	var input UnlockAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.UnlockAccount(c.Request.Context(), &authPB.UnlockAccountRequest{
+		Email: input.Email,
+	}); err != nil {
+		log.Printf("auth service unlock account error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateOAuthClient registers an OAuth client. The secret of a confidential
+// client is only returned here.
+func (h *AdminHandler) CreateOAuthClient(c *gin.Context) {
+	var input CreateOAuthClientInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.authClient.CreateOAuthClient(c.Request.Context(), &authPB.CreateOAuthClientRequest{
+		Name:         input.Name,
+		RedirectUris: input.RedirectURIs,
+		GrantTypes:   input.GrantTypes,
+		Scope:        input.Scope,
+		Confidential: input.Confidential,
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service create OAuth client error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	response := gin.H{"client_id": res.ClientId}
+	if res.ClientSecret != "" {
+		response["client_secret"] = res.ClientSecret
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// roleError writes the response for an error of AssignRole or RevokeRole.
+func roleError(c *gin.Context, op string, err error) {
+	st, _ := status.FromError(err)
+	switch st.Code() {
+	case codes.NotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": st.Message()})
+	default:
+		log.Printf("auth service %s error: %v", op, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CreateAPIKeyInput is a struct that contains the input fields for the CreateAPIKey method.
+type CreateAPIKeyInput struct {
+	Name      string     `json:"name" binding:"required"`
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyHandler struct {
+	authClient authPB.AuthServiceClient
+}
+
+func NewAPIKeyHandler(authClient authPB.AuthServiceClient) *APIKeyHandler {
+	return &APIKeyHandler{
+		authClient: authClient,
+	}
+}
+
+// CreateAPIKey creates an API key for the authenticated user. The key is only
+// returned here.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var input CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &authPB.CreateAPIKeyRequest{
+		UserId: c.GetString(middleware.ContextUserID),
+		Name:   input.Name,
+		Scope:  input.Scope,
+	}
+	if input.ExpiresAt != nil {
+		req.ExpiresAt = timestamppb.New(*input.ExpiresAt)
+	}
+
+	res, err := h.authClient.CreateAPIKey(c.Request.Context(), req)
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": st.Message()})
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("auth service create API key error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	response := apiKeyResponse(res.ApiKey)
+	response["key"] = res.Key
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListAPIKeys returns the API keys of the authenticated user that were not revoked.
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	res, err := h.authClient.ListAPIKeys(c.Request.Context(), &authPB.ListAPIKeysRequest{
+		UserId: c.GetString(middleware.ContextUserID),
+	})
+	if err != nil {
+		log.Printf("auth service list API keys error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	keys := make([]gin.H, 0, len(res.ApiKeys))
+	for _, key := range res.ApiKeys {
+		keys = append(keys, apiKeyResponse(key))
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey revokes an API key of the authenticated user.
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	if _, err := h.authClient.RevokeAPIKey(c.Request.Context(), &authPB.RevokeAPIKeyRequest{
+		UserId: c.GetString(middleware.ContextUserID),
+		Id:     c.Param("id"),
+	}); err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service revoke API key error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// apiKeyResponse converts an API key to its JSON response. Unset timestamps
+// are left out.
+func apiKeyResponse(key *authPB.APIKey) gin.H {
+	response := gin.H{
+		"id":         key.Id,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"scope":      key.Scope,
+		"created_at": key.CreatedAt.AsTime(),
+	}
+	if key.ExpiresAt != nil {
+		response["expires_at"] = key.ExpiresAt.AsTime()
+	}
+	if key.LastUsedAt != nil {
+		response["last_used_at"] = key.LastUsedAt.AsTime()
+	}
+	return response
+}
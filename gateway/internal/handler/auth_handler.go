@@ -2,11 +2,15 @@ package handler
 
 import (
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
-	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -14,7 +18,7 @@ import (
 // RegisterInput is a struct that contains the input fields for the Register method.
 type RegisterInput struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"`
 	FullName string `json:"full_name" binding:"required"`
 }
 
@@ -24,15 +28,79 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// RefreshInput is a struct that contains the input fields for the Refresh method.
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutInput is a struct that contains the input fields for the Logout method.
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// PasswordResetInput is a struct that contains the input fields for the RequestPasswordReset method.
+type PasswordResetInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ConfirmPasswordResetInput is a struct that contains the input fields for the ConfirmPasswordReset method.
+type ConfirmPasswordResetInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// VerifyEmailInput is a struct that contains the input fields for the VerifyEmail method.
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationInput is a struct that contains the input fields for the ResendVerificationEmail method.
+type ResendVerificationInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ChangePasswordInput is a struct that contains the input fields for the ChangePassword method.
+type ChangePasswordInput struct {
+	OldPassword         string `json:"old_password" binding:"required"`
+	NewPassword         string `json:"new_password" binding:"required"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
+}
+
+// VerifyMFAInput is a struct that contains the input fields for the VerifyMFA method.
+type VerifyMFAInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// ConfirmMFAInput is a struct that contains the input fields for the ConfirmMFA method.
+type ConfirmMFAInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableMFAInput is a struct that contains the input fields for the DisableMFA method.
+type DisableMFAInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// JWK is a JSON Web Key as served by the JWKS endpoint.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
 type AuthHandler struct {
 	authClient authPB.AuthServiceClient
-	userClient userPB.UserServiceClient
 }
 
-func NewAuthHandler(authClient authPB.AuthServiceClient, userClient userPB.UserServiceClient) *AuthHandler {
+func NewAuthHandler(authClient authPB.AuthServiceClient) *AuthHandler {
 	return &AuthHandler{
 		authClient: authClient,
-		userClient: userClient,
 	}
 }
 
@@ -44,16 +112,20 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Register the user with the auth service.
-	res, err := h.authClient.Register(c.Request.Context(), &authPB.RegisterRequest{
+	// The auth service creates the profile in the user service as part of
+	// registration, so a single call covers both.
+	if _, err := h.authClient.Register(c.Request.Context(), &authPB.RegisterRequest{
 		Email:    input.Email,
 		Password: input.Password,
-	})
-	if err != nil {
+		FullName: input.FullName,
+		Locale:   preferredLocale(c),
+	}); err != nil {
 		st, _ := status.FromError(err)
 		switch st.Code() {
 		case codes.AlreadyExists:
 			c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+		case codes.InvalidArgument:
+			badRequest(c, st)
 		default:
 			log.Printf("auth service register error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
@@ -61,57 +133,437 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Create a user with the user service.
-	if _, err := h.userClient.CreateUser(c.Request.Context(), &userPB.CreateUserRequest{
-		UserId:   res.UserId,
+	c.Status(http.StatusCreated)
+}
+
+// Login handles the user login process.
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input LoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.authClient.Login(c.Request.Context(), &authPB.LoginRequest{
 		Email:    input.Email,
-		FullName: input.FullName,
-	}); err != nil {
+		Password: input.Password,
+	})
+	if err != nil {
 		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.Unauthenticated:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		case codes.FailedPrecondition:
+			c.JSON(http.StatusForbidden, gin.H{"error": st.Message()})
+		case codes.ResourceExhausted:
+			tooManyRequests(c, st)
+		default:
+			log.Printf("auth service login error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
 
-		// Rollback the user registration if the user creation fails.
-		if _, err := h.authClient.DeleteUser(c.Request.Context(), &authPB.DeleteUserRequest{UserId: res.UserId}); err != nil {
-			log.Printf("rollback failed for UserID: %s, error: %v", res.UserId, err)
+	if res.MfaRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    res.MfaToken,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         res.Token,
+		"refresh_token": res.RefreshToken,
+	})
+}
+
+// VerifyMFA completes a login that returned an MFA token.
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var input VerifyMFAInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.authClient.VerifyMFA(c.Request.Context(), &authPB.VerifyMFARequest{
+		MfaToken: input.MFAToken,
+		Code:     input.Code,
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.Unauthenticated:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service verify MFA error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-			return
 		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         res.Token,
+		"refresh_token": res.RefreshToken,
+	})
+}
 
+// EnrollMFA creates a new TOTP secret for the authenticated user.
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	res, err := h.authClient.EnrollMFA(c.Request.Context(), &authPB.EnrollMFARequest{
+		UserId: c.GetString(middleware.ContextUserID),
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
 		switch st.Code() {
-		case codes.AlreadyExists:
-			c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+		case codes.FailedPrecondition:
+			c.JSON(http.StatusConflict, gin.H{"error": st.Message()})
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		default:
-			log.Printf("user service create user error: %v", err)
+			log.Printf("auth service enroll MFA error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		}
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      res.Secret,
+		"otpauth_uri": res.OtpauthUri,
+	})
 }
 
-// Login handles the user login process.
-func (h *AuthHandler) Login(c *gin.Context) {
-	var input LoginInput
+// ConfirmMFA enables MFA for the authenticated user and returns its recovery codes.
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	var input ConfirmMFAInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	token, err := h.authClient.Login(c.Request.Context(), &authPB.LoginRequest{
-		Email:    input.Email,
+	res, err := h.authClient.ConfirmMFA(c.Request.Context(), &authPB.ConfirmMFARequest{
+		UserId: c.GetString(middleware.ContextUserID),
+		Code:   input.Code,
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			badRequest(c, st)
+		case codes.FailedPrecondition:
+			c.JSON(http.StatusConflict, gin.H{"error": st.Message()})
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("auth service confirm MFA error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": res.RecoveryCodes})
+}
+
+// DisableMFA turns MFA off for the authenticated user after checking its password.
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var input DisableMFAInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.DisableMFA(c.Request.Context(), &authPB.DisableMFARequest{
+		UserId:   c.GetString(middleware.ContextUserID),
 		Password: input.Password,
+	}); err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.PermissionDenied:
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid password"})
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("auth service disable MFA error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.authClient.RefreshToken(c.Request.Context(), &authPB.RefreshTokenRequest{
+		RefreshToken: input.RefreshToken,
 	})
 	if err != nil {
 		st, _ := status.FromError(err)
 		switch st.Code() {
 		case codes.Unauthenticated:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": st.Message()})
 		default:
-			log.Printf("auth service login error: %v", err)
+			log.Printf("auth service refresh token error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         res.Token,
+		"refresh_token": res.RefreshToken,
+	})
+}
+
+// Logout revokes the bearer token of the request and, if given, its refresh token.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	token := middleware.BearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+		return
+	}
+
+	var input LogoutInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if _, err := h.authClient.Logout(c.Request.Context(), &authPB.LogoutRequest{
+		Token:        token,
+		RefreshToken: input.RefreshToken,
+	}); err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.Unauthenticated:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service logout error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RequestPasswordReset sends a password reset token to the given email. The
+// response is the same whether or not the email is registered.
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var input PasswordResetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.RequestPasswordReset(c.Request.Context(), &authPB.RequestPasswordResetRequest{
+		Email:  input.Email,
+		Locale: preferredLocale(c),
+	}); err != nil {
+		log.Printf("auth service request password reset error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// ConfirmPasswordReset sets a new password using a password reset token.
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var input ConfirmPasswordResetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.ConfirmPasswordReset(c.Request.Context(), &authPB.ConfirmPasswordResetRequest{
+		Token:       input.Token,
+		NewPassword: input.NewPassword,
+	}); err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			badRequest(c, st)
+		default:
+			log.Printf("auth service confirm password reset error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyEmail marks the email of a user as verified using a verification token.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.VerifyEmail(c.Request.Context(), &authPB.VerifyEmailRequest{
+		Token: input.Token,
+	}); err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			badRequest(c, st)
+		default:
+			log.Printf("auth service verify email error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResendVerificationEmail sends a new verification email to an unverified
+// account. It answers 202 Accepted whether or not an email was sent, so that
+// it does not reveal which emails are registered.
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	var input ResendVerificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.ResendVerificationEmail(c.Request.Context(), &authPB.ResendVerificationEmailRequest{
+		Email:  input.Email,
+		Locale: preferredLocale(c),
+	}); err != nil {
+		log.Printf("auth service resend verification email error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// ChangePassword replaces the password of the authenticated user. When other
+// sessions are revoked, the response carries a new token pair for this one.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.authClient.ChangePassword(c.Request.Context(), &authPB.ChangePasswordRequest{
+		UserId:              c.GetString(middleware.ContextUserID),
+		OldPassword:         input.OldPassword,
+		NewPassword:         input.NewPassword,
+		RevokeOtherSessions: input.RevokeOtherSessions,
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.PermissionDenied:
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid password"})
+		case codes.InvalidArgument:
+			badRequest(c, st)
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("auth service change password error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token.Token})
+	if res.Token == "" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         res.Token,
+		"refresh_token": res.RefreshToken,
+	})
+}
+
+// JWKS serves the public keys that verify access tokens.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	res, err := h.authClient.GetJWKS(c.Request.Context(), &authPB.GetJWKSRequest{})
+	if err != nil {
+		log.Printf("auth service get JWKS error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	keys := make([]JWK, 0, len(res.Keys))
+	for _, k := range res.Keys {
+		keys = append(keys, JWK{
+			Kty: k.Kty,
+			Kid: k.Kid,
+			Use: k.Use,
+			Alg: k.Alg,
+			N:   k.N,
+			E:   k.E,
+			Crv: k.Crv,
+			X:   k.X,
+		})
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// preferredLocale returns the first language of the Accept-Language header,
+// or an empty string to let the auth service pick its default.
+func preferredLocale(c *gin.Context) string {
+	header := c.GetHeader("Accept-Language")
+	first, _, _ := strings.Cut(header, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return ""
+	}
+	return tag
+}
+
+// tooManyRequests responds with 429 and, if the status carries retry
+// information, a Retry-After header in whole seconds.
+func tooManyRequests(c *gin.Context, st *status.Status) {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.RetryDelay != nil {
+			seconds := int(math.Ceil(info.RetryDelay.AsDuration().Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			break
+		}
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": st.Message()})
+}
+
+// FieldViolation describes why a field of a request was rejected.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// badRequest responds with 400 and the field violations carried by the status, if any.
+func badRequest(c *gin.Context, st *status.Status) {
+	var violations []FieldViolation
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				violations = append(violations, FieldViolation{Field: v.Field, Description: v.Description})
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": st.Message()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": st.Message(), "violations": violations})
 }
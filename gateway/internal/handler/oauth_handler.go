@@ -0,0 +1,425 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// oauthErrorDomain is the ErrorInfo domain of auth service errors that carry an OAuth error code.
+const oauthErrorDomain = "oauth2"
+
+// OpenID Connect scopes that select the claims of the userinfo endpoint.
+const (
+	scopeOpenID  = "openid"
+	scopeEmail   = "email"
+	scopeProfile = "profile"
+)
+
+// AuthorizeInput is a struct that contains the parameters of an OAuth authorization request.
+// Consent is only sent once the user has decided on the request.
+type AuthorizeInput struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	Prompt              string `form:"prompt" json:"prompt"`
+	Consent             string `form:"consent" json:"consent" binding:"omitempty,oneof=approve deny"`
+}
+
+// TokenInput is a struct that contains the form fields of an OAuth token request.
+type TokenInput struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// TokenResponse is the JSON representation of an OAuth token response.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// IntrospectInput is a struct that contains the form fields of a token introspection request.
+// The token_type_hint field is accepted but not needed, since only access tokens are introspected.
+type IntrospectInput struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectResponse is the JSON representation of a token introspection response (RFC 7662).
+// Only active is set for inactive tokens.
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document.
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+}
+
+type OAuthHandler struct {
+	authClient authPB.AuthServiceClient
+	userClient userPB.UserServiceClient
+}
+
+func NewOAuthHandler(authClient authPB.AuthServiceClient, userClient userPB.UserServiceClient) *OAuthHandler {
+	return &OAuthHandler{
+		authClient: authClient,
+		userClient: userClient,
+	}
+}
+
+// Authorize handles an authorization request of the authorization code flow
+// for the signed in user. The gateway has no login or consent pages of its
+// own: the app that shows them calls this endpoint with the bearer token of
+// the user and either asks for consent, or sends the browser to redirect_to.
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var input AuthorizeInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	consent := authPB.Consent_CONSENT_UNSPECIFIED
+	switch input.Consent {
+	case "approve":
+		consent = authPB.Consent_CONSENT_GRANTED
+	case "deny":
+		consent = authPB.Consent_CONSENT_DENIED
+	}
+
+	res, err := h.authClient.Authorize(c.Request.Context(), &authPB.AuthorizeRequest{
+		UserId:              c.GetString(middleware.ContextUserID),
+		ClientId:            input.ClientID,
+		ResponseType:        input.ResponseType,
+		RedirectUri:         input.RedirectURI,
+		Scope:               input.Scope,
+		CodeChallenge:       input.CodeChallenge,
+		CodeChallengeMethod: input.CodeChallengeMethod,
+		Nonce:               input.Nonce,
+		Prompt:              input.Prompt,
+		Consent:             consent,
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		info := oauthErrorInfo(st)
+		switch {
+		case info != nil && info.Metadata["redirect"] == "true":
+			c.JSON(http.StatusOK, gin.H{"redirect_to": redirectURL(input.RedirectURI, url.Values{
+				"error":             {info.Reason},
+				"error_description": {st.Message()},
+			}, input.State)})
+		case info != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": info.Reason, "error_description": st.Message()})
+		case st.Code() == codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("auth service authorize error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if res.ConsentRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"consent_required": true,
+			"client_name":      res.ClientName,
+			"scope":            res.Scope,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectURL(input.RedirectURI, url.Values{
+		"code": {res.Code},
+	}, input.State)})
+}
+
+// Token handles a request to the OAuth token endpoint. Clients authenticate
+// with HTTP Basic authentication or with client_id and client_secret in the form.
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var input TokenInput
+	if err := c.ShouldBindWith(&input, binding.FormPost); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	clientID, clientSecret, basic, ok := clientCredentials(c, input.ClientID, input.ClientSecret)
+	if !ok {
+		return
+	}
+
+	res, err := h.authClient.Token(c.Request.Context(), &authPB.TokenRequest{
+		GrantType:    input.GrantType,
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+		Code:         input.Code,
+		RedirectUri:  input.RedirectURI,
+		CodeVerifier: input.CodeVerifier,
+		RefreshToken: input.RefreshToken,
+		Scope:        input.Scope,
+	})
+	if err != nil {
+		oauthClientError(c, "token", err, basic)
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:  res.AccessToken,
+		TokenType:    res.TokenType,
+		ExpiresIn:    res.ExpiresIn,
+		RefreshToken: res.RefreshToken,
+		Scope:        res.Scope,
+		IDToken:      res.IdToken,
+	})
+}
+
+// Introspect handles a token introspection request (RFC 7662) of a resource
+// server. Resource servers authenticate as confidential clients, like at the
+// token endpoint. Tokens that are not active are reported with active=false.
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	var input IntrospectInput
+	if err := c.ShouldBindWith(&input, binding.FormPost); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	clientID, clientSecret, basic, ok := clientCredentials(c, input.ClientID, input.ClientSecret)
+	if !ok {
+		return
+	}
+
+	res, err := h.authClient.IntrospectToken(c.Request.Context(), &authPB.IntrospectTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+		Token:        input.Token,
+	})
+	if err != nil {
+		oauthClientError(c, "introspect token", err, basic)
+		return
+	}
+
+	c.JSON(http.StatusOK, IntrospectResponse{
+		Active:    res.Active,
+		Sub:       res.Sub,
+		Exp:       res.Exp,
+		Iat:       res.Iat,
+		Scope:     res.Scope,
+		ClientID:  res.ClientId,
+		TokenType: res.TokenType,
+	})
+}
+
+// OpenIDConfiguration serves the OpenID Connect discovery document. The
+// endpoints are relative to the issuer, which is the public URL of the gateway.
+func (h *OAuthHandler) OpenIDConfiguration(c *gin.Context) {
+	res, err := h.authClient.GetOpenIDConfiguration(c.Request.Context(), &authPB.GetOpenIDConfigurationRequest{})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.FailedPrecondition:
+			c.JSON(http.StatusNotFound, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service get OpenID configuration error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, OpenIDConfiguration{
+		Issuer:                            res.Issuer,
+		AuthorizationEndpoint:             res.Issuer + "/oauth/authorize",
+		TokenEndpoint:                     res.Issuer + "/oauth/token",
+		UserInfoEndpoint:                  res.Issuer + "/userinfo",
+		IntrospectionEndpoint:             res.Issuer + "/oauth/introspect",
+		JWKSURI:                           res.Issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{scopeOpenID, scopeEmail, scopeProfile},
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  res.IdTokenSigningAlgValues,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "nonce", "email", "email_verified", "name"},
+
+		IntrospectionEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+	})
+}
+
+// UserInfo returns the claims about the user of an access token issued for
+// the openid scope. The email claims come from the auth service and require
+// the email scope; the name comes from the user service and requires the
+// profile scope. The token is read from the Authorization header, or from the
+// access_token form field of a POST request.
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	token := middleware.BearerToken(c)
+	if token == "" && c.Request.Method == http.MethodPost {
+		token = c.PostForm("access_token")
+	}
+	if token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="userinfo"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_request", "error_description": "missing access token"})
+		return
+	}
+
+	res, err := h.authClient.UserInfo(c.Request.Context(), &authPB.UserInfoRequest{AccessToken: token})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.Unauthenticated:
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, st.Message()))
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": st.Message()})
+		case codes.PermissionDenied:
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "error_description": st.Message()})
+		default:
+			log.Printf("auth service user info error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	claims := gin.H{"sub": res.Sub}
+	scopes := strings.Fields(res.Scope)
+	if slices.Contains(scopes, scopeEmail) {
+		claims["email"] = res.Email
+		claims["email_verified"] = res.EmailVerified
+	}
+	if slices.Contains(scopes, scopeProfile) {
+		user, err := h.userClient.GetUser(c.Request.Context(), &userPB.GetUserRequest{UserId: res.Sub})
+		switch {
+		case err == nil:
+			claims["name"] = user.GetUser().GetFullName()
+		case status.Code(err) != codes.NotFound:
+			log.Printf("user service get user error: %v", err)
+		}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, claims)
+}
+
+// clientCredentials returns the credentials of a client from HTTP Basic
+// authentication, or else the given form fields. It writes an error response
+// and returns false if the Authorization header is malformed.
+func clientCredentials(c *gin.Context, formID, formSecret string) (clientID, clientSecret string, basic, ok bool) {
+	clientID, clientSecret, basic = c.Request.BasicAuth()
+	if !basic {
+		return formID, formSecret, false, true
+	}
+
+	// Credentials in the Authorization header are form encoded (RFC 6749 section 2.3.1).
+	var idErr, secretErr error
+	clientID, idErr = url.QueryUnescape(clientID)
+	clientSecret, secretErr = url.QueryUnescape(clientSecret)
+	if idErr != nil || secretErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "malformed client credentials"})
+		return "", "", true, false
+	}
+	return clientID, clientSecret, true, true
+}
+
+// oauthClientError writes the error response of an endpoint that
+// authenticates clients. Failed client authentication is a 401, with a Basic
+// challenge if the client tried HTTP Basic authentication.
+func oauthClientError(c *gin.Context, op string, err error, basic bool) {
+	st, _ := status.FromError(err)
+	info := oauthErrorInfo(st)
+	switch {
+	case info != nil && st.Code() == codes.Unauthenticated:
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": info.Reason, "error_description": st.Message()})
+	case info != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": info.Reason, "error_description": st.Message()})
+	default:
+		log.Printf("auth service %s error: %v", op, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+	}
+}
+
+// oauthErrorInfo returns the ErrorInfo of a status that carries an OAuth error code, if any.
+func oauthErrorInfo(st *status.Status) *errdetails.ErrorInfo {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == oauthErrorDomain {
+			return info
+		}
+	}
+	return nil
+}
+
+// redirectURL adds params and, when set, state to the query of a redirect URI.
+func redirectURL(redirectURI string, params url.Values, state string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
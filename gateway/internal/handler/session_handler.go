@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LoginHistoryInput is a struct that contains the query parameters for the LoginHistory method.
+type LoginHistoryInput struct {
+	PageSize  int32  `form:"page_size" binding:"omitempty,min=1,max=100"`
+	PageToken string `form:"page_token"`
+}
+
+type SessionHandler struct {
+	authClient authPB.AuthServiceClient
+}
+
+func NewSessionHandler(authClient authPB.AuthServiceClient) *SessionHandler {
+	return &SessionHandler{
+		authClient: authClient,
+	}
+}
+
+// ListSessions returns the active sessions of the authenticated user.
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	res, err := h.authClient.ListSessions(c.Request.Context(), &authPB.ListSessionsRequest{
+		UserId: c.GetString(middleware.ContextUserID),
+	})
+	if err != nil {
+		log.Printf("auth service list sessions error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	sessions := make([]gin.H, 0, len(res.Sessions))
+	for _, session := range res.Sessions {
+		sessions = append(sessions, gin.H{
+			"id":           session.Id,
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IpAddress,
+			"created_at":   session.CreatedAt.AsTime(),
+			"last_used_at": session.LastUsedAt.AsTime(),
+			"expires_at":   session.ExpiresAt.AsTime(),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// LoginHistory returns a page of the login history of the authenticated user, newest first.
+func (h *SessionHandler) LoginHistory(c *gin.Context) {
+	var input LoginHistoryInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.authClient.GetLoginHistory(c.Request.Context(), &authPB.GetLoginHistoryRequest{
+		UserId:    c.GetString(middleware.ContextUserID),
+		PageSize:  input.PageSize,
+		PageToken: input.PageToken,
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service get login history error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	events := make([]gin.H, 0, len(res.Events))
+	for _, event := range res.Events {
+		response := gin.H{
+			"id":         event.Id,
+			"success":    event.Success,
+			"ip_address": event.IpAddress,
+			"user_agent": event.UserAgent,
+			"new_device": event.NewDevice,
+			"created_at": event.CreatedAt.AsTime(),
+		}
+		if event.Reason != "" {
+			response["reason"] = event.Reason
+		}
+		events = append(events, response)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"events":          events,
+		"next_page_token": res.NextPageToken,
+		"has_more":        res.NextPageToken != "",
+	})
+}
+
+// RevokeSession signs the authenticated user out of one of its sessions.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	if _, err := h.authClient.RevokeSession(c.Request.Context(), &authPB.RevokeSessionRequest{
+		UserId: c.GetString(middleware.ContextUserID),
+		Id:     c.Param("id"),
+	}); err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service revoke session error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	userPB "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserResponse is the JSON representation of a user.
+type UserResponse struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	FullName  string    `json:"full_name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpdateMeInput is a struct that contains the input fields for the UpdateMe method.
+// Only the fields present in the request body are updated.
+type UpdateMeInput struct {
+	FullName *string `json:"full_name" binding:"omitempty,min=1"`
+}
+
+// ListUsersInput is a struct that contains the query parameters of the ListUsers method.
+type ListUsersInput struct {
+	PageSize      int32      `form:"page_size" binding:"omitempty,min=1,max=100"`
+	PageToken     string     `form:"page_token"`
+	EmailPrefix   string     `form:"email_prefix"`
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	OrderBy       string     `form:"order_by" binding:"omitempty,oneof='created_at asc' 'created_at desc'"`
+}
+
+// ListUsersResponse is the JSON representation of a page of users.
+type ListUsersResponse struct {
+	Users         []UserResponse `json:"users"`
+	NextPageToken string         `json:"next_page_token"`
+	HasMore       bool           `json:"has_more"`
+}
+
+// DeleteMeInput is a struct that contains the input fields for the DeleteMe method.
+type DeleteMeInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type UserHandler struct {
+	authClient authPB.AuthServiceClient
+	userClient userPB.UserServiceClient
+}
+
+func NewUserHandler(authClient authPB.AuthServiceClient, userClient userPB.UserServiceClient) *UserHandler {
+	return &UserHandler{
+		authClient: authClient,
+		userClient: userClient,
+	}
+}
+
+// GetMe returns the profile of the authenticated user.
+func (h *UserHandler) GetMe(c *gin.Context) {
+	res, err := h.userClient.GetUser(c.Request.Context(), &userPB.GetUserRequest{
+		UserId: c.GetString(middleware.ContextUserID),
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("user service get user error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(res.User))
+}
+
+// UpdateMe updates the profile of the authenticated user.
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	var input UpdateMeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := &userPB.User{Id: c.GetString(middleware.ContextUserID)}
+	var paths []string
+	if input.FullName != nil {
+		user.FullName = *input.FullName
+		paths = append(paths, "full_name")
+	}
+	if len(paths) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	res, err := h.userClient.UpdateUser(c.Request.Context(), &userPB.UpdateUserRequest{
+		User:       user,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+	})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": st.Message()})
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("user service update user error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(res.User))
+}
+
+// DeleteMe deletes the account of the authenticated user after checking its password.
+// The auth service removes the credential right away and the profile eventually.
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	var input DeleteMeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authClient.DeleteAccount(c.Request.Context(), &authPB.DeleteAccountRequest{
+		UserId:   c.GetString(middleware.ContextUserID),
+		Password: input.Password,
+	}); err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.PermissionDenied:
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid password"})
+		case codes.NotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("auth service delete account error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUsers returns a page of users matching the query parameters.
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	var input ListUsersInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &userPB.ListUsersRequest{
+		PageSize:    input.PageSize,
+		PageToken:   input.PageToken,
+		EmailPrefix: input.EmailPrefix,
+		OrderBy:     input.OrderBy,
+	}
+	if input.CreatedAfter != nil {
+		req.CreatedAfter = timestamppb.New(*input.CreatedAfter)
+	}
+	if input.CreatedBefore != nil {
+		req.CreatedBefore = timestamppb.New(*input.CreatedBefore)
+	}
+
+	res, err := h.userClient.ListUsers(c.Request.Context(), req)
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": st.Message()})
+		default:
+			log.Printf("user service list users error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	users := make([]UserResponse, 0, len(res.Users))
+	for _, user := range res.Users {
+		users = append(users, newUserResponse(user))
+	}
+
+	c.JSON(http.StatusOK, ListUsersResponse{
+		Users:         users,
+		NextPageToken: res.NextPageToken,
+		HasMore:       res.NextPageToken != "",
+	})
+}
+
+func newUserResponse(user *userPB.User) UserResponse {
+	return UserResponse{
+		ID:        user.Id,
+		Email:     user.Email,
+		FullName:  user.FullName,
+		CreatedAt: user.CreatedAt.AsTime(),
+		UpdatedAt: user.UpdatedAt.AsTime(),
+	}
+}
@@ -0,0 +1,187 @@
+// Package idempotency stores the responses of requests made with an
+// Idempotency-Key so that retries can be answered without repeating them.
+//
+// Responses are kept in the memory of one gateway process. They are lost when
+// the gateway restarts and are not shared between gateway instances, so a
+// retry that reaches another instance, or arrives after a restart, runs the
+// request again.
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+	ErrStoreFull           = errors.New("too many idempotent requests in progress")
+)
+
+// Response is a stored response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// entry is the state of a key. done is closed once the request that holds the
+// key has finished; response is nil when that request is to be forgotten.
+// element is the position of a finished entry in the eviction order.
+type entry struct {
+	fingerprint string
+	done        chan struct{}
+	response    *Response
+	expiresAt   time.Time
+	element     *list.Element
+}
+
+// Store keeps responses in memory for the configured TTL. It holds at most
+// maxEntries keys; when it is full, the least recently used response is
+// evicted to make room for a new key.
+type Store struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	// finished holds the keys of finished entries, least recently used first.
+	finished *list.List
+}
+
+// NewStore creates a new Store.
+func NewStore(ttl time.Duration, maxEntries int) *Store {
+	return &Store{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*entry{},
+		finished:   list.New(),
+	}
+}
+
+// Begin claims key for a request with the given fingerprint. When the key was
+// used before, Begin waits for that request to finish and returns its
+// response. Otherwise it returns a nil response and the caller must call
+// Finish once the request is done.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	for {
+		s.mu.Lock()
+		e, ok := s.entries[key]
+		if !ok || e.expired(time.Now()) {
+			if ok {
+				s.remove(key, e)
+			}
+			if !s.makeRoom() {
+				s.mu.Unlock()
+				return nil, ErrStoreFull
+			}
+			s.entries[key] = &entry{
+				fingerprint: fingerprint,
+				done:        make(chan struct{}),
+			}
+			s.mu.Unlock()
+			return nil, nil
+		}
+		if e.element != nil {
+			s.finished.MoveToBack(e.element)
+		}
+		s.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, ErrFingerprintMismatch
+		}
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if e.response != nil {
+			return e.response, nil
+		}
+		// The previous request was forgotten; try to claim the key again.
+	}
+}
+
+// Finish releases key. A nil response forgets the key so that the next
+// request with it runs again.
+func (s *Store) Finish(key string, response *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.response != nil {
+		return
+	}
+
+	if response == nil {
+		delete(s.entries, key)
+	} else {
+		e.response = response
+		e.expiresAt = time.Now().Add(s.ttl)
+		e.element = s.finished.PushBack(key)
+	}
+	close(e.done)
+}
+
+// makeRoom evicts the least recently used response if the store is full. It
+// reports false if the store is full of requests that are still running.
+func (s *Store) makeRoom() bool {
+	if len(s.entries) < s.maxEntries {
+		return true
+	}
+	oldest := s.finished.Front()
+	if oldest == nil {
+		return false
+	}
+	key := oldest.Value.(string)
+	s.remove(key, s.entries[key])
+	return true
+}
+
+// remove deletes a finished entry.
+func (s *Store) remove(key string, e *entry) {
+	delete(s.entries, key)
+	if e.element != nil {
+		s.finished.Remove(e.element)
+	}
+}
+
+// Prune removes the expired responses.
+func (s *Store) Prune() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, e := range s.entries {
+		if e.expired(now) {
+			s.remove(key, e)
+		}
+	}
+}
+
+// Run prunes expired responses every interval until ctx is cancelled.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Prune()
+		}
+	}
+}
+
+// expired reports whether a finished entry has outlived its TTL. Entries of
+// requests that are still running never expire.
+func (e *entry) expired(now time.Time) bool {
+	return e.response != nil && now.After(e.expiresAt)
+}
@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// finish claims key and stores a response for it.
+func finish(t *testing.T, s *Store, key string) {
+	t.Helper()
+
+	stored, err := s.Begin(context.Background(), key, "fingerprint")
+	if err != nil || stored != nil {
+		t.Fatalf("Begin(%s) = %v, %v, want to claim the key", key, stored, err)
+	}
+	s.Finish(key, &Response{Status: http.StatusCreated})
+}
+
+// replayed reports whether key has a stored response, without claiming it otherwise.
+func replayed(t *testing.T, s *Store, key string) bool {
+	t.Helper()
+
+	stored, err := s.Begin(context.Background(), key, "fingerprint")
+	if err != nil {
+		t.Fatalf("Begin(%s): %v", key, err)
+	}
+	if stored == nil {
+		s.Finish(key, nil)
+	}
+	return stored != nil
+}
+
+func TestStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewStore(time.Hour, 2)
+	finish(t, s, "a")
+	finish(t, s, "b")
+
+	// Replaying a makes b the least recently used response.
+	if !replayed(t, s, "a") {
+		t.Fatal("response of a was not stored")
+	}
+	finish(t, s, "c")
+
+	if got := len(s.entries); got != 2 {
+		t.Errorf("store holds %d keys, want 2", got)
+	}
+	if !replayed(t, s, "a") || !replayed(t, s, "c") {
+		t.Error("recently used responses were evicted")
+	}
+	if replayed(t, s, "b") {
+		t.Error("least recently used response was not evicted")
+	}
+}
+
+func TestStoreFullOfRunningRequests(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(time.Hour, 2)
+	for _, key := range []string{"a", "b"} {
+		if _, err := s.Begin(ctx, key, "fingerprint"); err != nil {
+			t.Fatalf("Begin(%s): %v", key, err)
+		}
+	}
+
+	if _, err := s.Begin(ctx, "c", "fingerprint"); !errors.Is(err, ErrStoreFull) {
+		t.Fatalf("Begin while full of running requests error = %v, want %v", err, ErrStoreFull)
+	}
+
+	// Once a request finishes, its response can be evicted for a new key.
+	s.Finish("a", &Response{Status: http.StatusCreated})
+	if _, err := s.Begin(ctx, "c", "fingerprint"); err != nil {
+		t.Errorf("Begin after a request finished: %v", err)
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	// Responses expire as soon as they are stored.
+	s := NewStore(-time.Minute, 10)
+	finish(t, s, "a")
+
+	s.Prune()
+	if len(s.entries) != 0 || s.finished.Len() != 0 {
+		t.Errorf("store holds %d keys and %d finished after pruning, want none", len(s.entries), s.finished.Len())
+	}
+	if replayed(t, s, "a") {
+		t.Error("expired response was replayed")
+	}
+}
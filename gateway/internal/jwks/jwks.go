@@ -0,0 +1,203 @@
+// Package jwks verifies access tokens locally with the public keys published by the auth service.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const refreshCooldown = time.Minute
+
+var (
+	ErrKeyNotFound  = errors.New("signing key not found")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrClientToken  = errors.New("token was issued to an OAuth client")
+)
+
+// Claims are the claims of a locally verified access token.
+type Claims struct {
+	UserID string
+	Email  string
+}
+
+// KeySet caches the public keys of the auth service and checks verified
+// tokens against the revocations it published.
+type KeySet struct {
+	client      authPB.AuthServiceClient
+	revocations *Revocations
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+// NewKeySet creates a new KeySet backed by the auth service and the given revocations.
+func NewKeySet(client authPB.AuthServiceClient, revocations *Revocations) *KeySet {
+	return &KeySet{
+		client:      client,
+		revocations: revocations,
+		keys:        map[string]crypto.PublicKey{},
+	}
+}
+
+// Refresh replaces the cached keys with the ones currently published by the auth service.
+func (k *KeySet) Refresh(ctx context.Context) error {
+	res, err := k.client.GetJWKS(ctx, &authPB.GetJWKSRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(res.Keys))
+	for _, jwk := range res.Keys {
+		key, err := parseJWK(jwk)
+		if err != nil {
+			log.Printf("skipping JWK %s: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.lastRefresh = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Run refreshes the keys every interval until ctx is cancelled.
+func (k *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Refresh(ctx); err != nil {
+				log.Printf("refresh JWKS error: %v", err)
+			}
+		}
+	}
+}
+
+// Verify checks the signature, expiry and revocation of an access token. It
+// returns ErrKeyNotFound when the token was signed by a key that is not known
+// yet, and ErrRevocationsStale when the revocations could not be synced
+// recently, so callers can fall back to asking the auth service.
+func (k *KeySet) Verify(ctx context.Context, token string) (*Claims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "EdDSA"}))
+	parsedToken, err := parser.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return k.lookup(ctx, kid)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrKeyNotFound):
+			return nil, ErrKeyNotFound
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || !parsedToken.Valid {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["exp"].(float64); !ok {
+		return nil, ErrInvalidToken
+	}
+	// Tokens of OAuth clients only grant their scope, which the API does not check.
+	if _, ok := claims["client_id"]; ok {
+		return nil, ErrClientToken
+	}
+
+	userID, _ := claims["user_id"].(string)
+	email, _ := claims["email"].(string)
+	if userID == "" || email == "" {
+		return nil, ErrInvalidToken
+	}
+	if err := k.revocations.check(claims); err != nil {
+		return nil, err
+	}
+
+	return &Claims{UserID: userID, Email: email}, nil
+}
+
+// lookup returns the key with the given ID, refreshing the cache once if it is unknown.
+func (k *KeySet) lookup(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if kid == "" {
+		return nil, ErrKeyNotFound
+	}
+
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	stale := time.Since(k.lastRefresh) > refreshCooldown
+	k.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, ErrKeyNotFound
+	}
+
+	if err := k.Refresh(ctx); err != nil {
+		log.Printf("refresh JWKS error: %v", err)
+		return nil, ErrKeyNotFound
+	}
+
+	k.mu.RLock()
+	key, ok = k.keys[kid]
+	k.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// parseJWK converts a JSON Web Key into a public key.
+func parseJWK(jwk *authPB.JSONWebKey) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
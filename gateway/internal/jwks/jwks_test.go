@@ -0,0 +1,195 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testKid = "test-key"
+
+// fakeAuthClient publishes one Ed25519 key and a fixed set of revocations,
+// and records the sessions reported as used.
+type fakeAuthClient struct {
+	authPB.AuthServiceClient
+
+	public ed25519.PublicKey
+
+	mu          sync.Mutex
+	revocations *authPB.ListRevocationsResponse
+	requests    []*authPB.ListRevocationsRequest
+	touched     [][]string
+	// touchErr is returned by TouchSessions when set.
+	touchErr error
+}
+
+func (c *fakeAuthClient) GetJWKS(context.Context, *authPB.GetJWKSRequest, ...grpc.CallOption) (*authPB.GetJWKSResponse, error) {
+	return &authPB.GetJWKSResponse{Keys: []*authPB.JSONWebKey{{
+		Kty: "OKP",
+		Kid: testKid,
+		Use: "sig",
+		Alg: "EdDSA",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(c.public),
+	}}}, nil
+}
+
+func (c *fakeAuthClient) ListRevocations(_ context.Context, req *authPB.ListRevocationsRequest, _ ...grpc.CallOption) (*authPB.ListRevocationsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, req)
+	res := &authPB.ListRevocationsResponse{AsOf: timestamppb.Now()}
+	if c.revocations != nil {
+		res.Tokens = c.revocations.Tokens
+		res.Users = c.revocations.Users
+		res.SessionIds = c.revocations.SessionIds
+	}
+	return res, nil
+}
+
+func (c *fakeAuthClient) TouchSessions(_ context.Context, req *authPB.TouchSessionsRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.touchErr != nil {
+		return nil, c.touchErr
+	}
+	c.touched = append(c.touched, req.Ids)
+	return &emptypb.Empty{}, nil
+}
+
+// newTestKeySet returns a key set with synced revocations and the key that signs its tokens.
+func newTestKeySet(t *testing.T, revocations *authPB.ListRevocationsResponse) (*KeySet, ed25519.PrivateKey) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	client := &fakeAuthClient{public: public, revocations: revocations}
+
+	r := NewRevocations(client, time.Minute)
+	if err := r.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	return NewKeySet(client, r), private
+}
+
+// userClaims returns the claims of a first-party token issued now.
+func userClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"jti":     "token-1",
+		"user_id": "user-1",
+		"email":   "alice@example.com",
+		"sid":     "session-1",
+		"iat":     float64(now.UnixMilli()) / 1000,
+		"exp":     now.Add(time.Minute).Unix(),
+	}
+}
+
+func signToken(t *testing.T, private ed25519.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(private)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// kid is the key ID in the header; testKid when empty.
+		kid    string
+		noKid  bool
+		claims func(c jwt.MapClaims)
+		// token replaces the signed token when it is set.
+		token   func(t *testing.T, signed string) string
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: ErrTokenExpired},
+		{name: "missing exp", claims: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: ErrInvalidToken},
+		{name: "unknown key", kid: "other-key", wantErr: ErrKeyNotFound},
+		{name: "missing kid", noKid: true, wantErr: ErrKeyNotFound},
+		{name: "OAuth client token", claims: func(c jwt.MapClaims) { c["client_id"] = "client-1"; c["scope"] = "openid" }, wantErr: ErrClientToken},
+		{
+			name:    "client credentials token",
+			claims:  func(c jwt.MapClaims) { delete(c, "user_id"); delete(c, "email"); c["client_id"] = "client-1" },
+			wantErr: ErrClientToken,
+		},
+		{name: "missing user_id", claims: func(c jwt.MapClaims) { delete(c, "user_id") }, wantErr: ErrInvalidToken},
+		{name: "missing jti", claims: func(c jwt.MapClaims) { delete(c, "jti") }, wantErr: ErrInvalidToken},
+		{
+			name: "tampered signature",
+			token: func(t *testing.T, signed string) string {
+				other := signToken(t, mustKey(t), testKid, userClaims())
+				return signed[:len(signed)-10] + other[len(other)-10:]
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "HMAC signed",
+			token: func(t *testing.T, _ string) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, userClaims())
+				token.Header["kid"] = testKid
+				signed, _ := token.SignedString([]byte("secret"))
+				return signed
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, private := newTestKeySet(t, nil)
+
+			claims := userClaims()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			kid := testKid
+			if tt.kid != "" || tt.noKid {
+				kid = tt.kid
+			}
+			token := signToken(t, private, kid, claims)
+			if tt.token != nil {
+				token = tt.token(t, token)
+			}
+
+			got, err := k.Verify(context.Background(), token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.UserID != "user-1" || got.Email != "alice@example.com") {
+				t.Errorf("Verify = %+v, want user-1 and alice@example.com", got)
+			}
+		})
+	}
+}
+
+func mustKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return private
+}
@@ -0,0 +1,194 @@
+package jwks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// fullSyncInterval is how often the revocations are fetched from scratch,
+	// which drops the ones that can no longer affect an unexpired token.
+	fullSyncInterval = 10 * time.Minute
+	// staleSyncs is how many sync intervals may pass without a successful sync
+	// before the cache is no longer trusted.
+	staleSyncs = 3
+)
+
+var (
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrRevocationsStale is returned when the revocations could not be synced
+	// recently, so callers must ask the auth service instead.
+	ErrRevocationsStale = errors.New("revocations are not up to date")
+)
+
+// Revocations caches the revocations published by the auth service, so that
+// locally verified tokens that were revoked are rejected within a sync
+// interval. It also reports the sessions of those tokens as used, which
+// VerifyToken would otherwise have recorded.
+type Revocations struct {
+	client   authPB.AuthServiceClient
+	interval time.Duration
+
+	mu         sync.RWMutex
+	tokens     map[string]time.Time
+	users      map[string]time.Time
+	sessions   map[string]struct{}
+	asOf       time.Time
+	syncedAt   time.Time
+	fullSyncAt time.Time
+
+	usedMu sync.Mutex
+	used   map[string]struct{}
+}
+
+// NewRevocations creates a new Revocations backed by the auth service and synced every interval.
+func NewRevocations(client authPB.AuthServiceClient, interval time.Duration) *Revocations {
+	return &Revocations{
+		client:   client,
+		interval: interval,
+		tokens:   map[string]time.Time{},
+		users:    map[string]time.Time{},
+		sessions: map[string]struct{}{},
+		used:     map[string]struct{}{},
+	}
+}
+
+// Sync reports the sessions used since the previous sync, then fetches the
+// revocations made since then, or all of them when the last full sync is
+// older than fullSyncInterval.
+func (r *Revocations) Sync(ctx context.Context) error {
+	r.touchSessions(ctx)
+
+	r.mu.RLock()
+	full := time.Since(r.fullSyncAt) >= fullSyncInterval
+	since := r.asOf
+	r.mu.RUnlock()
+
+	req := &authPB.ListRevocationsRequest{}
+	if !full {
+		req.Since = timestamppb.New(since)
+	}
+	res, err := r.client.ListRevocations(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to list revocations: %w", err)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if full {
+		r.tokens = make(map[string]time.Time, len(res.Tokens))
+		r.users = make(map[string]time.Time, len(res.Users))
+		r.sessions = make(map[string]struct{}, len(res.SessionIds))
+		r.fullSyncAt = now
+	}
+	for _, token := range res.Tokens {
+		r.tokens[token.Jti] = token.ExpiresAt.AsTime()
+	}
+	for _, user := range res.Users {
+		if revokedBefore := user.RevokedBefore.AsTime(); revokedBefore.After(r.users[user.UserId]) {
+			r.users[user.UserId] = revokedBefore
+		}
+	}
+	for _, id := range res.SessionIds {
+		r.sessions[id] = struct{}{}
+	}
+	r.asOf = res.AsOf.AsTime()
+	r.syncedAt = now
+
+	return nil
+}
+
+// touchSessions reports the sessions used since the previous sync. Sessions
+// that could not be reported are kept for the next sync.
+func (r *Revocations) touchSessions(ctx context.Context) {
+	r.usedMu.Lock()
+	used := r.used
+	r.used = map[string]struct{}{}
+	r.usedMu.Unlock()
+	if len(used) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(used))
+	for id := range used {
+		ids = append(ids, id)
+	}
+	if _, err := r.client.TouchSessions(ctx, &authPB.TouchSessionsRequest{Ids: ids}); err != nil {
+		log.Printf("touch sessions error: %v", err)
+		r.usedMu.Lock()
+		for _, id := range ids {
+			r.used[id] = struct{}{}
+		}
+		r.usedMu.Unlock()
+	}
+}
+
+// Run syncs the revocations every interval until ctx is cancelled.
+func (r *Revocations) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Sync(ctx); err != nil {
+				log.Printf("sync revocations error: %v", err)
+			}
+		}
+	}
+}
+
+// check rejects tokens that were revoked individually, with their session or
+// by a per-user cutoff, like the auth service does. The session of a token
+// that passes is recorded as used.
+func (r *Revocations) check(claims jwt.MapClaims) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if time.Since(r.syncedAt) > staleSyncs*r.interval {
+		return ErrRevocationsStale
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return ErrInvalidToken
+	}
+	if _, revoked := r.tokens[jti]; revoked {
+		return ErrTokenRevoked
+	}
+	sid, _ := claims["sid"].(string)
+	if _, revoked := r.sessions[sid]; sid != "" && revoked {
+		return ErrTokenRevoked
+	}
+
+	userID, _ := claims["user_id"].(string)
+	if revokedBefore, ok := r.users[userID]; ok {
+		iat, ok := claims["iat"].(float64)
+		if !ok {
+			return ErrInvalidToken
+		}
+		if !time.UnixMilli(int64(math.Round(iat * 1000))).After(revokedBefore) {
+			return ErrTokenRevoked
+		}
+	}
+
+	if sid != "" {
+		r.usedMu.Lock()
+		r.used[sid] = struct{}{}
+		r.usedMu.Unlock()
+	}
+	return nil
+}
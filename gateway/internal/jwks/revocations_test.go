@@ -0,0 +1,182 @@
+package jwks
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestVerifyRevocations(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		revocations *authPB.ListRevocationsResponse
+		claims      func(c jwt.MapClaims)
+		wantErr     error
+	}{
+		{name: "nothing revoked"},
+		{
+			name:        "revoked token",
+			revocations: &authPB.ListRevocationsResponse{Tokens: []*authPB.RevokedToken{{Jti: "token-1", ExpiresAt: timestamppb.New(now.Add(time.Minute))}}},
+			wantErr:     ErrTokenRevoked,
+		},
+		{
+			name:        "other token revoked",
+			revocations: &authPB.ListRevocationsResponse{Tokens: []*authPB.RevokedToken{{Jti: "token-2", ExpiresAt: timestamppb.New(now.Add(time.Minute))}}},
+		},
+		{
+			name:        "revoked session",
+			revocations: &authPB.ListRevocationsResponse{SessionIds: []string{"session-1"}},
+			wantErr:     ErrTokenRevoked,
+		},
+		{
+			name:        "token issued before the user cutoff",
+			revocations: &authPB.ListRevocationsResponse{Users: []*authPB.UserRevocation{{UserId: "user-1", RevokedBefore: timestamppb.New(now.Add(time.Second))}}},
+			wantErr:     ErrTokenRevoked,
+		},
+		{
+			name:        "token issued after the user cutoff",
+			revocations: &authPB.ListRevocationsResponse{Users: []*authPB.UserRevocation{{UserId: "user-1", RevokedBefore: timestamppb.New(now.Add(-time.Second))}}},
+		},
+		{
+			name:        "cutoff of another user",
+			revocations: &authPB.ListRevocationsResponse{Users: []*authPB.UserRevocation{{UserId: "user-2", RevokedBefore: timestamppb.New(now.Add(time.Second))}}},
+		},
+		{
+			name:        "missing iat under a user cutoff",
+			revocations: &authPB.ListRevocationsResponse{Users: []*authPB.UserRevocation{{UserId: "user-1", RevokedBefore: timestamppb.New(now.Add(-time.Second))}}},
+			claims:      func(c jwt.MapClaims) { delete(c, "iat") },
+			wantErr:     ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, private := newTestKeySet(t, tt.revocations)
+
+			claims := userClaims()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			if _, err := k.Verify(context.Background(), signToken(t, private, testKid, claims)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyStaleRevocations(t *testing.T) {
+	k, private := newTestKeySet(t, nil)
+	token := signToken(t, private, testKid, userClaims())
+
+	k.revocations.mu.Lock()
+	k.revocations.syncedAt = time.Now().Add(-staleSyncs*k.revocations.interval - time.Second)
+	k.revocations.mu.Unlock()
+
+	if _, err := k.Verify(context.Background(), token); !errors.Is(err, ErrRevocationsStale) {
+		t.Errorf("Verify with stale revocations error = %v, want %v", err, ErrRevocationsStale)
+	}
+}
+
+func TestRevocationsSync(t *testing.T) {
+	ctx := context.Background()
+	expiresAt := timestamppb.New(time.Now().Add(time.Minute))
+	client := &fakeAuthClient{revocations: &authPB.ListRevocationsResponse{
+		Tokens: []*authPB.RevokedToken{{Jti: "token-1", ExpiresAt: expiresAt}},
+	}}
+	r := NewRevocations(client, time.Minute)
+
+	if err := r.Sync(ctx); err != nil {
+		t.Fatalf("first Sync: %v", err)
+	}
+	if client.requests[0].Since != nil {
+		t.Errorf("first sync requested revocations since %v, want all of them", client.requests[0].Since.AsTime())
+	}
+	asOf := r.asOf
+
+	client.revocations = &authPB.ListRevocationsResponse{
+		Tokens: []*authPB.RevokedToken{{Jti: "token-2", ExpiresAt: expiresAt}},
+	}
+	if err := r.Sync(ctx); err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+	if since := client.requests[1].Since; since == nil || !since.AsTime().Equal(asOf) {
+		t.Errorf("second sync requested revocations since %v, want %v", since, asOf)
+	}
+	for _, jti := range []string{"token-1", "token-2"} {
+		if _, ok := r.tokens[jti]; !ok {
+			t.Errorf("%s is not revoked after an incremental sync", jti)
+		}
+	}
+
+	// A full sync drops revocations the auth service no longer lists.
+	r.fullSyncAt = time.Now().Add(-fullSyncInterval)
+	if err := r.Sync(ctx); err != nil {
+		t.Fatalf("third Sync: %v", err)
+	}
+	if client.requests[2].Since != nil {
+		t.Errorf("full sync requested revocations since %v, want all of them", client.requests[2].Since.AsTime())
+	}
+	if _, ok := r.tokens["token-1"]; ok {
+		t.Error("token-1 is still revoked after a full sync that did not list it")
+	}
+	if _, ok := r.tokens["token-2"]; !ok {
+		t.Error("token-2 is not revoked after a full sync")
+	}
+}
+
+func TestRevocationsTouchSessions(t *testing.T) {
+	ctx := context.Background()
+	k, private := newTestKeySet(t, &authPB.ListRevocationsResponse{SessionIds: []string{"session-2"}})
+	client := k.revocations.client.(*fakeAuthClient)
+
+	verify := func(sid string, wantErr error) {
+		t.Helper()
+
+		claims := userClaims()
+		if sid == "" {
+			delete(claims, "sid")
+		} else {
+			claims["sid"] = sid
+		}
+		if _, err := k.Verify(ctx, signToken(t, private, testKid, claims)); !errors.Is(err, wantErr) {
+			t.Fatalf("Verify of a token of %q error = %v, want %v", sid, err, wantErr)
+		}
+	}
+	// Only the session of a token that passes is reported, once per sync.
+	verify("session-1", nil)
+	verify("session-1", nil)
+	verify("session-2", ErrTokenRevoked)
+	verify("", nil)
+
+	client.touchErr = errors.New("unavailable")
+	if err := k.revocations.Sync(ctx); err != nil {
+		t.Fatalf("Sync with TouchSessions failing: %v", err)
+	}
+	if len(client.touched) != 0 {
+		t.Fatalf("touched %v while TouchSessions was failing", client.touched)
+	}
+
+	// Sessions that could not be reported are kept for the next sync.
+	client.touchErr = nil
+	if err := k.revocations.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(client.touched) != 1 || !reflect.DeepEqual(client.touched[0], []string{"session-1"}) {
+		t.Errorf("touched %v, want [[session-1]]", client.touched)
+	}
+
+	if err := k.revocations.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(client.touched) != 1 {
+		t.Errorf("touched %v again without new uses", client.touched[1:])
+	}
+}
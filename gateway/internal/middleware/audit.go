@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+// ContextRequestID is the key under which the ID of the request is stored in the gin context.
+const ContextRequestID = "request_id"
+
+// RequestIDHeader is the header that carries the ID of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID matches the request IDs accepted from clients.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID assigns an ID to the request, reusing the X-Request-ID header of
+// the client when it is a valid ID. The ID is returned in the response and
+// forwarded to the backend services, so that their audit records can be
+// matched with the gateway's.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = audit.NewRequestID()
+		}
+
+		c.Set(ContextRequestID, requestID)
+		c.Header(RequestIDHeader, requestID)
+		ctx := metadata.AppendToOutgoingContext(c.Request.Context(), audit.RequestIDMetadataKey, requestID)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// Audit writes a record for every state-changing request, that is every
+// request other than GET, HEAD and OPTIONS, once it was handled. The target of
+// a record is the :id path parameter of the route, if any. It must run after
+// RequestID.
+func Audit(l *audit.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		operation := c.FullPath()
+		if operation == "" {
+			operation = c.Request.URL.Path
+		}
+		record := audit.Record{
+			Operation: c.Request.Method + " " + operation,
+			Actor:     c.GetString(ContextUserID),
+			Target:    c.Param("id"),
+			RequestID: c.GetString(ContextRequestID),
+			Outcome:   audit.OutcomeSuccess,
+			Code:      strconv.Itoa(c.Writer.Status()),
+		}
+		if c.Writer.Status() >= http.StatusBadRequest {
+			record.Outcome = audit.OutcomeFailure
+		}
+		if err := l.Append(record); err != nil {
+			log.Printf("failed to audit %s: %v", record.Operation, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{name: "client request ID", header: "req-123.abc_DEF", wantSame: true},
+		{name: "missing request ID", header: ""},
+		{name: "invalid request ID", header: "bad id\n"},
+		{name: "request ID too long", header: strings.Repeat("a", 65)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded []string
+			r := gin.New()
+			r.GET("/", RequestID(), func(c *gin.Context) {
+				md, _ := metadata.FromOutgoingContext(c.Request.Context())
+				forwarded = md.Get(audit.RequestIDMetadataKey)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			if (got == tt.header) != tt.wantSame || got == "" {
+				t.Errorf("request ID = %q, want the client's %v", got, tt.wantSame)
+			}
+			if len(forwarded) != 1 || forwarded[0] != got {
+				t.Errorf("forwarded request ID = %v, want %q", forwarded, got)
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		// want is nil when the request is not audited.
+		want *audit.Record
+	}{
+		{
+			name:   "state-changing request",
+			method: http.MethodPost, path: "/api/admin/users/user-2/roles", status: http.StatusNoContent,
+			want: &audit.Record{Operation: "POST /api/admin/users/:id/roles", Actor: "admin-1", Target: "user-2", Outcome: audit.OutcomeSuccess, Code: "204"},
+		},
+		{
+			name:   "failed request",
+			method: http.MethodPost, path: "/api/admin/users/user-2/roles", status: http.StatusForbidden,
+			want: &audit.Record{Operation: "POST /api/admin/users/:id/roles", Actor: "admin-1", Target: "user-2", Outcome: audit.OutcomeFailure, Code: "403"},
+		},
+		{name: "read-only request", method: http.MethodGet, path: "/api/admin/users/user-2/roles", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			l, err := audit.Open(path, "gateway")
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer l.Close()
+
+			r := gin.New()
+			r.Use(RequestID(), func(c *gin.Context) { c.Set(ContextUserID, "admin-1") }, Audit(l))
+			r.Handle(tt.method, "/api/admin/users/:id/roles", func(c *gin.Context) { c.Status(tt.status) })
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(RequestIDHeader, "request-1")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if tt.want == nil {
+				if len(b) != 0 {
+					t.Errorf("audited a read-only request: %s", b)
+				}
+				return
+			}
+
+			var got audit.Record
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("malformed record %q: %v", b, err)
+			}
+			if got.Operation != tt.want.Operation || got.Actor != tt.want.Actor || got.Target != tt.want.Target ||
+				got.Outcome != tt.want.Outcome || got.Code != tt.want.Code || got.RequestID != "request-1" || got.Service != "gateway" {
+				t.Errorf("record = %+v, want %+v with request-1", got, tt.want)
+			}
+		})
+	}
+}
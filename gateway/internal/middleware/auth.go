@@ -0,0 +1,154 @@
+// Package middleware provides gin middleware used by the gateway routes.
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/jwks"
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Keys under which the authenticated user is stored in the gin context.
+// ContextAPIKeyID and ContextAPIKeyScope are only set for requests
+// authenticated with an API key.
+const (
+	ContextUserID      = "user_id"
+	ContextEmail       = "email"
+	ContextAPIKeyID    = "api_key_id"
+	ContextAPIKeyScope = "api_key_scope"
+)
+
+// Authenticate validates the bearer token or API key of the request and stores
+// the user ID and email in the gin context. Tokens are verified locally when
+// keySet is not nil, knows the signing key and has recently synced the
+// revocations, otherwise through the auth service. API keys, sent as "Authorization: ApiKey <key>", are always
+// verified by the auth service. Access tokens issued to OAuth clients are
+// rejected, since they only grant the scope the user consented to.
+func Authenticate(authClient authPB.AuthServiceClient, keySet *jwks.KeySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := apiKey(c); key != "" {
+			authenticateAPIKey(c, authClient, key)
+			return
+		}
+
+		token := BearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+
+		if keySet != nil {
+			claims, err := keySet.Verify(c.Request.Context(), token)
+			switch {
+			case err == nil:
+				setUser(c, claims.UserID, claims.Email)
+				c.Next()
+				return
+			case errors.Is(err, jwks.ErrTokenExpired):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token expired"})
+				return
+			case errors.Is(err, jwks.ErrClientToken), errors.Is(err, jwks.ErrTokenRevoked):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			case !errors.Is(err, jwks.ErrKeyNotFound) && !errors.Is(err, jwks.ErrRevocationsStale):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				return
+			}
+		}
+
+		res, err := authClient.VerifyToken(c.Request.Context(), &authPB.VerifyTokenRequest{Token: token})
+		if err != nil {
+			st, _ := status.FromError(err)
+			switch st.Code() {
+			case codes.Unauthenticated:
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": st.Message()})
+			default:
+				log.Printf("auth service verify token error: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			}
+			return
+		}
+		if !res.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		setUser(c, res.UserId, res.Email)
+		c.Next()
+	}
+}
+
+// RequireBearer rejects requests authenticated with an API key. It must run
+// after Authenticate, on routes that manage credentials, so that a leaked key
+// cannot be used to mint more keys or take over the account.
+func RequireBearer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ContextAPIKeyID) != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this request"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticateAPIKey verifies an API key with the auth service and stores the
+// user and the key in the gin context.
+func authenticateAPIKey(c *gin.Context, authClient authPB.AuthServiceClient, key string) {
+	res, err := authClient.VerifyAPIKey(c.Request.Context(), &authPB.VerifyAPIKeyRequest{Key: key})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch st.Code() {
+		case codes.Unauthenticated:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": st.Message()})
+		case codes.FailedPrecondition:
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": st.Message()})
+		default:
+			log.Printf("auth service verify API key error: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	setUser(c, res.UserId, res.Email)
+	c.Set(ContextAPIKeyID, res.KeyId)
+	c.Set(ContextAPIKeyScope, res.Scope)
+	c.Next()
+}
+
+// apiKey extracts the API key from the Authorization header of the request.
+func apiKey(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	scheme, key, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "ApiKey") {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// BearerToken extracts the token from the Authorization header of the request.
+func BearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// setUser stores the authenticated user in the gin context and forwards it to
+// the backend services as the actor of their audit records.
+func setUser(c *gin.Context, userID, email string) {
+	c.Set(ContextUserID, userID)
+	c.Set(ContextEmail, email)
+
+	ctx := metadata.AppendToOutgoingContext(c.Request.Context(), audit.ActorMetadataKey, userID)
+	c.Request = c.Request.WithContext(ctx)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+// gRPC metadata keys under which the client IP and user agent are forwarded.
+const (
+	ClientIPMetadataKey        = "x-client-ip"
+	ClientUserAgentMetadataKey = "x-client-user-agent"
+)
+
+// ForwardClientInfo adds the IP and user agent of the client to the outgoing
+// gRPC metadata of the request context, so that backend services can see who
+// called them. The IP is only taken from X-Forwarded-For when the request
+// came through one of the trusted proxies of the router.
+func ForwardClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := metadata.AppendToOutgoingContext(c.Request.Context(),
+			ClientIPMetadataKey, c.ClientIP(),
+			ClientUserAgentMetadataKey, c.Request.UserAgent(),
+		)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestForwardClientInfo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		want           string
+	}{
+		{name: "no proxies trusted", remoteAddr: "198.51.100.1:1234", forwardedFor: "203.0.113.7", want: "198.51.100.1"},
+		{name: "trusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:1234", forwardedFor: "203.0.113.7", want: "203.0.113.7"},
+		{name: "untrusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "198.51.100.1:1234", forwardedFor: "203.0.113.7", want: "198.51.100.1"},
+		{name: "without forwarded header", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.trustedProxies); err != nil {
+				t.Fatalf("SetTrustedProxies: %v", err)
+			}
+
+			var got []string
+			r.GET("/", ForwardClientInfo(), func(c *gin.Context) {
+				md, _ := metadata.FromOutgoingContext(c.Request.Context())
+				got = md.Get(ClientIPMetadataKey)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("forwarded client IP = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/PakornBank/go-grpc-example/gateway/internal/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header that carries the idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses that were replayed from the store.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize bounds the request body that is buffered to fingerprint it.
+	maxIdempotentBodySize = 1 << 20
+)
+
+// Idempotency replays the stored response of a request made with the same
+// Idempotency-Key header and body, and rejects a key that is reused with a
+// different body. Requests with the same key run one at a time. Server errors
+// are not stored, so the request can be retried with the same key. Requests
+// without the header are passed through.
+//
+// Responses are only replayed by the gateway instance that stored them, and
+// only until it restarts; see package idempotency.
+func Idempotency(store *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body is too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the route so that one key cannot replay another endpoint.
+		key = c.Request.Method + " " + c.FullPath() + " " + key
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		stored, err := store.Begin(c.Request.Context(), key, fingerprint)
+		if err != nil {
+			if errors.Is(err, idempotency.ErrFingerprintMismatch) {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was used with a different request"})
+				return
+			}
+			if errors.Is(err, idempotency.ErrStoreFull) {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "too many idempotent requests in progress"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+			return
+		}
+		if stored != nil {
+			replay(c, stored)
+			return
+		}
+
+		var response *idempotency.Response
+		defer func() { store.Finish(key, response) }()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if status := recorder.Status(); status < http.StatusInternalServerError {
+			response = &idempotency.Response{
+				Status: status,
+				Header: recorder.Header().Clone(),
+				Body:   recorder.body.Bytes(),
+			}
+		}
+	}
+}
+
+// replay writes a stored response.
+func replay(c *gin.Context, response *idempotency.Response) {
+	for name, values := range response.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Writer.Header().Set(IdempotentReplayedHeader, "true")
+	c.Writer.WriteHeader(response.Status)
+	_, _ = c.Writer.Write(response.Body)
+	c.Abort()
+}
+
+// responseRecorder copies the response body while it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/gateway/internal/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+type idempotentRequest struct {
+	path string
+	key  string
+	body string
+}
+
+// newIdempotentRouter returns a router whose handlers answer with the number
+// of times they ran and the given status.
+func newIdempotentRouter(store *idempotency.Store, status int, calls *int) *gin.Engine {
+	r := gin.New()
+	r.Use(Idempotency(store))
+	handler := func(c *gin.Context) {
+		*calls++
+		c.Header("X-Call", strconv.Itoa(*calls))
+		c.String(status, "call %d", *calls)
+	}
+	r.POST("/api/orders", handler)
+	r.POST("/api/refunds", handler)
+	return r
+}
+
+func serveIdempotent(r *gin.Engine, req idempotentRequest) *httptest.ResponseRecorder {
+	httpReq := httptest.NewRequest(http.MethodPost, req.path, strings.NewReader(req.body))
+	if req.key != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, req.key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httpReq)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	first := idempotentRequest{path: "/api/orders", key: "key-1", body: `{"item":"book"}`}
+
+	tests := []struct {
+		name   string
+		status int
+		// second is sent after first.
+		second       idempotentRequest
+		wantStatus   int
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{name: "retry", status: http.StatusCreated, second: first, wantStatus: http.StatusCreated, wantReplayed: true, wantCalls: 1},
+		{name: "retry of a client error", status: http.StatusBadRequest, second: first, wantStatus: http.StatusBadRequest, wantReplayed: true, wantCalls: 1},
+		{name: "retry of a server error", status: http.StatusInternalServerError, second: first, wantStatus: http.StatusInternalServerError, wantCalls: 2},
+		{
+			name: "key reused with another body", status: http.StatusCreated,
+			second:     idempotentRequest{path: first.path, key: first.key, body: `{"item":"pen"}`},
+			wantStatus: http.StatusUnprocessableEntity, wantCalls: 1,
+		},
+		{
+			name: "key reused on another route", status: http.StatusCreated,
+			second:     idempotentRequest{path: "/api/refunds", key: first.key, body: first.body},
+			wantStatus: http.StatusCreated, wantCalls: 2,
+		},
+		{
+			name: "another key", status: http.StatusCreated,
+			second:     idempotentRequest{path: first.path, key: "key-2", body: first.body},
+			wantStatus: http.StatusCreated, wantCalls: 2,
+		},
+		{
+			name: "no key", status: http.StatusCreated,
+			second:     idempotentRequest{path: first.path, body: first.body},
+			wantStatus: http.StatusCreated, wantCalls: 2,
+		},
+		{
+			name: "key too long", status: http.StatusCreated,
+			second:     idempotentRequest{path: first.path, key: strings.Repeat("k", 256), body: first.body},
+			wantStatus: http.StatusBadRequest, wantCalls: 1,
+		},
+		{
+			name: "body too large", status: http.StatusCreated,
+			second:     idempotentRequest{path: first.path, key: "key-2", body: strings.Repeat("x", maxIdempotentBodySize+1)},
+			wantStatus: http.StatusRequestEntityTooLarge, wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			r := newIdempotentRouter(idempotency.NewStore(time.Hour, 100), tt.status, &calls)
+
+			original := serveIdempotent(r, first)
+			got := serveIdempotent(r, tt.second)
+
+			if got.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got.Code, tt.wantStatus)
+			}
+			if replayed := got.Header().Get(IdempotentReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && (got.Body.String() != original.Body.String() || got.Header().Get("X-Call") != "1") {
+				t.Errorf("replayed %q with X-Call %q, want the original response %q", got.Body, got.Header().Get("X-Call"), original.Body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyConcurrentRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	r := gin.New()
+	r.Use(Idempotency(idempotency.NewStore(time.Hour, 100)))
+	r.POST("/api/orders", func(c *gin.Context) {
+		calls++
+		close(started)
+		<-release
+		c.String(http.StatusCreated, "created")
+	})
+
+	req := idempotentRequest{path: "/api/orders", key: "key-1", body: `{"item":"book"}`}
+	var wg sync.WaitGroup
+	var original *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		original = serveIdempotent(r, req)
+	}()
+	<-started
+
+	// A retry that gives up while the first request runs is told it is in progress.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	httpReq := httptest.NewRequest(http.MethodPost, req.path, strings.NewReader(req.body)).WithContext(ctx)
+	httpReq.Header.Set(IdempotencyKeyHeader, req.key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httpReq)
+	if w.Code != http.StatusConflict {
+		t.Errorf("status of a retry while in progress = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	// A retry that waits gets the response of the first request.
+	var retry *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		retry = serveIdempotent(r, req)
+	}()
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if original.Code != http.StatusCreated || retry.Code != http.StatusCreated || retry.Body.String() != "created" ||
+		retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("responses = %d, %d %q, want the first response replayed", original.Code, retry.Code, retry.Body)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions checked by the gateway routes. They match the permissions
+// granted by the roles of the auth service.
+const (
+	PermissionUsersList      = "users:list"
+	PermissionRolesManage    = "roles:manage"
+	PermissionAccountsUnlock = "accounts:unlock"
+	PermissionOAuthClients   = "oauth_clients:manage"
+)
+
+// RequirePermission returns a middleware factory that rejects requests of users
+// lacking any of the given permissions. It must run after Authenticate.
+// Permissions are checked with the auth service on every request, so a revoked
+// role takes effect before the tokens that carry it expire. Requests made with
+// an API key that has a scope are also limited to the permissions in the scope.
+func RequirePermission(authClient authPB.AuthServiceClient) func(permissions ...string) gin.HandlerFunc {
+	return func(permissions ...string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			userID := c.GetString(ContextUserID)
+			if userID == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+				return
+			}
+
+			scope := strings.Fields(c.GetString(ContextAPIKeyScope))
+			for _, permission := range permissions {
+				if len(scope) > 0 && !slices.Contains(scope, permission) {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+					return
+				}
+
+				res, err := authClient.CheckPermission(c.Request.Context(), &authPB.CheckPermissionRequest{
+					UserId:     userID,
+					Permission: permission,
+				})
+				if err != nil {
+					log.Printf("auth service check permission error: %v", err)
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+					return
+				}
+				if !res.Allowed {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+					return
+				}
+			}
+
+			c.Next()
+		}
+	}
+}
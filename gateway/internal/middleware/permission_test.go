@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	authPB "github.com/PakornBank/go-grpc-example/auth/proto/auth/v1"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+)
+
+// fakePermissionClient grants the permissions in allowed to every user.
+type fakePermissionClient struct {
+	authPB.AuthServiceClient
+
+	allowed []string
+	err     error
+}
+
+func (c *fakePermissionClient) CheckPermission(_ context.Context, req *authPB.CheckPermissionRequest, _ ...grpc.CallOption) (*authPB.CheckPermissionResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &authPB.CheckPermissionResponse{Allowed: slices.Contains(c.allowed, req.Permission)}, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		userID      string
+		apiKeyScope string
+		allowed     []string
+		err         error
+		permissions []string
+		want        int
+	}{
+		{name: "allowed", userID: "user-1", allowed: []string{PermissionRolesManage}, permissions: []string{PermissionRolesManage}, want: http.StatusOK},
+		{name: "denied", userID: "user-1", allowed: []string{PermissionUsersList}, permissions: []string{PermissionRolesManage}, want: http.StatusForbidden},
+		{
+			name:   "one of several denied",
+			userID: "user-1", allowed: []string{PermissionUsersList},
+			permissions: []string{PermissionUsersList, PermissionRolesManage}, want: http.StatusForbidden,
+		},
+		{name: "not authenticated", allowed: []string{PermissionRolesManage}, permissions: []string{PermissionRolesManage}, want: http.StatusUnauthorized},
+		{
+			name:   "API key scope covers the permission",
+			userID: "user-1", apiKeyScope: PermissionUsersList + " " + PermissionRolesManage, allowed: []string{PermissionRolesManage},
+			permissions: []string{PermissionRolesManage}, want: http.StatusOK,
+		},
+		{
+			name:   "API key scope lacks the permission",
+			userID: "user-1", apiKeyScope: PermissionUsersList, allowed: []string{PermissionRolesManage},
+			permissions: []string{PermissionRolesManage}, want: http.StatusForbidden,
+		},
+		{
+			name:   "API key scope does not grant the permission",
+			userID: "user-1", apiKeyScope: PermissionRolesManage,
+			permissions: []string{PermissionRolesManage}, want: http.StatusForbidden,
+		},
+		{name: "auth service error", userID: "user-1", err: errors.New("unavailable"), permissions: []string{PermissionRolesManage}, want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakePermissionClient{allowed: tt.allowed, err: tt.err}
+
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				if tt.userID != "" {
+					c.Set(ContextUserID, tt.userID)
+				}
+				if tt.apiKeyScope != "" {
+					c.Set(ContextAPIKeyID, "key-1")
+					c.Set(ContextAPIKeyScope, tt.apiKeyScope)
+				}
+			}, RequirePermission(client)(tt.permissions...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireBearer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		apiKey bool
+		want   int
+	}{
+		{name: "bearer token", want: http.StatusOK},
+		{name: "API key", apiKey: true, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				c.Set(ContextUserID, "user-1")
+				if tt.apiKey {
+					c.Set(ContextAPIKeyID, "key-1")
+				}
+			}, RequireBearer(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
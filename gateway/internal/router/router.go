@@ -2,12 +2,23 @@ package router
 
 import (
 	"github.com/PakornBank/go-grpc-example/gateway/internal/di"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
 	"github.com/PakornBank/go-grpc-example/gateway/internal/routes"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes call functions to register routes on gin router.
+// Every request gets a request ID, and state-changing requests are audited.
 func SetupRoutes(router *gin.Engine, container *di.Container) {
-	group := router.Group("/api")
-	routes.RegisterAuthRoutes(group, container.AuthHandler)
+	router.Use(middleware.RequestID(), middleware.Audit(container.AuditLog))
+
+	group := router.Group("/api", middleware.ForwardClientInfo())
+	routes.RegisterAuthRoutes(group, container.AuthHandler, container.Authenticate, container.Idempotency)
+	routes.RegisterUserRoutes(group, container.UserHandler, container.Authenticate, container.RequirePermission)
+	routes.RegisterAPIKeyRoutes(group, container.APIKeyHandler, container.Authenticate)
+	routes.RegisterSessionRoutes(group, container.SessionHandler, container.Authenticate)
+	routes.RegisterAdminRoutes(group, container.AdminHandler, container.Authenticate, container.RequirePermission)
+
+	routes.RegisterOAuthRoutes(router, container.OAuthHandler, container.Authenticate)
+	routes.RegisterWellKnownRoutes(router, container.AuthHandler, container.OAuthHandler)
 }
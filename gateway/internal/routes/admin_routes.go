@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes registers the admin routes with the provided gin router group and handler.
+// Every route requires authentication and the permission of its group.
+func RegisterAdminRoutes(group *gin.RouterGroup, h *handler.AdminHandler, authenticate gin.HandlerFunc, requirePermission func(...string) gin.HandlerFunc) {
+	admin := group.Group("/admin", authenticate)
+
+	roles := admin.Group("/users/:id/roles", requirePermission(middleware.PermissionRolesManage))
+	{
+		roles.GET("", h.ListUserRoles)
+		roles.POST("", h.AssignRole)
+		roles.DELETE("/:role", h.RevokeRole)
+	}
+
+	accounts := admin.Group("/accounts", requirePermission(middleware.PermissionAccountsUnlock))
+	{
+		accounts.POST("/unlock", h.UnlockAccount)
+	}
+
+	oauthClients := admin.Group("/oauth/clients", requirePermission(middleware.PermissionOAuthClients))
+	{
+		oauthClients.POST("", h.CreateOAuthClient)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAPIKeyRoutes registers the API key routes with the provided gin router group and handler.
+// Every route requires authentication with a bearer token; API keys cannot manage API keys.
+func RegisterAPIKeyRoutes(group *gin.RouterGroup, h *handler.APIKeyHandler, authenticate gin.HandlerFunc) {
+	apiKeys := group.Group("/auth/api-keys", authenticate, middleware.RequireBearer())
+	{
+		apiKeys.POST("", h.CreateAPIKey)
+		apiKeys.GET("", h.ListAPIKeys)
+		apiKeys.DELETE("/:id", h.RevokeAPIKey)
+	}
+}
@@ -2,14 +2,28 @@ package routes
 
 import (
 	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 // RegisterAuthRoutes registers the auth routes with the provided gin router group and handler.
-func RegisterAuthRoutes(group *gin.RouterGroup, h *handler.AuthHandler) {
+// Registration honours the Idempotency-Key header through the idempotent middleware,
+// and changing the password or MFA settings requires authentication with a bearer token.
+func RegisterAuthRoutes(group *gin.RouterGroup, h *handler.AuthHandler, authenticate, idempotent gin.HandlerFunc) {
 	auth := group.Group("/auth")
 	{
-		auth.POST("/register", h.Register)
+		auth.POST("/register", idempotent, h.Register)
 		auth.POST("/login", h.Login)
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/logout", h.Logout)
+		auth.POST("/password-reset", h.RequestPasswordReset)
+		auth.POST("/password-reset/confirm", h.ConfirmPasswordReset)
+		auth.POST("/verify-email", h.VerifyEmail)
+		auth.POST("/verify-email/resend", h.ResendVerificationEmail)
+		auth.POST("/password", authenticate, middleware.RequireBearer(), h.ChangePassword)
+		auth.POST("/mfa/verify", h.VerifyMFA)
+		auth.POST("/mfa/enroll", authenticate, middleware.RequireBearer(), h.EnrollMFA)
+		auth.POST("/mfa/confirm", authenticate, middleware.RequireBearer(), h.ConfirmMFA)
+		auth.DELETE("/mfa", authenticate, middleware.RequireBearer(), h.DisableMFA)
 	}
 }
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterOAuthRoutes registers the OAuth authorization server and OpenID Connect routes with the provided gin router and handler.
+// The authorization endpoint acts for the signed in user and requires a first-party bearer token,
+// so that neither API keys nor the tokens of other clients can grant consent;
+// the token and introspection endpoints authenticate clients and the userinfo endpoint checks its own access token.
+func RegisterOAuthRoutes(router *gin.Engine, h *handler.OAuthHandler, authenticate gin.HandlerFunc) {
+	oauth := router.Group("/oauth")
+	{
+		oauth.GET("/authorize", authenticate, middleware.RequireBearer(), h.Authorize)
+		oauth.POST("/authorize", authenticate, middleware.RequireBearer(), h.Authorize)
+		oauth.POST("/token", h.Token)
+		oauth.POST("/introspect", h.Introspect)
+	}
+
+	router.GET("/userinfo", h.UserInfo)
+	router.POST("/userinfo", h.UserInfo)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterSessionRoutes registers the session routes with the provided gin router group and handler.
+// Every route, including the login history, requires authentication with a bearer token.
+func RegisterSessionRoutes(group *gin.RouterGroup, h *handler.SessionHandler, authenticate gin.HandlerFunc) {
+	sessions := group.Group("/auth/sessions", authenticate, middleware.RequireBearer())
+	{
+		sessions.GET("", h.ListSessions)
+		sessions.DELETE("/:id", h.RevokeSession)
+	}
+
+	group.GET("/auth/login-history", authenticate, middleware.RequireBearer(), h.LoginHistory)
+}
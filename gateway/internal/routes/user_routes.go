@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/PakornBank/go-grpc-example/gateway/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterUserRoutes registers the user routes with the provided gin router group and handler.
+// Every route requires authentication, listing every user requires the users:list permission,
+// and deleting the account requires a bearer session rather than an API key.
+func RegisterUserRoutes(group *gin.RouterGroup, h *handler.UserHandler, authenticate gin.HandlerFunc, requirePermission func(...string) gin.HandlerFunc) {
+	users := group.Group("/users", authenticate)
+	{
+		users.GET("", requirePermission(middleware.PermissionUsersList), h.ListUsers)
+		users.GET("/me", h.GetMe)
+		users.PATCH("/me", h.UpdateMe)
+		users.DELETE("/me", middleware.RequireBearer(), h.DeleteMe)
+	}
+}
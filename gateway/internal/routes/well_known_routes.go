@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/PakornBank/go-grpc-example/gateway/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWellKnownRoutes registers the /.well-known routes with the provided gin router and handlers.
+func RegisterWellKnownRoutes(router *gin.Engine, h *handler.AuthHandler, oauth *handler.OAuthHandler) {
+	wellKnown := router.Group("/.well-known")
+	{
+		wellKnown.GET("/jwks.json", h.JWKS)
+		wellKnown.GET("/openid-configuration", oauth.OpenIDConfiguration)
+	}
+}
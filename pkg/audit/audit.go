@@ -0,0 +1,166 @@
+// Package audit writes a tamper-evident audit trail of state-changing
+// operations. Records are appended to a local JSON Lines file, and each
+// record carries the hash of the record before it, so that editing, removing
+// or reordering records breaks the chain. Truncating the end of the file is
+// only detected when the hash of the last record is kept elsewhere.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of an audited operation.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// GenesisHash is the previous hash of the first record of a chain.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Record is an entry of the audit trail. Actor is the authenticated user who
+// made the request, and empty when there is none; Target is the user the
+// operation acted on, if known. Code is the gRPC code or HTTP status of the
+// response.
+type Record struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Service   string    `json:"service"`
+	Operation string    `json:"operation"`
+	Actor     string    `json:"actor"`
+	Target    string    `json:"target"`
+	RequestID string    `json:"request_id"`
+	Outcome   string    `json:"outcome"`
+	Code      string    `json:"code"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// computeHash returns the hash of a record, which covers every field except
+// the hash itself, including the hash of the previous record.
+func (r Record) computeHash() (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends records to an audit file. It is safe for concurrent use, but
+// only one Log may write to a file at a time.
+type Log struct {
+	service string
+
+	mu       sync.Mutex
+	file     *os.File
+	seq      uint64
+	lastHash string
+	now      func() time.Time
+}
+
+// Open opens the audit file at path for appending, creating it if needed,
+// and continues the chain of the records already in it. service names the
+// service in the records written through the Log.
+func Open(path, service string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	l := &Log{
+		service:  service,
+		file:     file,
+		lastHash: GenesisHash,
+		now:      time.Now,
+	}
+
+	last, err := lastRecord(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if last != nil {
+		l.seq = last.Seq
+		l.lastHash = last.Hash
+	}
+
+	return l, nil
+}
+
+// Append completes a record with the service, time, sequence number and
+// hashes, and writes it to the file. The file is synced before Append
+// returns.
+func (l *Log) Append(record Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.Seq = l.seq + 1
+	record.Service = l.service
+	record.Time = l.now().UTC()
+	record.PrevHash = l.lastHash
+
+	hash, err := record.computeHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash audit record: %w", err)
+	}
+	record.Hash = hash
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	if _, err := l.file.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.seq = record.Seq
+	l.lastHash = record.Hash
+	return nil
+}
+
+// Close closes the audit file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// lastRecord returns the last record of an audit file, or nil if it is empty.
+func lastRecord(r io.Reader) (*Record, error) {
+	var last []byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if last == nil {
+		return nil, nil
+	}
+
+	var record Record
+	if err := json.Unmarshal(last, &record); err != nil {
+		return nil, errors.New("audit log ends with a malformed record")
+	}
+	return &record, nil
+}
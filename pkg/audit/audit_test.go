@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openLog(t *testing.T, path string) *Log {
+	t.Helper()
+
+	l, err := Open(path, "auth")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// readRecords returns the records of an audit file.
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var record Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("malformed record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func verifyFile(t *testing.T, path string) (*VerifyResult, error) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	return Verify(f)
+}
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := openLog(t, path)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("ICT", 7*60*60))
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := l.Append(Record{Operation: "/auth.v1.AuthService/Login", Actor: "user-1", Seq: 99, Service: "other", PrevHash: "forged"}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	prevHash := GenesisHash
+	for i, record := range records {
+		// The Log sets these fields whatever the caller passed.
+		if record.Seq != uint64(i+1) || record.Service != "auth" || record.PrevHash != prevHash {
+			t.Errorf("record %d seq, service, prev hash = %d, %s, %s, want %d, auth, %s", i, record.Seq, record.Service, record.PrevHash, i+1, prevHash)
+		}
+		if !record.Time.Equal(now) || record.Time.Location() != time.UTC {
+			t.Errorf("record %d time = %v, want %v in UTC", i, record.Time, now)
+		}
+		prevHash = record.Hash
+	}
+}
+
+func TestOpenContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	first := openLog(t, path)
+	if err := first.Append(Record{Operation: "first"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	first.Close()
+
+	second := openLog(t, path)
+	if err := second.Append(Record{Operation: "second"}); err != nil {
+		t.Fatalf("Append after reopening: %v", err)
+	}
+
+	result, err := verifyFile(t, path)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if result.Records != 2 || result.LastSeq != 2 {
+		t.Errorf("Verify = %+v, want 2 records", result)
+	}
+}
+
+func TestOpenMalformedLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte("{\"seq\":1,\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := Open(path, "auth"); err == nil {
+		t.Error("Open of a file ending with a malformed record succeeded")
+	}
+}
+
+func TestAppendConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := openLog(t, path)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := l.Append(Record{Operation: fmt.Sprintf("op-%d", i)}); err != nil {
+				t.Errorf("Append: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result, err := verifyFile(t, path)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if result.Records != 50 {
+		t.Errorf("Verify counted %d records, want 50", result.Records)
+	}
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys under which the gateway forwards the authenticated user and
+// the ID of the request.
+const (
+	ActorMetadataKey     = "x-actor-id"
+	RequestIDMetadataKey = "x-request-id"
+)
+
+// TargetFunc returns the user an RPC acted on from its request and, when it
+// succeeded, its response. resp is nil for failed calls.
+type TargetFunc func(req, resp any) string
+
+// UnaryServerInterceptor returns an interceptor that writes a record for every
+// call of the given methods, keyed by full method name. A nil TargetFunc
+// uses Target. Calls of other methods are not audited.
+func UnaryServerInterceptor(l *Log, methods map[string]TargetFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		target, audited := methods[info.FullMethod]
+		if !audited {
+			return handler(ctx, req)
+		}
+		if target == nil {
+			target = Target
+		}
+
+		resp, err := handler(ctx, req)
+
+		record := Record{
+			Operation: info.FullMethod,
+			Actor:     metadataValue(ctx, ActorMetadataKey),
+			RequestID: metadataValue(ctx, RequestIDMetadataKey),
+			Outcome:   OutcomeSuccess,
+			Code:      status.Code(err).String(),
+		}
+		if record.RequestID == "" {
+			record.RequestID = NewRequestID()
+		}
+		if err != nil {
+			record.Outcome = OutcomeFailure
+			record.Target = target(req, nil)
+		} else {
+			record.Target = target(req, resp)
+		}
+		if auditErr := l.Append(record); auditErr != nil {
+			log.Printf("failed to audit %s: %v", info.FullMethod, auditErr)
+		}
+
+		return resp, err
+	}
+}
+
+// Target returns the user_id of the request or response of an RPC, or else
+// the email of its request.
+func Target(req, resp any) string {
+	for _, msg := range []any{req, resp} {
+		if m, ok := msg.(interface{ GetUserId() string }); ok && m.GetUserId() != "" {
+			return m.GetUserId()
+		}
+	}
+	if m, ok := req.(interface{ GetEmail() string }); ok {
+		return m.GetEmail()
+	}
+	return ""
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
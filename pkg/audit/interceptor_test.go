@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// userRequest has the getters of a generated request message.
+type userRequest struct {
+	userID string
+	email  string
+}
+
+func (r userRequest) GetUserId() string { return r.userID }
+func (r userRequest) GetEmail() string  { return r.email }
+
+const auditedMethod = "/auth.v1.AuthService/DeleteUser"
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		md       metadata.MD
+		req      any
+		resp     any
+		err      error
+		target   TargetFunc
+		want     *Record
+		wantSkip bool
+	}{
+		{
+			name:   "success",
+			method: auditedMethod,
+			md:     metadata.Pairs(ActorMetadataKey, "admin-1", RequestIDMetadataKey, "request-1"),
+			req:    userRequest{userID: "user-1"},
+			want:   &Record{Actor: "admin-1", Target: "user-1", RequestID: "request-1", Outcome: OutcomeSuccess, Code: "OK"},
+		},
+		{
+			name:   "failure",
+			method: auditedMethod,
+			req:    userRequest{userID: "user-1"},
+			err:    status.Error(codes.NotFound, "user not found"),
+			want:   &Record{Target: "user-1", Outcome: OutcomeFailure, Code: "NotFound"},
+		},
+		{
+			name:   "target from the response",
+			method: auditedMethod,
+			req:    userRequest{email: "alice@example.com"},
+			resp:   userRequest{userID: "user-1"},
+			want:   &Record{Target: "user-1", Outcome: OutcomeSuccess, Code: "OK"},
+		},
+		{
+			name:   "email target",
+			method: auditedMethod,
+			req:    userRequest{email: "alice@example.com"},
+			resp:   userRequest{},
+			want:   &Record{Target: "alice@example.com", Outcome: OutcomeSuccess, Code: "OK"},
+		},
+		{
+			name:   "custom target",
+			method: auditedMethod,
+			req:    userRequest{userID: "user-1"},
+			target: func(any, any) string { return "client-1" },
+			want:   &Record{Target: "client-1", Outcome: OutcomeSuccess, Code: "OK"},
+		},
+		{name: "method not audited", method: "/auth.v1.AuthService/VerifyToken", req: userRequest{userID: "user-1"}, wantSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			l := openLog(t, path)
+			interceptor := UnaryServerInterceptor(l, map[string]TargetFunc{auditedMethod: tt.target})
+
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			resp, err := interceptor(ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(context.Context, any) (any, error) {
+				return tt.resp, tt.err
+			})
+			if resp != tt.resp || err != tt.err {
+				t.Errorf("interceptor = %v, %v, want the handler result %v, %v", resp, err, tt.resp, tt.err)
+			}
+
+			result, verifyErr := verifyFile(t, path)
+			if verifyErr != nil {
+				t.Fatalf("Verify: %v", verifyErr)
+			}
+			if tt.wantSkip {
+				if result.Records != 0 {
+					t.Errorf("audited %d records of a method that is not audited", result.Records)
+				}
+				return
+			}
+
+			records := readRecords(t, path)
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			got := records[0]
+			if got.Operation != tt.method || got.Actor != tt.want.Actor || got.Target != tt.want.Target ||
+				got.Outcome != tt.want.Outcome || got.Code != tt.want.Code {
+				t.Errorf("record = %+v, want %+v", got, tt.want)
+			}
+			if tt.want.RequestID != "" && got.RequestID != tt.want.RequestID {
+				t.Errorf("request ID = %q, want %q", got.RequestID, tt.want.RequestID)
+			}
+			if got.RequestID == "" {
+				t.Error("record has no request ID")
+			}
+		})
+	}
+}
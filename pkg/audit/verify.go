@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxRecordSize is the longest line accepted in an audit file.
+const maxRecordSize = 1024 * 1024
+
+// ChainError reports the first record that breaks the chain of an audit file.
+type ChainError struct {
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// VerifyResult summarises an audit file whose chain is intact.
+type VerifyResult struct {
+	Records  int
+	LastSeq  uint64
+	LastHash string
+}
+
+// Verify reads an audit file and checks that every record has the next
+// sequence number, links to the hash of the record before it, and matches its
+// own hash. It returns a *ChainError for the first record that does not.
+func Verify(r io.Reader) (*VerifyResult, error) {
+	result := &VerifyResult{LastHash: GenesisHash}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, &ChainError{Line: line, Seq: result.LastSeq + 1, Reason: "malformed record"}
+		}
+		if record.Seq != result.LastSeq+1 {
+			return nil, &ChainError{Line: line, Seq: record.Seq, Reason: fmt.Sprintf("expected seq %d", result.LastSeq+1)}
+		}
+		if record.PrevHash != result.LastHash {
+			return nil, &ChainError{Line: line, Seq: record.Seq, Reason: "previous hash does not match"}
+		}
+		hash, err := record.computeHash()
+		if err != nil {
+			return nil, err
+		}
+		if record.Hash != hash {
+			return nil, &ChainError{Line: line, Seq: record.Seq, Reason: "record hash does not match its content"}
+		}
+
+		result.Records++
+		result.LastSeq = record.Seq
+		result.LastHash = record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return result, nil
+}
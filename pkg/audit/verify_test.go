@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chainLines returns the lines of an audit file with n intact records.
+func chainLines(t *testing.T, n int) []string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := openLog(t, path)
+	for i := 0; i < n; i++ {
+		if err := l.Append(Record{Operation: "/user.v1.UserService/UpdateUser", Actor: "user-1", Target: "user-1"}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	var lines []string
+	for _, record := range readRecords(t, path) {
+		b, err := json.Marshal(record)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		lines = append(lines, string(b))
+	}
+	return lines
+}
+
+// editRecord changes the record on line i, and recomputes its hash when rehash is set.
+func editRecord(t *testing.T, lines []string, i int, rehash bool, edit func(r *Record)) {
+	t.Helper()
+
+	var record Record
+	if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	edit(&record)
+	if rehash {
+		hash, err := record.computeHash()
+		if err != nil {
+			t.Fatalf("computeHash: %v", err)
+		}
+		record.Hash = hash
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	lines[i] = string(b)
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, lines []string) []string
+		// wantRecords is the number of records of an intact chain.
+		wantRecords int
+		// wantLine and wantReason describe the break; zero expects an intact chain.
+		wantLine   int
+		wantReason string
+	}{
+		{name: "intact", wantRecords: 3},
+		{
+			name:        "blank lines",
+			tamper:      func(_ *testing.T, lines []string) []string { return append([]string{""}, append(lines, "")...) },
+			wantRecords: 3,
+		},
+		{
+			name: "edited record",
+			tamper: func(t *testing.T, lines []string) []string {
+				editRecord(t, lines, 1, false, func(r *Record) { r.Actor = "user-2" })
+				return lines
+			},
+			wantLine: 2, wantReason: "record hash does not match its content",
+		},
+		{
+			name: "edited record with a recomputed hash",
+			tamper: func(t *testing.T, lines []string) []string {
+				editRecord(t, lines, 1, true, func(r *Record) { r.Outcome = OutcomeSuccess })
+				return lines
+			},
+			wantLine: 3, wantReason: "previous hash does not match",
+		},
+		{
+			name:     "removed record",
+			tamper:   func(_ *testing.T, lines []string) []string { return append(lines[:1], lines[2:]...) },
+			wantLine: 2, wantReason: "expected seq 2",
+		},
+		{
+			name: "removed record with renumbered successors",
+			tamper: func(t *testing.T, lines []string) []string {
+				lines = append(lines[:1], lines[2:]...)
+				editRecord(t, lines, 1, true, func(r *Record) { r.Seq = 2 })
+				return lines
+			},
+			wantLine: 2, wantReason: "previous hash does not match",
+		},
+		{
+			name:     "reordered records",
+			tamper:   func(_ *testing.T, lines []string) []string { return []string{lines[0], lines[2], lines[1]} },
+			wantLine: 2, wantReason: "expected seq 2",
+		},
+		{
+			name:     "malformed record",
+			tamper:   func(_ *testing.T, lines []string) []string { lines[1] = "{"; return lines },
+			wantLine: 2, wantReason: "malformed record",
+		},
+		{
+			// Truncation leaves an intact chain; only the last hash kept
+			// elsewhere reveals it.
+			name:        "truncated",
+			tamper:      func(_ *testing.T, lines []string) []string { return lines[:2] },
+			wantRecords: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := chainLines(t, 3)
+			var last Record
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if tt.tamper != nil {
+				lines = tt.tamper(t, lines)
+			}
+
+			result, err := Verify(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+			if tt.wantLine != 0 {
+				var chainErr *ChainError
+				if !errors.As(err, &chainErr) || chainErr.Line != tt.wantLine || chainErr.Reason != tt.wantReason {
+					t.Fatalf("Verify error = %v, want a break at line %d: %s", err, tt.wantLine, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if result.Records != tt.wantRecords || result.LastSeq != uint64(tt.wantRecords) {
+				t.Errorf("Verify = %+v, want %d records", result, tt.wantRecords)
+			}
+			if intact := result.LastHash == last.Hash; intact != (tt.wantRecords == 3) {
+				t.Errorf("last hash %s matches the last appended record = %v, want %v", result.LastHash, intact, tt.wantRecords == 3)
+			}
+		})
+	}
+}
+
+func TestVerifyEmpty(t *testing.T) {
+	result, err := Verify(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if result.Records != 0 || result.LastHash != GenesisHash {
+		t.Errorf("Verify = %+v, want no records and the genesis hash", result)
+	}
+}
@@ -29,7 +29,7 @@ func main() {
 		log.Fatal("failed to listen: ", err)
 	}
 
-	s := grpc.NewServer(grpc.Creds(creds))
+	s := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(container.AuditInterceptor))
 	pb.RegisterUserServiceServer(s, container.Server)
 
 	// Handle shutdown signals
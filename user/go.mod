@@ -3,6 +3,7 @@ module github.com/PakornBank/go-grpc-example/user
 go 1.23.4
 
 require (
+	github.com/PakornBank/go-grpc-example v0.0.0
 	github.com/google/uuid v1.6.0
 	github.com/spf13/viper v1.19.0
 	google.golang.org/grpc v1.70.0
@@ -44,3 +45,5 @@ require (
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/PakornBank/go-grpc-example => ../
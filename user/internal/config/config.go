@@ -16,6 +16,8 @@ type Config struct {
 	CACertPath     string `mapstructure:"CA_CERT_PATH"`
 	ServerCertPath string `mapstructure:"SERVER_CERT_PATH"`
 	ServerKeyPath  string `mapstructure:"SERVER_KEY_PATH"`
+
+	AuditLogPath string `mapstructure:"AUDIT_LOG_PATH"`
 }
 
 func LoadConfig() (*Config, error) {
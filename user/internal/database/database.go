@@ -12,7 +12,10 @@ import (
 
 // NewDataBase initializes a new database connection using the provided configuration.
 func NewDataBase(config *config.Config) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(config.DBURL()), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(config.DBURL()), &gorm.Config{
+		// Report unique violations as gorm.ErrDuplicatedKey.
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -3,17 +3,25 @@ package di
 import (
 	"log"
 
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
 	"github.com/PakornBank/go-grpc-example/user/internal/config"
 	"github.com/PakornBank/go-grpc-example/user/internal/database"
 	"github.com/PakornBank/go-grpc-example/user/internal/repository"
 	"github.com/PakornBank/go-grpc-example/user/internal/server"
 	"github.com/PakornBank/go-grpc-example/user/internal/service"
+	"google.golang.org/grpc"
 	"gorm.io/gorm"
 )
 
+// defaultAuditLogPath is used when AUDIT_LOG_PATH is not configured.
+const defaultAuditLogPath = "audit.jsonl"
+
 type Container struct {
-	Server *server.Server
-	DB     *gorm.DB
+	Server   *server.Server
+	DB       *gorm.DB
+	AuditLog *audit.Log
+	// AuditInterceptor records the state-changing RPCs of Server in AuditLog.
+	AuditInterceptor grpc.UnaryServerInterceptor
 }
 
 func NewContainer(cfg *config.Config) *Container {
@@ -25,13 +33,28 @@ func NewContainer(cfg *config.Config) *Container {
 	r := repository.NewRepository(db)
 	s := service.NewService(r)
 
+	auditLogPath := cfg.AuditLogPath
+	if auditLogPath == "" {
+		auditLogPath = defaultAuditLogPath
+	}
+	auditLog, err := audit.Open(auditLogPath, "user")
+	if err != nil {
+		log.Fatal("failed to open audit log: ", err)
+	}
+
 	return &Container{
-		Server: server.NewServer(s),
-		DB:     db,
+		Server:           server.NewServer(s),
+		DB:               db,
+		AuditLog:         auditLog,
+		AuditInterceptor: audit.UnaryServerInterceptor(auditLog, server.AuditedMethods),
 	}
 }
 
 func (c *Container) Close() error {
+	if err := c.AuditLog.Close(); err != nil {
+		log.Printf("failed to close audit log: %v", err)
+	}
+
 	sqlDB, err := c.DB.DB()
 	if err != nil {
 		return err
@@ -3,16 +3,37 @@ package repository
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/PakornBank/go-grpc-example/user/internal/model"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrDuplicateKey is returned when a user would share its ID or email with another user.
+var ErrDuplicateKey = errors.New("user already exists")
+
 type Repository interface {
 	CreateUser(ctx context.Context, user *model.User) error
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
 	FindByID(ctx context.Context, id string) (*model.User, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*model.User, error)
+	ListUsers(ctx context.Context, query ListUsersQuery) ([]model.User, error)
+	DeleteByID(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// ListUsersQuery holds the filters and keyset position of a ListUsers call.
+type ListUsersQuery struct {
+	EmailPrefix   string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Descending    bool
+	// AfterCreatedAt and AfterID are the sort key of the last user of the
+	// previous page. Users are returned strictly after it in sort order.
+	AfterCreatedAt *time.Time
+	AfterID        uuid.UUID
+	Limit          int
 }
 
 type repository struct {
@@ -23,9 +44,16 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
-// CreateUser inserts a new user record into the database.
+// CreateUser inserts a new user record into the database. It returns
+// ErrDuplicateKey if the ID or email is already taken.
 func (r *repository) CreateUser(ctx context.Context, user *model.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+	if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicateKey
+		}
+		return err
+	}
+	return nil
 }
 
 // FindByEmail retrieves a user from the database by their email address.
@@ -42,7 +70,7 @@ func (r *repository) FindByEmail(ctx context.Context, email string) (*model.User
 	return &user, nil
 }
 
-// FindByID retrieves a user from the database by their email address.
+// FindByID retrieves a user from the database by their ID.
 func (r *repository) FindByID(ctx context.Context, id string) (*model.User, error) {
 	uid, err := uuid.Parse(id)
 	if err != nil {
@@ -59,3 +87,67 @@ func (r *repository) FindByID(ctx context.Context, id string) (*model.User, erro
 
 	return &user, nil
 }
+
+// UpdateUser applies the given column updates to a user and bumps its UpdatedAt.
+// It returns nil if the user does not exist.
+func (r *repository) UpdateUser(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*model.User, error) {
+	updates["updated_at"] = time.Now()
+
+	result := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return r.FindByID(ctx, id.String())
+}
+
+// DeleteByID deletes a user record from the database. It reports false if there was no such user.
+func (r *repository) DeleteByID(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.User{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// likeEscaper escapes the LIKE wildcards of a user supplied prefix.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUsers retrieves a page of users ordered by creation time and ID.
+func (r *repository) ListUsers(ctx context.Context, query ListUsersQuery) ([]model.User, error) {
+	db := r.db.WithContext(ctx).Model(&model.User{})
+
+	if query.EmailPrefix != "" {
+		db = db.Where("email LIKE ?", likeEscaper.Replace(query.EmailPrefix)+"%")
+	}
+	if query.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *query.CreatedBefore)
+	}
+
+	direction := "ASC"
+	comparison := ">"
+	if query.Descending {
+		direction = "DESC"
+		comparison = "<"
+	}
+	if query.AfterCreatedAt != nil {
+		db = db.Where("(created_at, id) "+comparison+" (?, ?)", *query.AfterCreatedAt, query.AfterID)
+	}
+
+	var users []model.User
+	if err := db.
+		Order("created_at " + direction).
+		Order("id " + direction).
+		Limit(query.Limit).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunRepository returns a repository that builds queries without running
+// them, and a func that returns the SQL and arguments of the last query.
+func dryRunRepository(t *testing.T) (Repository, func() (string, []interface{})) {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+
+	var sql string
+	var vars []interface{}
+	if err := db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+		vars = tx.Statement.Vars
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	return NewRepository(db), func() (string, []interface{}) { return sql, vars }
+}
+
+func TestListUsersQuery(t *testing.T) {
+	after := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := after.Add(24 * time.Hour)
+	lastID := uuid.MustParse("6b2f3c9e-0d55-4a57-9d59-3f7f4f9c2a11")
+
+	tests := []struct {
+		name     string
+		query    ListUsersQuery
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{
+			name:     "first page newest first",
+			query:    ListUsersQuery{Descending: true, Limit: 11},
+			wantSQL:  `SELECT * FROM "users" ORDER BY created_at DESC,id DESC LIMIT $1`,
+			wantVars: []interface{}{11},
+		},
+		{
+			name:     "email prefix with LIKE wildcards",
+			query:    ListUsersQuery{EmailPrefix: `a_b%c\d`, Limit: 11},
+			wantSQL:  `SELECT * FROM "users" WHERE email LIKE $1 ORDER BY created_at ASC,id ASC LIMIT $2`,
+			wantVars: []interface{}{`a\_b\%c\\d%`, 11},
+		},
+		{
+			name:     "created range",
+			query:    ListUsersQuery{CreatedAfter: &after, CreatedBefore: &before, Limit: 11},
+			wantSQL:  `SELECT * FROM "users" WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at ASC,id ASC LIMIT $3`,
+			wantVars: []interface{}{after, before, 11},
+		},
+		{
+			name:     "next page oldest first",
+			query:    ListUsersQuery{AfterCreatedAt: &after, AfterID: lastID, Limit: 11},
+			wantSQL:  `SELECT * FROM "users" WHERE (created_at, id) > ($1, $2) ORDER BY created_at ASC,id ASC LIMIT $3`,
+			wantVars: []interface{}{after, lastID, 11},
+		},
+		{
+			name:     "next page newest first",
+			query:    ListUsersQuery{Descending: true, AfterCreatedAt: &after, AfterID: lastID, Limit: 11},
+			wantSQL:  `SELECT * FROM "users" WHERE (created_at, id) < ($1, $2) ORDER BY created_at DESC,id DESC LIMIT $3`,
+			wantVars: []interface{}{after, lastID, 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, lastQuery := dryRunRepository(t)
+			if _, err := r.ListUsers(context.Background(), tt.query); err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+
+			sql, vars := lastQuery()
+			if sql != tt.wantSQL {
+				t.Errorf("SQL = %s\nwant  %s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(vars, tt.wantVars) {
+				t.Errorf("vars = %#v, want %#v", vars, tt.wantVars)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Package repositorytest provides an in-memory repository.Repository for tests.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/user/internal/model"
+	"github.com/PakornBank/go-grpc-example/user/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Repository is an in-memory repository.Repository. It behaves like the
+// database implementation. Users are stored by value, so that callers cannot
+// change them without going through the repository.
+type Repository struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]model.User
+}
+
+var _ repository.Repository = (*Repository)(nil)
+
+// New creates an empty Repository.
+func New() *Repository {
+	return &Repository{users: map[uuid.UUID]model.User{}}
+}
+
+// CreateUser inserts a new user. CreatedAt and UpdatedAt default to the
+// current time, like the column defaults.
+func (r *Repository) CreateUser(_ context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	if _, ok := r.users[user.ID]; ok {
+		return repository.ErrDuplicateKey
+	}
+	for _, u := range r.users {
+		if u.Email == user.Email {
+			return repository.ErrDuplicateKey
+		}
+	}
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+// FindByEmail retrieves a user by their email.
+func (r *Repository) FindByEmail(_ context.Context, email string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindByID retrieves a user by their ID.
+func (r *Repository) FindByID(_ context.Context, id string) (*model.User, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u, ok := r.users[uid]; ok {
+		return &u, nil
+	}
+	return nil, nil
+}
+
+// UpdateUser applies column updates to a user and bumps its UpdatedAt. It
+// returns nil if the user does not exist.
+func (r *Repository) UpdateUser(_ context.Context, id uuid.UUID, updates map[string]interface{}) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	for column, value := range updates {
+		switch column {
+		case "full_name":
+			u.FullName = value.(string)
+		default:
+			return nil, errors.New("unknown column " + column)
+		}
+	}
+	u.UpdatedAt = time.Now()
+	r.users[id] = u
+	return &u, nil
+}
+
+// ListUsers returns a page of users ordered by creation time and ID.
+func (r *Repository) ListUsers(_ context.Context, query repository.ListUsersQuery) ([]model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var users []model.User
+	for _, u := range r.users {
+		if !strings.HasPrefix(u.Email, query.EmailPrefix) ||
+			query.CreatedAfter != nil && u.CreatedAt.Before(*query.CreatedAfter) ||
+			query.CreatedBefore != nil && !u.CreatedAt.Before(*query.CreatedBefore) {
+			continue
+		}
+		if query.AfterCreatedAt != nil && !sortsAfter(u, *query.AfterCreatedAt, query.AfterID, query.Descending) {
+			continue
+		}
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return sortsAfter(users[j], users[i].CreatedAt, users[i].ID, query.Descending)
+	})
+
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+	return users, nil
+}
+
+// sortsAfter reports whether u comes after the key (createdAt, id) in the given order.
+func sortsAfter(u model.User, createdAt time.Time, id uuid.UUID, descending bool) bool {
+	after := u.CreatedAt.After(createdAt)
+	if u.CreatedAt.Equal(createdAt) {
+		if u.ID == id {
+			return false
+		}
+		after = u.ID.String() > id.String()
+	}
+	return after != descending
+}
+
+// DeleteByID deletes a user. It reports false if there was no such user.
+func (r *Repository) DeleteByID(_ context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return false, nil
+	}
+	delete(r.users, id)
+	return true, nil
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"github.com/PakornBank/go-grpc-example/pkg/audit"
+	pb "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
+)
+
+// AuditedMethods lists the state-changing RPCs that are written to the audit log.
+var AuditedMethods = map[string]audit.TargetFunc{
+	pb.UserService_CreateUser_FullMethodName: nil,
+	pb.UserService_UpdateUser_FullMethodName: updatedUserTarget,
+	pb.UserService_DeleteUser_FullMethodName: nil,
+}
+
+// updatedUserTarget returns the ID of the user whose profile was updated.
+func updatedUserTarget(req, _ any) string {
+	if r, ok := req.(*pb.UpdateUserRequest); ok {
+		return r.GetUser().GetId()
+	}
+	return ""
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"log"
 
+	"github.com/PakornBank/go-grpc-example/user/internal/model"
 	"github.com/PakornBank/go-grpc-example/user/internal/service"
 	pb "github.com/PakornBank/go-grpc-example/user/proto/user/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -42,13 +44,7 @@ func (s *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 
-	return &pb.CreateUserResponse{User: &pb.User{
-		Id:        user.ID.String(),
-		Email:     user.Email,
-		FullName:  user.FullName,
-		CreatedAt: timestamppb.New(user.CreatedAt),
-		UpdatedAt: timestamppb.New(user.UpdatedAt),
-	}}, nil
+	return &pb.CreateUserResponse{User: toPB(user)}, nil
 }
 
 func (s *Server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
@@ -62,11 +58,99 @@ func (s *Server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUs
 		return nil, status.Error(codes.NotFound, "user not found")
 	}
 
-	return &pb.GetUserResponse{User: &pb.User{
+	return &pb.GetUserResponse{User: toPB(user)}, nil
+}
+
+// UpdateUser updates the fields of a user listed in the update mask.
+func (s *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	if req.User == nil {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+
+	user, err := s.service.UpdateUser(ctx, req.User.Id, &model.User{
+		FullName: req.User.FullName,
+	}, req.UpdateMask.GetPaths())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidID):
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		case errors.Is(err, service.ErrInvalidUpdateMask), errors.Is(err, service.ErrInvalidFullName):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Printf("update user error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	if user == nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	return &pb.UpdateUserResponse{User: toPB(user)}, nil
+}
+
+// ListUsers returns a page of users matching the request filters.
+func (s *Server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	params := service.ListUsersParams{
+		PageSize:    int(req.PageSize),
+		PageToken:   req.PageToken,
+		EmailPrefix: req.EmailPrefix,
+		OrderBy:     req.OrderBy,
+	}
+	if req.CreatedAfter != nil {
+		t := req.CreatedAfter.AsTime()
+		params.CreatedAfter = &t
+	}
+	if req.CreatedBefore != nil {
+		t := req.CreatedBefore.AsTime()
+		params.CreatedBefore = &t
+	}
+
+	users, nextPageToken, err := s.service.ListUsers(ctx, params)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPageSize),
+			errors.Is(err, service.ErrInvalidPageToken),
+			errors.Is(err, service.ErrInvalidOrderBy):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Printf("list users error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	res := &pb.ListUsersResponse{
+		Users:         make([]*pb.User, 0, len(users)),
+		NextPageToken: nextPageToken,
+	}
+	for i := range users {
+		res.Users = append(res.Users, toPB(&users[i]))
+	}
+
+	return res, nil
+}
+
+// DeleteUser deletes the profile of a user.
+func (s *Server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	if err := s.service.DeleteUser(ctx, req.UserId); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidID):
+			return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+		case errors.Is(err, service.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("delete user error: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// toPB converts a user model to its protobuf representation.
+func toPB(user *model.User) *pb.User {
+	return &pb.User{
 		Id:        user.ID.String(),
 		Email:     user.Email,
 		FullName:  user.FullName,
 		CreatedAt: timestamppb.New(user.CreatedAt),
 		UpdatedAt: timestamppb.New(user.UpdatedAt),
-	}}, nil
+	}
 }
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// pageToken is the keyset position encoded in an opaque page token. It also
+// carries a digest of the query so that a token cannot be reused with other filters.
+type pageToken struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+	Query     string    `json:"q"`
+}
+
+// encodePageToken returns the opaque page token for the given position.
+func encodePageToken(token pageToken) (string, error) {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodePageToken parses an opaque page token.
+func decodePageToken(s string) (*pageToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	var token pageToken
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	return &token, nil
+}
+
+// queryDigest returns a short digest identifying the filters and order of a ListUsers call.
+func queryDigest(params ListUsersParams) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%v|%v|%s",
+		params.EmailPrefix, formatTime(params.CreatedAfter), formatTime(params.CreatedBefore), params.OrderBy)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/PakornBank/go-grpc-example/user/internal/model"
 	"github.com/PakornBank/go-grpc-example/user/internal/repository"
@@ -14,12 +16,42 @@ var (
 	ErrEmailAlreadyExists = errors.New("email already registered")
 	ErrIDAlreadyExists    = errors.New("user ID already registered")
 	ErrInvalidID          = errors.New("invalid user ID")
+	ErrInvalidUpdateMask  = errors.New("invalid update mask")
+	ErrInvalidFullName    = errors.New("full name must not be empty")
+	ErrInvalidPageSize    = errors.New("page size must not be negative")
+	ErrInvalidPageToken   = errors.New("invalid page token")
+	ErrInvalidOrderBy     = errors.New("invalid order by")
+	ErrUserNotFound       = errors.New("user not found")
 )
 
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// updatableFields maps the update mask paths that may be changed to their columns.
+var updatableFields = map[string]string{
+	"full_name": "full_name",
+}
+
 // Service defines the methods that a service must implement.
 type Service interface {
 	CreateUser(ctx context.Context, id, email, password string) (*model.User, error)
 	GetUser(ctx context.Context, id string) (*model.User, error)
+	UpdateUser(ctx context.Context, id string, changes *model.User, paths []string) (*model.User, error)
+	ListUsers(ctx context.Context, params ListUsersParams) ([]model.User, string, error)
+	DeleteUser(ctx context.Context, id string) error
+}
+
+// ListUsersParams holds the filters and paging options of ListUsers.
+type ListUsersParams struct {
+	PageSize      int
+	PageToken     string
+	EmailPrefix   string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// OrderBy is "created_at asc" or "created_at desc". Defaults to newest first.
+	OrderBy string
 }
 
 // service is a struct that provides methods to interact with the user service.
@@ -32,21 +64,19 @@ func NewService(repository repository.Repository) Service {
 	return &service{repository: repository}
 }
 
-// CreateUser handles the user registration process.
+// CreateUser handles the user registration process. Creating a user that
+// already exists with the same ID and email returns the existing user, so the
+// call can be retried safely.
 func (s *service) CreateUser(ctx context.Context, id, email, fullName string) (*model.User, error) {
-	if existingUser, _ := s.repository.FindByID(ctx, id); existingUser != nil {
-		return nil, ErrIDAlreadyExists
-	}
-
-	if existingUser, _ := s.repository.FindByEmail(ctx, email); existingUser != nil {
-		return nil, ErrEmailAlreadyExists
-	}
-
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
 		return nil, ErrInvalidID
 	}
 
+	if existingUser, err := s.findExisting(ctx, parsedID, email); existingUser != nil || err != nil {
+		return existingUser, err
+	}
+
 	user := &model.User{
 		ID:       parsedID,
 		Email:    email,
@@ -54,12 +84,161 @@ func (s *service) CreateUser(ctx context.Context, id, email, fullName string) (*
 	}
 
 	if err := s.repository.CreateUser(ctx, user); err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		if !errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		// A concurrent call took the ID or email after the lookup above.
+		if existingUser, err := s.findExisting(ctx, parsedID, email); existingUser != nil || err != nil {
+			return existingUser, err
+		}
+		return nil, ErrEmailAlreadyExists
 	}
 
 	return user, nil
 }
 
+// findExisting returns the user with the given ID if it has the same email,
+// or an error if the ID or email belongs to another user. It returns nil if
+// neither is taken.
+func (s *service) findExisting(ctx context.Context, id uuid.UUID, email string) (*model.User, error) {
+	existingUser, err := s.repository.FindByID(ctx, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by id: %w", err)
+	}
+	if existingUser != nil {
+		if existingUser.Email == email {
+			return existingUser, nil
+		}
+		return nil, ErrIDAlreadyExists
+	}
+
+	existingUser, err = s.repository.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by email: %w", err)
+	}
+	if existingUser != nil {
+		return nil, ErrEmailAlreadyExists
+	}
+
+	return nil, nil
+}
+
 func (s *service) GetUser(ctx context.Context, id string) (*model.User, error) {
 	return s.repository.FindByID(ctx, id)
 }
+
+// UpdateUser copies the fields listed in paths from changes to the user with the given ID.
+// It returns nil if the user does not exist.
+func (s *service) UpdateUser(ctx context.Context, id string, changes *model.User, paths []string) (*model.User, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: no paths given", ErrInvalidUpdateMask)
+	}
+
+	updates := make(map[string]interface{}, len(paths))
+	for _, path := range paths {
+		column, ok := updatableFields[path]
+		if !ok {
+			return nil, fmt.Errorf("%w: field %q cannot be updated", ErrInvalidUpdateMask, path)
+		}
+
+		switch path {
+		case "full_name":
+			fullName := strings.TrimSpace(changes.FullName)
+			if fullName == "" {
+				return nil, ErrInvalidFullName
+			}
+			updates[column] = fullName
+		}
+	}
+
+	return s.repository.UpdateUser(ctx, parsedID, updates)
+}
+
+// ListUsers returns a page of users matching the filters, and the token of the next page if there is one.
+func (s *service) ListUsers(ctx context.Context, params ListUsersParams) ([]model.User, string, error) {
+	if params.PageSize < 0 {
+		return nil, "", ErrInvalidPageSize
+	}
+	if params.PageSize == 0 {
+		params.PageSize = defaultPageSize
+	}
+	if params.PageSize > maxPageSize {
+		params.PageSize = maxPageSize
+	}
+
+	var descending bool
+	switch strings.ToLower(strings.Join(strings.Fields(params.OrderBy), " ")) {
+	case "", "created_at desc":
+		descending = true
+	case "created_at", "created_at asc":
+		descending = false
+	default:
+		return nil, "", ErrInvalidOrderBy
+	}
+
+	query := repository.ListUsersQuery{
+		EmailPrefix:   params.EmailPrefix,
+		CreatedAfter:  params.CreatedAfter,
+		CreatedBefore: params.CreatedBefore,
+		Descending:    descending,
+		Limit:         params.PageSize + 1,
+	}
+
+	digest := queryDigest(params)
+	if params.PageToken != "" {
+		token, err := decodePageToken(params.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		if token.Query != digest {
+			return nil, "", fmt.Errorf("%w: filters changed", ErrInvalidPageToken)
+		}
+		query.AfterCreatedAt = &token.CreatedAt
+		query.AfterID = token.ID
+	}
+
+	users, err := s.repository.ListUsers(ctx, query)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list users: %w", err)
+	}
+
+	if len(users) <= params.PageSize {
+		return users, "", nil
+	}
+
+	users = users[:params.PageSize]
+	last := users[len(users)-1]
+	nextPageToken, err := encodePageToken(pageToken{
+		CreatedAt: last.CreatedAt,
+		ID:        last.ID,
+		Query:     digest,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode page token: %w", err)
+	}
+
+	return users, nextPageToken, nil
+}
+
+// DeleteUser deletes the profile of a user.
+func (s *service) DeleteUser(ctx context.Context, id string) error {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	deleted, err := s.repository.DeleteByID(ctx, parsedID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,416 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PakornBank/go-grpc-example/user/internal/model"
+	"github.com/PakornBank/go-grpc-example/user/internal/repository"
+	"github.com/PakornBank/go-grpc-example/user/internal/repository/repositorytest"
+	"github.com/google/uuid"
+)
+
+// createUser stores a user created at the given time.
+func createUser(t *testing.T, r *repositorytest.Repository, email string, createdAt time.Time) *model.User {
+	t.Helper()
+
+	user := &model.User{ID: uuid.New(), Email: email, FullName: "Test User", CreatedAt: createdAt, UpdatedAt: createdAt}
+	if err := r.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+var errLookup = errors.New("connection reset")
+
+// failingLookups fails every lookup, as if the database was unreachable.
+type failingLookups struct {
+	*repositorytest.Repository
+}
+
+func (failingLookups) FindByID(context.Context, string) (*model.User, error) {
+	return nil, errLookup
+}
+
+func (failingLookups) FindByEmail(context.Context, string) (*model.User, error) {
+	return nil, errLookup
+}
+
+// racingCreate stores racer right before the first user is created, as if a
+// concurrent call inserted it after CreateUser looked for existing users.
+type racingCreate struct {
+	*repositorytest.Repository
+	racer *model.User
+}
+
+func (r *racingCreate) CreateUser(ctx context.Context, user *model.User) error {
+	if r.racer != nil {
+		racer := r.racer
+		r.racer = nil
+		if err := r.Repository.CreateUser(ctx, racer); err != nil {
+			return err
+		}
+	}
+	return r.Repository.CreateUser(ctx, user)
+}
+
+func TestCreateUser(t *testing.T) {
+	existingID := uuid.New()
+	newID := uuid.New()
+
+	tests := []struct {
+		name  string
+		id    string
+		email string
+		// repository wraps the store, which holds alice@example.com with existingID.
+		repository func(r *repositorytest.Repository) repository.Repository
+		wantErr    error
+		// wantID is the ID of the returned user.
+		wantID uuid.UUID
+	}{
+		{name: "new user", id: newID.String(), email: "bob@example.com", wantID: newID},
+		{name: "retry of a created user", id: existingID.String(), email: "alice@example.com", wantID: existingID},
+		{name: "ID of another user", id: existingID.String(), email: "bob@example.com", wantErr: ErrIDAlreadyExists},
+		{name: "email of another user", id: newID.String(), email: "alice@example.com", wantErr: ErrEmailAlreadyExists},
+		{name: "malformed ID", id: "not-a-uuid", email: "bob@example.com", wantErr: ErrInvalidID},
+		{
+			name: "lookup fails", id: newID.String(), email: "bob@example.com",
+			repository: func(r *repositorytest.Repository) repository.Repository { return failingLookups{r} },
+			wantErr:    errLookup,
+		},
+		{
+			name: "concurrent retry", id: newID.String(), email: "bob@example.com",
+			repository: func(r *repositorytest.Repository) repository.Repository {
+				return &racingCreate{Repository: r, racer: &model.User{ID: newID, Email: "bob@example.com", FullName: "Bob"}}
+			},
+			wantID: newID,
+		},
+		{
+			name: "email taken concurrently", id: newID.String(), email: "bob@example.com",
+			repository: func(r *repositorytest.Repository) repository.Repository {
+				return &racingCreate{Repository: r, racer: &model.User{ID: uuid.New(), Email: "bob@example.com", FullName: "Bob"}}
+			},
+			wantErr: ErrEmailAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := repositorytest.New()
+			if err := store.CreateUser(ctx, &model.User{ID: existingID, Email: "alice@example.com", FullName: "Alice"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			var r repository.Repository = store
+			if tt.repository != nil {
+				r = tt.repository(store)
+			}
+
+			got, err := NewService(r).CreateUser(ctx, tt.id, tt.email, "Bob")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateUser error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != tt.wantID || got.Email != tt.email) {
+				t.Errorf("CreateUser = %+v, want %s with ID %s", got, tt.email, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      func(existing string) string
+		changes *model.User
+		paths   []string
+		wantErr error
+		// wantFullName is the stored full name afterwards.
+		wantFullName string
+	}{
+		{name: "full name", changes: &model.User{FullName: "New Name"}, paths: []string{"full_name"}, wantFullName: "New Name"},
+		{name: "full name is trimmed", changes: &model.User{FullName: "  New Name \t"}, paths: []string{"full_name"}, wantFullName: "New Name"},
+		{
+			name:    "fields outside the mask are ignored",
+			changes: &model.User{FullName: "New Name", Email: "mallory@example.com"}, paths: []string{"full_name"},
+			wantFullName: "New Name",
+		},
+		{name: "blank full name", changes: &model.User{FullName: "   "}, paths: []string{"full_name"}, wantErr: ErrInvalidFullName, wantFullName: "Test User"},
+		{name: "empty mask", changes: &model.User{FullName: "New Name"}, wantErr: ErrInvalidUpdateMask, wantFullName: "Test User"},
+		{
+			name:    "immutable field",
+			changes: &model.User{Email: "mallory@example.com"}, paths: []string{"email"},
+			wantErr: ErrInvalidUpdateMask, wantFullName: "Test User",
+		},
+		{
+			name:    "unknown field with an updatable one",
+			changes: &model.User{FullName: "New Name"}, paths: []string{"full_name", "nickname"},
+			wantErr: ErrInvalidUpdateMask, wantFullName: "Test User",
+		},
+		{
+			name:    "malformed ID",
+			id:      func(string) string { return "not-a-uuid" },
+			changes: &model.User{FullName: "New Name"}, paths: []string{"full_name"},
+			wantErr: ErrInvalidID, wantFullName: "Test User",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := repositorytest.New()
+			s := NewService(r)
+			existing := createUser(t, r, "alice@example.com", time.Now().Add(-time.Hour))
+			id := existing.ID.String()
+			if tt.id != nil {
+				id = tt.id(id)
+			}
+
+			got, err := s.UpdateUser(ctx, id, tt.changes, tt.paths)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUser error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.FullName != tt.wantFullName || got.Email != existing.Email || !got.UpdatedAt.After(existing.UpdatedAt)) {
+				t.Errorf("UpdateUser = %+v, want %q with a new UpdatedAt and the old email", got, tt.wantFullName)
+			}
+
+			stored, err := r.FindByID(ctx, existing.ID.String())
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if stored.FullName != tt.wantFullName || stored.Email != existing.Email {
+				t.Errorf("stored user = %+v, want %q with the old email", stored, tt.wantFullName)
+			}
+		})
+	}
+}
+
+func TestUpdateUserNotFound(t *testing.T) {
+	s := NewService(repositorytest.New())
+
+	got, err := s.UpdateUser(context.Background(), uuid.NewString(), &model.User{FullName: "New Name"}, []string{"full_name"})
+	if got != nil || err != nil {
+		t.Errorf("UpdateUser = %v, %v, want nil for an unknown user", got, err)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Users are created an hour apart, except bob and carol, whose tie is
+	// broken by their IDs.
+	seed := []struct {
+		email   string
+		created time.Duration
+	}{
+		{"alice@example.com", 0},
+		{"bob@example.com", time.Hour},
+		{"carol@example.com", time.Hour},
+		{"dave@example.org", 2 * time.Hour},
+		{"a_b@example.com", 3 * time.Hour},
+		{"axb@example.com", 4 * time.Hour},
+	}
+
+	tests := []struct {
+		name   string
+		params ListUsersParams
+		// want lists the emails of every page in order.
+		want []string
+	}{
+		{
+			name:   "newest first by default",
+			params: ListUsersParams{PageSize: 2},
+			want:   []string{"axb@example.com", "a_b@example.com", "dave@example.org", "<tie>", "<tie>", "alice@example.com"},
+		},
+		{
+			name:   "oldest first",
+			params: ListUsersParams{PageSize: 4, OrderBy: "  CREATED_AT   asc "},
+			want:   []string{"alice@example.com", "<tie>", "<tie>", "dave@example.org", "a_b@example.com", "axb@example.com"},
+		},
+		{
+			name:   "email prefix",
+			params: ListUsersParams{PageSize: 1, EmailPrefix: "a", OrderBy: "created_at"},
+			want:   []string{"alice@example.com", "a_b@example.com", "axb@example.com"},
+		},
+		{
+			name: "created range",
+			params: ListUsersParams{
+				PageSize:      1,
+				CreatedAfter:  timePtr(base.Add(time.Hour)),
+				CreatedBefore: timePtr(base.Add(3 * time.Hour)),
+			},
+			want: []string{"dave@example.org", "<tie>", "<tie>"},
+		},
+		{name: "no match", params: ListUsersParams{EmailPrefix: "zed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := repositorytest.New()
+			s := NewService(r)
+			for _, u := range seed {
+				createUser(t, r, u.email, base.Add(u.created))
+			}
+
+			var got []model.User
+			params := tt.params
+			for pages := 1; ; pages++ {
+				users, next, err := s.ListUsers(ctx, params)
+				if err != nil {
+					t.Fatalf("ListUsers: %v", err)
+				}
+				if len(users) > tt.params.PageSize {
+					t.Fatalf("page of %d users, want at most %d", len(users), tt.params.PageSize)
+				}
+				got = append(got, users...)
+				if next == "" {
+					break
+				}
+				if pages > len(seed) {
+					t.Fatal("ListUsers does not stop paging")
+				}
+				params.PageToken = next
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("listed %d users, want %d", len(got), len(tt.want))
+			}
+			var tie []string
+			for i, u := range got {
+				if tt.want[i] == "<tie>" {
+					tie = append(tie, u.ID.String())
+					continue
+				}
+				if u.Email != tt.want[i] {
+					t.Errorf("user %d = %s, want %s", i, u.Email, tt.want[i])
+				}
+			}
+			if len(tie) == 2 {
+				descending := tt.params.OrderBy == ""
+				if (tie[0] > tie[1]) != descending {
+					t.Errorf("users with the same creation time are listed as %v, want them ordered by ID", tie)
+				}
+			}
+		})
+	}
+}
+
+func TestListUsersInvalidParams(t *testing.T) {
+	ctx := context.Background()
+	r := repositorytest.New()
+	s := NewService(r)
+	for i := 0; i < 3; i++ {
+		createUser(t, r, fmt.Sprintf("user%d@example.com", i), time.Now().Add(time.Duration(i)*time.Minute))
+	}
+	_, token, err := s.ListUsers(ctx, ListUsersParams{PageSize: 1, EmailPrefix: "user"})
+	if err != nil || token == "" {
+		t.Fatalf("ListUsers = %q, %v, want a next page", token, err)
+	}
+
+	tests := []struct {
+		name    string
+		params  ListUsersParams
+		wantErr error
+	}{
+		{name: "same filters", params: ListUsersParams{PageSize: 1, EmailPrefix: "user", PageToken: token}},
+		{name: "page size changed", params: ListUsersParams{PageSize: 2, EmailPrefix: "user", PageToken: token}},
+		{name: "filter changed", params: ListUsersParams{PageSize: 1, EmailPrefix: "use", PageToken: token}, wantErr: ErrInvalidPageToken},
+		{name: "order changed", params: ListUsersParams{PageSize: 1, EmailPrefix: "user", OrderBy: "created_at asc", PageToken: token}, wantErr: ErrInvalidPageToken},
+		{
+			name:    "created range added",
+			params:  ListUsersParams{PageSize: 1, EmailPrefix: "user", CreatedAfter: timePtr(time.Now().Add(-time.Hour)), PageToken: token},
+			wantErr: ErrInvalidPageToken,
+		},
+		{name: "malformed page token", params: ListUsersParams{PageToken: "not a token"}, wantErr: ErrInvalidPageToken},
+		{name: "page token that is not JSON", params: ListUsersParams{PageToken: "bm90LWpzb24"}, wantErr: ErrInvalidPageToken},
+		{name: "negative page size", params: ListUsersParams{PageSize: -1}, wantErr: ErrInvalidPageSize},
+		{name: "unknown order", params: ListUsersParams{OrderBy: "email"}, wantErr: ErrInvalidOrderBy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := s.ListUsers(ctx, tt.params); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ListUsers error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListUsersPageSize(t *testing.T) {
+	ctx := context.Background()
+	r := repositorytest.New()
+	s := NewService(r)
+	for i := 0; i < maxPageSize+1; i++ {
+		createUser(t, r, fmt.Sprintf("user%03d@example.com", i), time.Now().Add(time.Duration(i)*time.Second))
+	}
+
+	tests := []struct {
+		name     string
+		pageSize int
+		want     int
+	}{
+		{name: "default", want: defaultPageSize},
+		{name: "requested", pageSize: 7, want: 7},
+		{name: "above the maximum", pageSize: maxPageSize + 50, want: maxPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, next, err := s.ListUsers(ctx, ListUsersParams{PageSize: tt.pageSize})
+			if err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if len(users) != tt.want || next == "" {
+				t.Errorf("ListUsers = %d users, next page %q, want %d and a next page", len(users), next, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          func(existing string) string
+		wantErr     error
+		wantDeleted bool
+	}{
+		{name: "existing user", wantDeleted: true},
+		{name: "unknown user", id: func(string) string { return uuid.NewString() }, wantErr: ErrUserNotFound},
+		{name: "malformed ID", id: func(string) string { return "not-a-uuid" }, wantErr: ErrInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := repositorytest.New()
+			s := NewService(r)
+			existing := createUser(t, r, "alice@example.com", time.Now())
+			other := createUser(t, r, "bob@example.com", time.Now())
+			id := existing.ID.String()
+			if tt.id != nil {
+				id = tt.id(id)
+			}
+
+			if err := s.DeleteUser(ctx, id); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteUser error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := s.GetUser(ctx, existing.ID.String())
+			if err != nil {
+				t.Fatalf("GetUser: %v", err)
+			}
+			if deleted := stored == nil; deleted != tt.wantDeleted {
+				t.Errorf("user deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if kept, err := s.GetUser(ctx, other.ID.String()); kept == nil || err != nil {
+				t.Errorf("GetUser of another user = %v, %v, want it kept", kept, err)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}